@@ -2,8 +2,12 @@
 package client
 
 import (
+	"hash/fnv"
+	"math"
 	"math/rand"
 	"net"
+	"slices"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -58,6 +62,289 @@ func (b *randomBalancer) Select(_ string, instances []*voyagerv1.Registration) *
 	return instances[rand.Intn(len(instances))]
 }
 
+// weightedBalancer implements smooth weighted round robin: every instance
+// gains its weight on each pick, the leader is selected and pays back the
+// total. Picks interleave instead of bursting on the heaviest instance.
+type weightedBalancer struct {
+	mu      sync.Mutex
+	current map[string]map[string]int64 // current weight per service and instance
+}
+
+func newWeightedBalancer() *weightedBalancer {
+	return &weightedBalancer{
+		current: make(map[string]map[string]int64),
+	}
+}
+
+// Select chooses the instance with the highest current weight
+func (b *weightedBalancer) Select(serviceName string, instances []*voyagerv1.Registration) *voyagerv1.Registration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current, exists := b.current[serviceName]
+	if !exists {
+		current = make(map[string]int64)
+		b.current[serviceName] = current
+	}
+
+	var selected *voyagerv1.Registration
+	var total int64
+	present := make(map[string]struct{}, len(instances))
+	for _, inst := range instances {
+		weight := int64(InstanceWeight(inst))
+		if weight == 0 {
+			continue
+		}
+		present[inst.InstanceId] = struct{}{}
+		total += weight
+		current[inst.InstanceId] += weight
+		if selected == nil || current[inst.InstanceId] > current[selected.InstanceId] {
+			selected = inst
+		}
+	}
+
+	// Forget instances that left so they restart from zero if they return
+	for id := range current {
+		if _, ok := present[id]; !ok {
+			delete(current, id)
+		}
+	}
+
+	if selected == nil {
+		return nil
+	}
+	current[selected.InstanceId] -= total
+	return selected
+}
+
+// KeyedLoadBalancer is a LoadBalancer that can route by a per-call hash key
+type KeyedLoadBalancer interface {
+	LoadBalancer
+	SelectKey(serviceName, key string, instances []*voyagerv1.Registration) *voyagerv1.Registration
+}
+
+const (
+	// ringReplicas is the number of ring points per unit of instance weight
+	// while the ring stays below maxRingSize
+	ringReplicas = 160
+	// maxRingSize bounds the ring; larger total weights are scaled down to it
+	maxRingSize = 1 << 17
+)
+
+// ringHashBalancer implements consistent hashing on a ring of virtual
+// nodes. When an instance joins or leaves only the keys next to its points
+// move; every other key keeps its instance.
+type ringHashBalancer struct {
+	mu    sync.Mutex
+	rings map[string]*hashRing
+}
+
+// hashRing is the ring of one service for a given instance set
+type hashRing struct {
+	members []*voyagerv1.Registration
+	points  []ringPoint
+}
+
+// ringPoint is a virtual node of an instance on the ring
+type ringPoint struct {
+	hash     uint64
+	instance *voyagerv1.Registration
+}
+
+func newRingHashBalancer() *ringHashBalancer {
+	return &ringHashBalancer{
+		rings: make(map[string]*hashRing),
+	}
+}
+
+// Select chooses an instance for a random key
+func (b *ringHashBalancer) Select(serviceName string, instances []*voyagerv1.Registration) *voyagerv1.Registration {
+	return b.selectHash(serviceName, rand.Uint64(), instances)
+}
+
+// SelectKey chooses the instance owning key on the ring
+func (b *ringHashBalancer) SelectKey(serviceName, key string, instances []*voyagerv1.Registration) *voyagerv1.Registration {
+	return b.selectHash(serviceName, ringHash(key), instances)
+}
+
+// selectHash returns the first ring point at or after hash
+func (b *ringHashBalancer) selectHash(serviceName string, hash uint64, instances []*voyagerv1.Registration) *voyagerv1.Registration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ring, exists := b.rings[serviceName]
+	if !exists || !ring.built(instances) {
+		ring = buildHashRing(instances)
+		b.rings[serviceName] = ring
+	}
+	if len(ring.points) == 0 {
+		return nil
+	}
+
+	i := sort.Search(len(ring.points), func(i int) bool {
+		return ring.points[i].hash >= hash
+	})
+	if i == len(ring.points) {
+		i = 0
+	}
+	return ring.points[i].instance
+}
+
+// buildHashRing places ringReplicas points per unit of weight for every
+// instance, derived from its ID so the ring only depends on the instance set.
+// Like Envoy's maximum ring size, weights adding up to more than maxRingSize
+// points are scaled down, keeping at least one point per weighted instance.
+func buildHashRing(instances []*voyagerv1.Registration) *hashRing {
+	var totalWeight float64
+	for _, inst := range instances {
+		totalWeight += float64(InstanceWeight(inst))
+	}
+	scale := float64(ringReplicas)
+	if totalWeight*scale > maxRingSize {
+		scale = maxRingSize / totalWeight
+	}
+
+	ring := &hashRing{members: slices.Clone(instances)}
+	for _, inst := range instances {
+		weight := InstanceWeight(inst)
+		if weight == 0 {
+			continue
+		}
+		replicas := max(int(math.Round(float64(weight)*scale)), 1)
+		for i := 0; i < replicas; i++ {
+			ring.points = append(ring.points, ringPoint{
+				hash:     ringHash(inst.InstanceId + "#" + strconv.Itoa(i)),
+				instance: inst,
+			})
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool {
+		return ring.points[i].hash < ring.points[j].hash
+	})
+	return ring
+}
+
+// built reports whether the ring was built from the same instances.
+// Registrations are never modified once cached, so lists filtered from the
+// same cache entry share their pointers and an unchanged set is recognized
+// without hashing it on every pick.
+func (r *hashRing) built(instances []*voyagerv1.Registration) bool {
+	return slices.Equal(r.members, instances)
+}
+
+// ringHash hashes a key with FNV-1a and a 64-bit finalizer, which spreads
+// the similar keys of virtual nodes evenly over the ring
+func ringHash(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// healthFilteringBalancer is implemented by balancers that receive
+// unhealthy instances too and skip them themselves, e.g. to measure how
+// healthy each locality is
+type healthFilteringBalancer interface {
+	LoadBalancer
+	filtersHealth()
+}
+
+// localityBalancer prefers instances in the client's zone, then its region,
+// then anywhere. A locality is used while at least threshold of its
+// instances are healthy; instances within it are picked by weight.
+type localityBalancer struct {
+	region    string
+	zone      string
+	threshold float64
+	picker    *weightedBalancer
+}
+
+func newLocalityBalancer(region, zone string, threshold float64) *localityBalancer {
+	return &localityBalancer{
+		region:    region,
+		zone:      zone,
+		threshold: threshold,
+		picker:    newWeightedBalancer(),
+	}
+}
+
+func (b *localityBalancer) filtersHealth() {}
+
+// Select chooses a healthy instance from the closest locality that is
+// healthy enough
+func (b *localityBalancer) Select(serviceName string, instances []*voyagerv1.Registration) *voyagerv1.Registration {
+	var sameZone, sameRegion []*voyagerv1.Registration
+	for _, inst := range instances {
+		locality := inst.GetLocality()
+		inRegion := b.region != "" && locality.GetRegion() == b.region
+		if inRegion {
+			sameRegion = append(sameRegion, inst)
+		}
+		// Without a region the zone name alone identifies the zone
+		if b.zone != "" && locality.GetZone() == b.zone && (inRegion || b.region == "") {
+			sameZone = append(sameZone, inst)
+		}
+	}
+
+	for _, tier := range [][]*voyagerv1.Registration{sameZone, sameRegion} {
+		healthy := healthyInstances(tier)
+		if len(healthy) > 0 && float64(len(healthy)) >= b.threshold*float64(len(tier)) {
+			return b.picker.Select(serviceName, healthy)
+		}
+	}
+	return b.picker.Select(serviceName, healthyInstances(instances))
+}
+
+// peakEWMABalancer implements power of two choices on the latency and
+// outstanding requests observed by the connection pool interceptors
+type peakEWMABalancer struct {
+	pool *ConnectionPool
+}
+
+func newPeakEWMABalancer(pool *ConnectionPool) *peakEWMABalancer {
+	return &peakEWMABalancer{pool: pool}
+}
+
+// Select chooses the cheaper of two random instances
+func (b *peakEWMABalancer) Select(_ string, instances []*voyagerv1.Registration) *voyagerv1.Registration {
+	switch len(instances) {
+	case 0:
+		return nil
+	case 1:
+		return instances[0]
+	}
+
+	i := rand.Intn(len(instances))
+	j := rand.Intn(len(instances) - 1)
+	if j >= i {
+		j++
+	}
+	first, second := instances[i], instances[j]
+	if b.cost(second) < b.cost(first) {
+		return second
+	}
+	return first
+}
+
+// cost returns the expected wait at an instance
+func (b *peakEWMABalancer) cost(inst *voyagerv1.Registration) float64 {
+	return b.pool.latency.cost(instanceAddress(inst))
+}
+
+// InstanceWeight returns the weight of an instance, DefaultWeight when the
+// registration does not set one
+func InstanceWeight(inst *voyagerv1.Registration) uint32 {
+	if inst.Weight == nil {
+		return DefaultWeight
+	}
+	return *inst.Weight
+}
+
 // leastConnectionsBalancer selects instance with least active connections
 type leastConnectionsBalancer struct {
 	pool *ConnectionPool
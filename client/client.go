@@ -5,9 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"net"
-	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,42 +16,53 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 
 	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+	"github.com/kolkov/voyager/internal/selector"
 )
 
 // Client manages service registration, discovery, and connection pooling
 type Client struct {
-	discoveryAddr     string
-	discoverySvc      voyagerv1.DiscoveryClient
-	conn              *grpc.ClientConn
-	cache             *cache.Cache
-	connectionPool    ConnectionPooler
-	options           *Options
-	instanceID        string
-	serviceName       string
-	healthMutex       sync.Mutex
-	healthCheckCtx    context.Context
-	healthCheckCancel context.CancelFunc
-	balancer          LoadBalancer
-	address           string
-	port              int
-}
-
-// New creates a new Voyager client with configured options
+	discoveryAddr      string
+	discoverySvc       voyagerv1.DiscoveryClient
+	conn               *grpc.ClientConn
+	cache              *cache.Cache
+	connectionPool     ConnectionPooler
+	options            *Options
+	healthMutex        sync.Mutex
+	healthCheckCtx     context.Context
+	healthCheckCancel  context.CancelFunc
+	healthStatus       voyagerv1.HealthResponse_Status // health of instances registered later
+	sessionMutex       sync.Mutex
+	session            voyagerv1.Discovery_SessionClient // open Session stream, nil without one
+	balancer           LoadBalancer
+	balancersMutex     sync.Mutex
+	balancers          map[BalancerStrategy]LoadBalancer // balancers of per-call strategy overrides
+	outliers           *outlierDetector                  // nil without outlier detection
+	lastKnown          *lastKnown                        // instance lists kept for discovery outages
+	registrationsMutex sync.Mutex
+	registrations      map[string]*Registration // instances kept alive, by instance ID
+}
+
+// New creates a new Voyager client with configured options. discoveryAddr is
+// one discovery server, a DNS name resolving to several, or a comma-separated
+// list; calls are balanced over all of them and fail over between them.
 func New(discoveryAddr string, opts ...Option) (*Client, error) {
 	options := defaultOptions()
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	if discoveryAddr == "" {
+	endpoints := discoveryEndpoints(discoveryAddr, options.DiscoveryEndpoints)
+	if len(endpoints) == 0 {
 		return nil, errors.New("discovery address cannot be empty")
 	}
 
-	log.Printf("Creating Voyager client for discovery service at: %s", discoveryAddr)
+	log.Printf("Creating Voyager client for discovery service at: %s", strings.Join(endpoints, ", "))
 
-	conn, svc, err := connectWithRetry(discoveryAddr, options)
+	conn, svc, err := connectWithRetry(endpoints, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to discovery service: %w", err)
 	}
@@ -59,116 +71,262 @@ func New(discoveryAddr string, opts ...Option) (*Client, error) {
 
 	pool := NewConnectionPool(options)
 
-	var balancer LoadBalancer
-	switch options.BalancerStrategy {
-	case Random:
-		balancer = newRandomBalancer()
-	case LeastConnections:
-		balancer = newLeastConnectionsBalancer(pool)
-	default:
-		balancer = newRoundRobinBalancer()
-	}
-
 	return &Client{
-		discoveryAddr:  discoveryAddr,
+		discoveryAddr:  strings.Join(endpoints, ","),
 		discoverySvc:   svc,
 		conn:           conn,
 		cache:          cache.New(options.TTL, 10*time.Minute),
 		connectionPool: pool,
 		options:        options,
-		balancer:       balancer,
+		balancer:       newBalancer(options.BalancerStrategy, options, pool),
+		outliers:       pool.outliers,
+		lastKnown:      newLastKnown(options.MaxStaleness),
 	}, nil
 }
 
-// Register registers the service instance with the discovery service
-func (c *Client) Register(serviceName, address string, port int, metadata map[string]string) error {
-	if serviceName == "" || address == "" || port == 0 {
-		return errors.New("invalid registration parameters")
+// newBalancer creates the balancer of a strategy. Strategies measuring
+// connections need the pool and fall back to round robin without one.
+func newBalancer(strategy BalancerStrategy, options *Options, pool *ConnectionPool) LoadBalancer {
+	switch strategy {
+	case Random:
+		return newRandomBalancer()
+	case LeastConnections:
+		if pool != nil {
+			return newLeastConnectionsBalancer(pool)
+		}
+	case Weighted:
+		return newWeightedBalancer()
+	case LocalityAware:
+		return newLocalityBalancer(options.Region, options.Zone, options.LocalitySpillover)
+	case ConsistentHash:
+		return newRingHashBalancer()
+	case PeakEWMA:
+		if pool != nil {
+			return newPeakEWMABalancer(pool)
+		}
+	}
+	return newRoundRobinBalancer()
+}
+
+// balancerFor returns the balancer of a per-call strategy override, created
+// on first use, or the client balancer
+func (c *Client) balancerFor(callOpts *discoverOptions) LoadBalancer {
+	if callOpts.strategy == nil || *callOpts.strategy == c.options.BalancerStrategy {
+		return c.balancer
 	}
 
-	c.serviceName = serviceName
-	c.address = address
-	c.port = port
+	c.balancersMutex.Lock()
+	defer c.balancersMutex.Unlock()
 
-	if c.instanceID == "" {
-		hostname, _ := os.Hostname()
-		c.instanceID = fmt.Sprintf("%s-%d", hostname, time.Now().UnixNano())
+	if lb, exists := c.balancers[*callOpts.strategy]; exists {
+		return lb
 	}
+	if c.balancers == nil {
+		c.balancers = make(map[BalancerStrategy]LoadBalancer)
+	}
+	pool, _ := c.connectionPool.(*ConnectionPool)
+	lb := newBalancer(*callOpts.strategy, c.options, pool)
+	c.balancers[*callOpts.strategy] = lb
+	return lb
+}
 
-	reg := &voyagerv1.Registration{
-		ServiceName: serviceName,
-		InstanceId:  c.instanceID,
-		Address:     address,
-		Port:        int32(port),
-		Metadata:    metadata,
+// Register registers a service instance with the discovery service and
+// returns its handle. Every call registers another instance, all kept alive
+// over one Session stream.
+func (c *Client) Register(serviceName, address string, port int, metadata map[string]string, opts ...RegisterOption) (*Registration, error) {
+	if serviceName == "" || address == "" || port == 0 {
+		return nil, errors.New("invalid registration parameters")
+	}
+
+	r := &Registration{
+		client:      c,
+		serviceName: serviceName,
+		instanceID:  newInstanceID(),
+		address:     address,
+		port:        port,
+		metadata:    maps.Clone(metadata),
+		opts:        opts,
+		health:      c.reportedHealth(),
+	}
+	if err := r.register(r.metadata, opts); err != nil {
+		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	c.addRegistration(r)
 
-	resp, err := c.discoverySvc.Register(ctx, reg)
+	// An open session binds the new instance right away; a failed send also
+	// breaks the session, which is then reopened
+	c.sessionMutex.Lock()
+	stream := c.session
+	c.sessionMutex.Unlock()
+	if stream != nil {
+		_ = c.sendKeepalives(stream, r)
+	}
+	return r, nil
+}
+
+// Discover returns a connection to a service instance using load balancing
+func (c *Client) Discover(ctx context.Context, serviceName string, opts ...DiscoverOption) (*grpc.ClientConn, error) {
+	callOpts, err := newDiscoverOptions(opts)
 	if err != nil {
-		return fmt.Errorf("registration failed: %w", err)
+		return nil, err
 	}
 
-	if !resp.Success {
-		return errors.New("registration failed: " + resp.Error)
+	selected, err := c.selectInstance(ctx, serviceName, callOpts, nil)
+	if err != nil {
+		return nil, err
 	}
+	return c.connectionPool.Get(ctx, instanceAddress(selected))
+}
 
-	c.startHealthChecks()
-	return nil
+// newDiscoverOptions applies per-call Discover options
+func newDiscoverOptions(opts []DiscoverOption) (*discoverOptions, error) {
+	callOpts := &discoverOptions{}
+	for _, opt := range opts {
+		opt.applyDiscover(callOpts)
+	}
+	if callOpts.err != nil {
+		return nil, fmt.Errorf("invalid discover options: %w", callOpts.err)
+	}
+	return callOpts, nil
 }
 
-// Discover returns a connection to a service instance using load balancing
-func (c *Client) Discover(ctx context.Context, serviceName string) (*grpc.ClientConn, error) {
-	instances, err := c.getServiceInstances(ctx, serviceName)
+// selectInstance picks an instance of a service with the balancer. Instances
+// whose address is in exclude are skipped unless no other one is left.
+func (c *Client) selectInstance(ctx context.Context, serviceName string, callOpts *discoverOptions, exclude map[string]bool) (*voyagerv1.Registration, error) {
+	instances, err := c.candidates(ctx, serviceName, callOpts)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(instances) == 0 {
+	if len(exclude) > 0 {
+		remaining := make([]*voyagerv1.Registration, 0, len(instances))
+		for _, inst := range instances {
+			if !exclude[instanceAddress(inst)] {
+				remaining = append(remaining, inst)
+			}
+		}
+		if len(healthyInstances(remaining)) > 0 {
+			instances = remaining
+		}
+	}
+	healthy := healthyInstances(instances)
+	if len(healthy) == 0 {
 		return nil, fmt.Errorf("no instances available for service: %s", serviceName)
 	}
+	balancer := c.balancerFor(callOpts)
+	if _, ok := balancer.(healthFilteringBalancer); !ok {
+		instances = healthy
+	}
 
-	selected := c.balancer.Select(serviceName, instances)
+	var selected *voyagerv1.Registration
+	if keyed, ok := balancer.(KeyedLoadBalancer); ok && callOpts.hashKey != "" {
+		selected = keyed.SelectKey(serviceName, callOpts.hashKey, instances)
+	} else {
+		selected = balancer.Select(serviceName, instances)
+	}
 	if selected == nil {
 		return nil, errors.New("no instance selected")
 	}
+	return selected, nil
+}
 
-	address := net.JoinHostPort(selected.Address, strconv.Itoa(int(selected.Port)))
-	return c.connectionPool.Get(ctx, address)
+// candidates returns the instances of a service that may receive traffic:
+// looked up through the cache, without weight 0 and ejected instances, and
+// with at least the minimum count of healthy ones. Unhealthy instances stay
+// in the list for balancers judging locality health.
+func (c *Client) candidates(ctx context.Context, serviceName string, callOpts *discoverOptions) ([]*voyagerv1.Registration, error) {
+	instances, err := c.requireInstances(ctx, serviceName, callOpts, servingInstances)
+	if err != nil {
+		return nil, err
+	}
+	if c.outliers != nil {
+		instances = c.outliers.filter(instances)
+	}
+	return instances, nil
 }
 
-// Deregister removes the service instance from the discovery service
-func (c *Client) Deregister() error {
-	if c.serviceName == "" || c.instanceID == "" {
-		return errors.New("service not registered")
+// requireInstances returns the instances of a service kept by keep, failing
+// unless at least callOpts.minInstances of them are healthy
+func (c *Client) requireInstances(ctx context.Context, serviceName string, callOpts *discoverOptions,
+	keep func([]*voyagerv1.Registration) []*voyagerv1.Registration) ([]*voyagerv1.Registration, error) {
+	instances, err := c.getServiceInstances(ctx, serviceName, callOpts)
+	if err != nil {
+		return nil, err
 	}
 
-	c.stopHealthChecks()
+	instances = keep(instances)
+	if available := len(healthyInstances(instances)); available < callOpts.minInstances {
+		if !callOpts.bypassCache {
+			// The cached list may lag behind; ask the discovery service
+			fresh := *callOpts
+			fresh.bypassCache = true
+			return c.requireInstances(ctx, serviceName, &fresh, keep)
+		}
+		return nil, fmt.Errorf("%d healthy instances available for service %s, %d required",
+			available, serviceName, callOpts.minInstances)
+	}
+	return instances, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// instanceAddress returns the host:port of an instance
+func instanceAddress(inst *voyagerv1.Registration) string {
+	return net.JoinHostPort(inst.Address, strconv.Itoa(int(inst.Port)))
+}
 
-	resp, err := c.discoverySvc.Deregister(ctx, &voyagerv1.InstanceID{
-		ServiceName: c.serviceName,
-		InstanceId:  c.instanceID,
-	})
-	if err != nil {
-		return err
+// Ejections returns the instance addresses outlier detection took out of
+// rotation, including half-open ones waiting for a probe
+func (c *Client) Ejections() []Ejection {
+	if c.outliers == nil {
+		return nil
 	}
+	return c.outliers.ejections()
+}
+
+// ListServices returns every service whose name starts with prefix, with
+// instance counts and distinct metadata values, following all result pages
+func (c *Client) ListServices(ctx context.Context, prefix string) ([]*voyagerv1.ServiceSummary, error) {
+	var services []*voyagerv1.ServiceSummary
+	req := &voyagerv1.ListServicesRequest{Prefix: prefix}
+	for {
+		resp, err := c.discoverySvc.ListServices(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, resp.Services...)
 
-	if !resp.Success {
-		return errors.New("deregistration failed: " + resp.Error)
+		if resp.NextPageToken == "" {
+			return services, nil
+		}
+		req = &voyagerv1.ListServicesRequest{Prefix: prefix, PageToken: resp.NextPageToken}
 	}
+}
 
-	return nil
+// Deregister removes every instance registered by the client from the
+// discovery service
+func (c *Client) Deregister() error {
+	registrations := c.Registrations()
+	if len(registrations) == 0 {
+		return errors.New("service not registered")
+	}
+
+	var errs []error
+	for _, r := range registrations {
+		if err := r.Deregister(); err != nil {
+			errs = append(errs, fmt.Errorf("instance %s: %w", r.instanceID, err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Close cleans up resources and stops background processes
 func (c *Client) Close() error {
 	c.stopHealthChecks()
 
+	if c.lastKnown != nil {
+		c.lastKnown.close()
+	}
+
 	if c.connectionPool != nil {
 		c.connectionPool.Close()
 	}
@@ -179,29 +337,191 @@ func (c *Client) Close() error {
 	return nil
 }
 
-// getServiceInstances retrieves service instances from cache or discovery service
-func (c *Client) getServiceInstances(ctx context.Context, serviceName string) ([]*voyagerv1.Registration, error) {
-	if cached, found := c.cache.Get(serviceName); found {
-		return cached.([]*voyagerv1.Registration), nil
+// getServiceInstances retrieves service instances matching a selector from
+// cache or discovery service. A cached full list is filtered locally. While
+// the discovery service is unreachable, the last fetched list is served past
+// its TTL and refreshed in the background.
+func (c *Client) getServiceInstances(ctx context.Context, serviceName string, callOpts *discoverOptions) ([]*voyagerv1.Registration, error) {
+	requirements := callOpts.selector
+	maxStaleness := c.options.MaxStaleness
+	if callOpts.maxStaleness > 0 {
+		maxStaleness = callOpts.maxStaleness
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
-	defer cancel()
+	// Lists fetched for the client balancer may lack the unhealthy instances
+	// a health-filtering strategy override of the call needs
+	_, clientFiltersHealth := c.balancer.(healthFilteringBalancer)
+	_, callFiltersHealth := c.balancerFor(callOpts).(healthFilteringBalancer)
 
-	resp, err := c.discoverySvc.Discover(ctx, &voyagerv1.ServiceQuery{
-		ServiceName: serviceName,
-		HealthyOnly: true,
-	})
+	key := selectorCacheKey(serviceName, requirements)
+	if !callOpts.bypassCache && (clientFiltersHealth || !callFiltersHealth) {
+		if cached, found := c.cachedInstances(serviceName, maxStaleness); found {
+			return selector.Filter(requirements, cached), nil
+		}
+		if cached, found := c.cachedInstances(key, maxStaleness); found {
+			return cached, nil
+		}
+	}
+
+	instances, err := c.fetchInstances(ctx, serviceName, callOpts)
 	if err != nil {
-		return nil, err
+		// Only outages are bridged, not rejected queries or cancelled calls
+		if c.lastKnown == nil || ctx.Err() != nil || !isOutlierFailure(err) {
+			return nil, err
+		}
+		stale, refresh, ok := c.lastKnown.serveStale(key, maxStaleness)
+		if !ok {
+			return nil, err
+		}
+		if refresh {
+			log.Printf("Discovery of %s failed, serving %d last known instances: %v", key, len(stale), err)
+			go c.refreshStale(key, serviceName, requirements)
+		}
+		staleServesCounter.WithLabelValues(serviceName).Inc()
+		return stale, nil
 	}
 
-	c.cache.Set(serviceName, resp.Instances, c.options.TTL)
-	return resp.Instances, nil
+	c.storeInstances(key, instances)
+	return instances, nil
+}
+
+// storeInstances caches an instance list and keeps it as the last known one
+func (c *Client) storeInstances(key string, instances []*voyagerv1.Registration) {
+	c.cache.Set(key, instances, c.options.TTL)
+	if c.lastKnown != nil {
+		c.lastKnown.store(key, instances)
+	}
+	if c.outliers != nil {
+		c.outliers.listChanged(key, instances)
+	}
 }
 
-// connectWithRetry establishes connection with retry logic
-func connectWithRetry(addr string, opts *Options) (*grpc.ClientConn, voyagerv1.DiscoveryClient, error) {
+// cachedInstances returns a cached instance list unless it is older than
+// maxAge. Entries are stored for the TTL, so their age follows from the
+// remaining time.
+func (c *Client) cachedInstances(key string, maxAge time.Duration) ([]*voyagerv1.Registration, bool) {
+	cached, expiration, found := c.cache.GetWithExpiration(key)
+	if !found {
+		return nil, false
+	}
+	if maxAge > 0 && !expiration.IsZero() && c.options.TTL-time.Until(expiration) > maxAge {
+		return nil, false
+	}
+	return cached.([]*voyagerv1.Registration), true
+}
+
+// fetchInstances asks the discovery service for instances matching the
+// selector of a call, retrying unreachable servers as often as the call
+// allows
+func (c *Client) fetchInstances(ctx context.Context, serviceName string, callOpts *discoverOptions) ([]*voyagerv1.Registration, error) {
+	timeout := c.options.Timeout
+	if callOpts.timeout > 0 {
+		timeout = callOpts.timeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	// Balancers that judge locality health need the unhealthy instances too
+	_, filtersHealth := c.balancerFor(callOpts).(healthFilteringBalancer)
+	query := &voyagerv1.ServiceQuery{
+		ServiceName: serviceName,
+		HealthyOnly: !filtersHealth,
+		Selector:    callOpts.selector,
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		resp, err := c.discoverySvc.Discover(attemptCtx, query)
+		cancel()
+		if err == nil {
+			return resp.Instances, nil
+		}
+		if attempt >= callOpts.retries || ctx.Err() != nil || !isOutlierFailure(err) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(callOpts.retryDelay):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// servingInstances drops instances registered with weight 0
+func servingInstances(instances []*voyagerv1.Registration) []*voyagerv1.Registration {
+	serving := make([]*voyagerv1.Registration, 0, len(instances))
+	for _, inst := range instances {
+		if InstanceWeight(inst) > 0 {
+			serving = append(serving, inst)
+		}
+	}
+	return serving
+}
+
+// healthyInstances drops instances the server marked SUSPECT or UNHEALTHY.
+// Watch events may put them into the cache; UNKNOWN comes from older servers.
+func healthyInstances(instances []*voyagerv1.Registration) []*voyagerv1.Registration {
+	healthy := make([]*voyagerv1.Registration, 0, len(instances))
+	for _, inst := range instances {
+		switch inst.Health {
+		case voyagerv1.HealthResponse_HEALTHY, voyagerv1.HealthResponse_UNKNOWN:
+			healthy = append(healthy, inst)
+		}
+	}
+	return healthy
+}
+
+// discoveryScheme is the resolver scheme of a discovery endpoint list
+const discoveryScheme = "voyager-discovery"
+
+// discoveryServiceConfig spreads discovery calls over every resolved server
+// and retries calls that hit one which became unreachable
+const discoveryServiceConfig = `{
+	"loadBalancingConfig": [{"round_robin": {}}],
+	"methodConfig": [{
+		"name": [{"service": "voyager.v1.Discovery"}],
+		"retryPolicy": {
+			"maxAttempts": 3,
+			"initialBackoff": "0.1s",
+			"maxBackoff": "1s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
+// discoveryEndpoints splits a comma-separated discovery address and appends
+// the endpoints given as options
+func discoveryEndpoints(discoveryAddr string, extra []string) []string {
+	var endpoints []string
+	for _, endpoint := range append(strings.Split(discoveryAddr, ","), extra...) {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// connectWithRetry establishes connection with retry logic. A single
+// endpoint is resolved by gRPC, so a DNS name may stand for several servers;
+// several endpoints are handed to gRPC as a static address list, each with
+// its host as the TLS server name.
+func connectWithRetry(endpoints []string, opts *Options) (*grpc.ClientConn, voyagerv1.DiscoveryClient, error) {
+	target := endpoints[0]
+	var resolverOpts []grpc.DialOption
+	if len(endpoints) > 1 {
+		addresses := make([]resolver.Address, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			addresses = append(addresses, resolver.Address{Addr: endpoint, ServerName: endpointHost(endpoint)})
+		}
+		r := manual.NewBuilderWithScheme(discoveryScheme)
+		r.InitialState(resolver.State{Addresses: addresses})
+		target = discoveryScheme + ":///"
+		resolverOpts = append(resolverOpts, grpc.WithResolvers(r))
+	}
+
 	for i := 0; i < opts.MaxRetries; i++ {
 		creds, credErr := getTransportCredentials(opts)
 		if credErr != nil {
@@ -210,13 +530,19 @@ func connectWithRetry(addr string, opts *Options) (*grpc.ClientConn, voyagerv1.D
 
 		dialOpts := []grpc.DialOption{
 			grpc.WithTransportCredentials(creds),
+			grpc.WithDefaultServiceConfig(discoveryServiceConfig),
+		}
+		dialOpts = append(dialOpts, resolverOpts...)
+
+		if creds := newTokenCredentials(opts); creds != nil {
+			dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(creds))
 		}
 
 		if opts.DialFunc != nil {
 			dialOpts = append(dialOpts, grpc.WithContextDialer(opts.DialFunc))
 		}
 
-		conn, err := grpc.NewClient(addr, dialOpts...)
+		conn, err := grpc.NewClient(target, dialOpts...)
 
 		if err == nil {
 			return conn, voyagerv1.NewDiscoveryClient(conn), nil
@@ -229,6 +555,15 @@ func connectWithRetry(addr string, opts *Options) (*grpc.ClientConn, voyagerv1.D
 	return nil, nil, fmt.Errorf("failed after %d attempts", opts.MaxRetries)
 }
 
+// endpointHost returns the host of a host:port endpoint
+func endpointHost(endpoint string) string {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	return host
+}
+
 // getTransportCredentials returns appropriate transport credentials
 func getTransportCredentials(opts *Options) (credentials.TransportCredentials, error) {
 	if opts.Insecure {
@@ -3,25 +3,39 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
 	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
 	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
 )
 
 // MockDiscoveryClient simulates the behavior of the discovery service
 type MockDiscoveryClient struct {
 	mock.Mock
+	sessions bool // serve Session; false simulates a server without it
 }
 
 func (m *MockDiscoveryClient) Register(
@@ -60,6 +74,113 @@ func (m *MockDiscoveryClient) Deregister(
 	return args.Get(0).(*voyagerv1.Response), args.Error(1)
 }
 
+func (m *MockDiscoveryClient) ListServices(
+	ctx context.Context,
+	req *voyagerv1.ListServicesRequest,
+	opts ...grpc.CallOption,
+) (*voyagerv1.ListServicesResponse, error) {
+	args := m.Called(ctx, req)
+	resp := args.Get(0)
+	if resp == nil {
+		return nil, args.Error(1)
+	}
+	return resp.(*voyagerv1.ListServicesResponse), args.Error(1)
+}
+
+func (m *MockDiscoveryClient) Watch(
+	ctx context.Context,
+	req *voyagerv1.ServiceQuery,
+	opts ...grpc.CallOption,
+) (voyagerv1.Discovery_WatchClient, error) {
+	args := m.Called(ctx, req)
+	stream := args.Get(0)
+	if stream == nil {
+		return nil, args.Error(1)
+	}
+	return stream.(voyagerv1.Discovery_WatchClient), args.Error(1)
+}
+
+func (m *MockDiscoveryClient) Session(
+	ctx context.Context,
+	opts ...grpc.CallOption,
+) (voyagerv1.Discovery_SessionClient, error) {
+	if !m.sessions {
+		return nil, status.Error(codes.Unimplemented, "unknown method Session")
+	}
+	args := m.Called(ctx)
+	stream := args.Get(0)
+	if stream == nil {
+		return nil, args.Error(1)
+	}
+	return stream.(voyagerv1.Discovery_SessionClient), args.Error(1)
+}
+
+// MockWatchClient replays a fixed sequence of watch events
+type MockWatchClient struct {
+	grpc.ClientStream
+	events chan *voyagerv1.ServiceEvent
+}
+
+func (m *MockWatchClient) Recv() (*voyagerv1.ServiceEvent, error) {
+	event, ok := <-m.events
+	if !ok {
+		return nil, status.Error(codes.Canceled, "stream closed")
+	}
+	return event, nil
+}
+
+// MockSessionClient records keepalives and ends the stream on demand
+type MockSessionClient struct {
+	grpc.ClientStream
+	ctx  context.Context
+	sent chan *voyagerv1.SessionRequest
+	end  chan error
+}
+
+func newMockSessionClient() *MockSessionClient {
+	return &MockSessionClient{
+		sent: make(chan *voyagerv1.SessionRequest, 4),
+		end:  make(chan error, 1),
+	}
+}
+
+// bind attaches the stream to the context of the Session call
+func (m *MockSessionClient) bind(args mock.Arguments) {
+	m.ctx = args.Get(0).(context.Context)
+}
+
+func (m *MockSessionClient) Send(req *voyagerv1.SessionRequest) error {
+	select {
+	case m.sent <- req:
+		return nil
+	case <-m.ctx.Done():
+		return io.EOF
+	}
+}
+
+func (m *MockSessionClient) Recv() (*voyagerv1.SessionResponse, error) {
+	select {
+	case err := <-m.end:
+		return nil, err
+	case <-m.ctx.Done():
+		return nil, status.Error(codes.Canceled, "stream closed")
+	}
+}
+
+// testRegistration adds an instance at localhost:8080 to a client without
+// registering it
+func testRegistration(cli *Client, serviceName, instanceID string) *Registration {
+	r := &Registration{
+		client:      cli,
+		serviceName: serviceName,
+		instanceID:  instanceID,
+		address:     "localhost",
+		port:        8080,
+	}
+	cli.registrations = map[string]*Registration{instanceID: r}
+	return r
+}
+
 // MockConnectionPool simulates connection pool behavior
 type MockConnectionPool struct {
 	mock.Mock
@@ -105,8 +226,28 @@ func TestClient_Register(t *testing.T) {
 				req.Port == 8080
 		})).Return(&voyagerv1.Response{Success: true}, nil)
 
-		err := cli.Register("test-service", "localhost", 8080, nil)
+		_, err := cli.Register("test-service", "localhost", 8080, nil)
+		assert.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Registration with weight", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		cli := &Client{
+			discoverySvc: mockClient,
+			options: &Options{
+				TTL: 30 * time.Second,
+			},
+			cache: cache.New(30*time.Second, 10*time.Minute),
+		}
+
+		mockClient.On("Register", mock.Anything, mock.MatchedBy(func(req *voyagerv1.Registration) bool {
+			return req.Weight != nil && *req.Weight == 0
+		})).Return(&voyagerv1.Response{Success: true}, nil)
+
+		_, err := cli.Register("test-service", "localhost", 8080, nil, WithWeight(0))
 		assert.NoError(t, err)
+		cli.stopHealthChecks()
 		mockClient.AssertExpectations(t)
 	})
 
@@ -123,7 +264,7 @@ func TestClient_Register(t *testing.T) {
 			nil,
 		)
 
-		err := cli.Register("test-service", "localhost", 8080, nil)
+		_, err := cli.Register("test-service", "localhost", 8080, nil)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "registration failed")
 	})
@@ -197,6 +338,109 @@ func TestClient_Discover(t *testing.T) {
 		assert.Contains(t, err.Error(), "no instances available")
 	})
 
+	t.Run("Unhealthy instances are skipped", func(t *testing.T) {
+		mockPool := new(MockConnectionPool)
+		cli := &Client{
+			options: &Options{
+				TTL: 30 * time.Second,
+			},
+			connectionPool: mockPool,
+			balancer:       newRoundRobinBalancer(),
+			cache:          cache.New(30*time.Second, 10*time.Minute),
+		}
+
+		// A watch may leave suspect and unhealthy instances in the cache
+		cli.cache.Set("test-service", []*voyagerv1.Registration{
+			{ServiceName: "test-service", InstanceId: "1", Address: "10.0.0.1", Port: 8080, Health: voyagerv1.HealthResponse_SUSPECT},
+			{ServiceName: "test-service", InstanceId: "2", Address: "10.0.0.2", Port: 8080, Health: voyagerv1.HealthResponse_HEALTHY},
+			{ServiceName: "test-service", InstanceId: "3", Address: "10.0.0.3", Port: 8080, Health: voyagerv1.HealthResponse_UNHEALTHY},
+		}, 30*time.Second)
+
+		conn, err := grpc.NewClient(
+			"passthrough:///localhost:0",
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		assert.NoError(t, err)
+		defer func() {
+			if closeErr := conn.Close(); closeErr != nil {
+				t.Logf("failed to close connection: %v", closeErr)
+			}
+		}()
+
+		mockPool.On("Get", mock.Anything, "10.0.0.2:8080").Return(conn, nil)
+
+		for i := 0; i < 3; i++ {
+			_, err := cli.Discover(context.Background(), "test-service")
+			assert.NoError(t, err)
+		}
+		mockPool.AssertNumberOfCalls(t, "Get", 3)
+	})
+
+	t.Run("Selector", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockPool := new(MockConnectionPool)
+		cli := &Client{
+			discoverySvc: mockClient,
+			options: &Options{
+				TTL: 30 * time.Second,
+			},
+			connectionPool: mockPool,
+			balancer:       newRoundRobinBalancer(),
+			cache:          cache.New(30*time.Second, 10*time.Minute),
+		}
+
+		production := &voyagerv1.Registration{
+			ServiceName: "test-service",
+			InstanceId:  "1",
+			Address:     "10.0.0.1",
+			Port:        8080,
+			Metadata:    map[string]string{"environment": "production"},
+		}
+		staging := &voyagerv1.Registration{
+			ServiceName: "test-service",
+			InstanceId:  "2",
+			Address:     "10.0.0.2",
+			Port:        8080,
+			Metadata:    map[string]string{"environment": "staging"},
+		}
+
+		// The selector is evaluated by the server
+		mockClient.On("Discover", mock.Anything, mock.MatchedBy(func(q *voyagerv1.ServiceQuery) bool {
+			return len(q.Selector) == 1 && q.Selector[0].Key == "environment" &&
+				q.Selector[0].Operator == voyagerv1.LabelRequirement_EQUALS &&
+				q.Selector[0].Values[0] == "staging"
+		})).Return(&voyagerv1.ServiceList{Instances: []*voyagerv1.Registration{staging}}, nil).Once()
+
+		conn, err := grpc.NewClient(
+			"passthrough:///localhost:0",
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		assert.NoError(t, err)
+		defer func() {
+			if closeErr := conn.Close(); closeErr != nil {
+				t.Logf("failed to close connection: %v", closeErr)
+			}
+		}()
+		mockPool.On("Get", mock.Anything, "10.0.0.2:8080").Return(conn, nil)
+		mockPool.On("Get", mock.Anything, "10.0.0.1:8080").Return(conn, nil)
+
+		_, err = cli.Discover(context.Background(), "test-service", WithLabelSelector("environment=staging"))
+		assert.NoError(t, err)
+		// The filtered list is cached per selector
+		_, err = cli.Discover(context.Background(), "test-service", WithSelector(LabelEquals("environment", "staging")))
+		assert.NoError(t, err)
+		mockClient.AssertExpectations(t)
+
+		// A cached full list, e.g. kept by Watch, is filtered locally
+		cli.cache.Set("test-service", []*voyagerv1.Registration{production, staging}, 30*time.Second)
+		_, err = cli.Discover(context.Background(), "test-service", WithSelector(LabelNotEquals("environment", "staging")))
+		assert.NoError(t, err)
+		mockPool.AssertCalled(t, "Get", mock.Anything, "10.0.0.1:8080")
+
+		_, err = cli.Discover(context.Background(), "test-service", WithLabelSelector("version in (1.2"))
+		assert.Error(t, err)
+	})
+
 	t.Run("Discovery error", func(t *testing.T) {
 		mockClient := new(MockDiscoveryClient)
 		cli := &Client{
@@ -231,10 +475,9 @@ func TestClient_HealthCheck(t *testing.T) {
 			options: &Options{
 				HealthCheckInterval: 100 * time.Millisecond,
 			},
-			serviceName: "test-service",
-			instanceID:  "test-instance",
-			cache:       cache.New(30*time.Second, 10*time.Minute),
+			cache: cache.New(30*time.Second, 10*time.Minute),
 		}
+		testRegistration(cli, "test-service", "test-instance")
 
 		healthReq := &voyagerv1.HealthRequest{
 			ServiceName: "test-service",
@@ -260,12 +503,9 @@ func TestClient_HealthCheck(t *testing.T) {
 			options: &Options{
 				HealthCheckInterval: 100 * time.Millisecond,
 			},
-			serviceName: "test-service",
-			instanceID:  "test-instance",
-			address:     "localhost",
-			port:        8080,
-			cache:       cache.New(30*time.Second, 10*time.Minute),
+			cache: cache.New(30*time.Second, 10*time.Minute),
 		}
+		testRegistration(cli, "test-service", "test-instance")
 
 		healthReq := &voyagerv1.HealthRequest{
 			ServiceName: "test-service",
@@ -303,16 +543,44 @@ func TestClient_HealthCheck(t *testing.T) {
 	})
 }
 
+// TestClient_SetHealthStatus tests self-reported health
+func TestClient_SetHealthStatus(t *testing.T) {
+	mockClient := new(MockDiscoveryClient)
+	cli := &Client{
+		discoverySvc: mockClient,
+		options: &Options{
+			HealthCheckInterval: time.Hour,
+		},
+		cache: cache.New(30*time.Second, 10*time.Minute),
+	}
+	testRegistration(cli, "test-service", "test-instance")
+
+	mockClient.On("HealthCheck", mock.Anything, &voyagerv1.HealthRequest{
+		ServiceName: "test-service",
+		InstanceId:  "test-instance",
+		Status:      voyagerv1.HealthResponse_UNHEALTHY,
+	}).Return(
+		&voyagerv1.HealthResponse{Status: voyagerv1.HealthResponse_UNHEALTHY},
+		nil,
+	).Once()
+
+	cli.startHealthChecks()
+	defer cli.stopHealthChecks()
+
+	// The new state is reported without waiting for the next interval
+	cli.SetHealthStatus(voyagerv1.HealthResponse_UNHEALTHY)
+	mockClient.AssertExpectations(t)
+}
+
 // TestClient_Deregister tests service deregistration functionality
 func TestClient_Deregister(t *testing.T) {
 	t.Run("Successful deregistration", func(t *testing.T) {
 		mockClient := new(MockDiscoveryClient)
 		cli := &Client{
 			discoverySvc: mockClient,
-			serviceName:  "test-service",
-			instanceID:   "test-instance",
 			cache:        cache.New(30*time.Second, 10*time.Minute),
 		}
+		testRegistration(cli, "test-service", "test-instance")
 
 		deregReq := &voyagerv1.InstanceID{
 			ServiceName: "test-service",
@@ -330,10 +598,9 @@ func TestClient_Deregister(t *testing.T) {
 		mockClient := new(MockDiscoveryClient)
 		cli := &Client{
 			discoverySvc: mockClient,
-			serviceName:  "test-service",
-			instanceID:   "test-instance",
 			cache:        cache.New(30*time.Second, 10*time.Minute),
 		}
+		testRegistration(cli, "test-service", "test-instance")
 
 		deregReq := &voyagerv1.InstanceID{
 			ServiceName: "test-service",
@@ -358,12 +625,11 @@ func TestClient_Close(t *testing.T) {
 		mockClient := new(MockDiscoveryClient)
 		cli := &Client{
 			discoverySvc:   mockClient,
-			serviceName:    "test-service",
-			instanceID:     "test-instance",
 			connectionPool: NewConnectionPool(&Options{ConnectionTimeout: 100 * time.Millisecond}),
 			cache:          cache.New(30*time.Second, 10*time.Minute),
 			options:        &Options{HealthCheckInterval: 100 * time.Millisecond},
 		}
+		testRegistration(cli, "test-service", "test-instance")
 
 		cli.startHealthChecks()
 		time.Sleep(10 * time.Millisecond)
@@ -425,6 +691,229 @@ func TestClient_ConnectionPool(t *testing.T) {
 		count = pool.ConnectionCount(address)
 		assert.Equal(t, int64(0), count)
 	})
+
+	t.Run("Latency tracking", func(t *testing.T) {
+		lis := bufconn.Listen(1024 * 1024)
+		defer func() {
+			if err := lis.Close(); err != nil {
+				t.Logf("failed to close listener: %v", err)
+			}
+		}()
+
+		// Every method takes 50ms
+		srv := grpc.NewServer(grpc.UnknownServiceHandler(func(any, grpc.ServerStream) error {
+			time.Sleep(50 * time.Millisecond)
+			return status.Error(codes.Unimplemented, "unknown method")
+		}))
+		go func() {
+			if err := srv.Serve(lis); err != nil {
+				log.Printf("Test server error: %v", err)
+			}
+		}()
+		defer srv.Stop()
+
+		pool := NewConnectionPool(&Options{
+			Insecure: true,
+			DialFunc: func(ctx context.Context, address string) (net.Conn, error) {
+				return lis.Dial()
+			},
+		})
+		defer pool.Close()
+
+		address := "passthrough:///bufnet"
+		conn, err := pool.Get(context.Background(), address)
+		require.NoError(t, err)
+		err = conn.Invoke(context.Background(), "/test.Service/Method", &voyagerv1.InstanceID{}, &voyagerv1.Response{})
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+		// The slow call replaced the initial estimate and nothing is pending
+		assert.GreaterOrEqual(t, pool.latency.cost(address), float64(45*time.Millisecond))
+		assert.Equal(t, float64(initialLatency), pool.latency.cost("unknown:8080"))
+
+		// One slow instance loses every comparison
+		started := pool.latency.start("slow:8080")
+		pool.latency.finish("slow:8080", started.Add(-500*time.Millisecond))
+
+		balancer := newPeakEWMABalancer(pool)
+		instances := []*voyagerv1.Registration{
+			{InstanceId: "slow", Address: "slow", Port: 8080},
+			{InstanceId: "fast", Address: "fast", Port: 8080},
+		}
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, "fast", balancer.Select("test-service", instances).InstanceId)
+		}
+
+		// Enough outstanding requests outweigh the latency difference
+		for i := 0; i < 20; i++ {
+			pool.latency.start("fast:8080")
+		}
+		assert.Equal(t, "slow", balancer.Select("test-service", instances).InstanceId)
+	})
+}
+
+// TestClient_OutlierDetection tests ejection, half-open probing and the
+// max ejection percent
+func TestClient_OutlierDetection(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	instances := []*voyagerv1.Registration{
+		{InstanceId: "instance-1", Address: "host1", Port: 8080},
+		{InstanceId: "instance-2", Address: "host2", Port: 8080},
+	}
+	ids := func(instances []*voyagerv1.Registration) []string {
+		var ids []string
+		for _, inst := range instances {
+			ids = append(ids, inst.InstanceId)
+		}
+		return ids
+	}
+
+	t.Run("Consecutive failures", func(t *testing.T) {
+		detector := newOutlierDetector(&OutlierDetection{
+			ConsecutiveFailures: 3,
+			BaseEjectionTime:    50 * time.Millisecond,
+			MaxEjectionTime:     time.Second,
+		})
+		cli := &Client{outliers: detector}
+
+		// Errors caused by the request do not count
+		detector.record("host1:8080", status.Error(codes.InvalidArgument, "bad request"))
+		for i := 0; i < 3; i++ {
+			detector.record("host1:8080", unavailable)
+		}
+		ejections := cli.Ejections()
+		require.Len(t, ejections, 1)
+		assert.Equal(t, "host1:8080", ejections[0].Address)
+		assert.Equal(t, []string{"instance-2"}, ids(detector.filter(instances)))
+
+		// At most half of the instances are ejected
+		for i := 0; i < 3; i++ {
+			detector.record("host2:8080", unavailable)
+		}
+		assert.Len(t, cli.Ejections(), 2)
+		assert.Len(t, detector.filter(instances), 1)
+
+		// After the ejection a single probe is let through
+		time.Sleep(60 * time.Millisecond)
+		assert.True(t, cli.Ejections()[0].HalfOpen)
+		assert.Len(t, detector.filter(instances), 2)
+		detector.start("host1:8080")
+		assert.Equal(t, []string{"instance-2"}, ids(detector.filter(instances)))
+
+		// A failed probe doubles the ejection
+		detector.record("host1:8080", unavailable)
+		ejection := cli.Ejections()[0]
+		assert.False(t, ejection.HalfOpen)
+		assert.Equal(t, 2, ejection.Ejections)
+		assert.InDelta(t, float64(100*time.Millisecond), float64(time.Until(ejection.Until)), float64(20*time.Millisecond))
+
+		// A successful probe returns the address to rotation
+		time.Sleep(110 * time.Millisecond)
+		detector.start("host1:8080")
+		detector.record("host1:8080", nil)
+		detector.start("host2:8080")
+		detector.record("host2:8080", nil)
+		assert.Empty(t, cli.Ejections())
+		assert.Len(t, detector.filter(instances), 2)
+	})
+
+	t.Run("Probe timeout", func(t *testing.T) {
+		detector := newOutlierDetector(&OutlierDetection{
+			ConsecutiveFailures: 1,
+			BaseEjectionTime:    10 * time.Millisecond,
+			ProbeTimeout:        50 * time.Millisecond,
+		})
+		detector.record("host1:8080", unavailable)
+		time.Sleep(20 * time.Millisecond)
+
+		// A probe that never finishes keeps the address out until it times out
+		detector.start("host1:8080")
+		assert.Equal(t, []string{"instance-2"}, ids(detector.filter(instances)))
+		time.Sleep(60 * time.Millisecond)
+		assert.Len(t, detector.filter(instances), 2)
+	})
+
+	t.Run("Removed instances", func(t *testing.T) {
+		detector := newOutlierDetector(&OutlierDetection{ConsecutiveFailures: 1})
+		detector.listChanged("payment-service", instances)
+		detector.listChanged("payment-service|version=v1", instances[:1])
+		ejected := testutil.ToFloat64(ejectedAddressesGauge)
+
+		detector.record("host1:8080", unavailable)
+		detector.record("host2:8080", nil)
+		assert.Equal(t, ejected+1, testutil.ToFloat64(ejectedAddressesGauge))
+
+		// State is kept while any list holds the address
+		detector.listChanged("payment-service", nil)
+		assert.Len(t, detector.ejections(), 1)
+		assert.NotContains(t, detector.addresses, "host2:8080")
+
+		detector.listChanged("payment-service|version=v1", nil)
+		assert.Empty(t, detector.ejections())
+		assert.Empty(t, detector.addresses)
+		assert.Equal(t, ejected, testutil.ToFloat64(ejectedAddressesGauge))
+
+		// Late results of removed addresses are not tracked again
+		detector.listChanged("order-service", []*voyagerv1.Registration{
+			{InstanceId: "instance-3", Address: "host3", Port: 8080},
+		})
+		detector.record("host1:8080", unavailable)
+		assert.Empty(t, detector.addresses)
+	})
+
+	t.Run("Failure rate", func(t *testing.T) {
+		detector := newOutlierDetector(&OutlierDetection{
+			ConsecutiveFailures: 100,
+			FailureRate:         0.5,
+			MinimumRequests:     4,
+		})
+		for i := 0; i < 3; i++ {
+			detector.record("host1:8080", unavailable)
+			detector.record("host1:8080", nil)
+		}
+		ejections := detector.ejections()
+		require.Len(t, ejections, 1)
+		assert.Equal(t, "host1:8080", ejections[0].Address)
+	})
+
+	t.Run("Pooled connections", func(t *testing.T) {
+		lis := bufconn.Listen(1024 * 1024)
+		defer func() {
+			if err := lis.Close(); err != nil {
+				t.Logf("failed to close listener: %v", err)
+			}
+		}()
+
+		srv := grpc.NewServer(grpc.UnknownServiceHandler(func(any, grpc.ServerStream) error {
+			return status.Error(codes.Unavailable, "overloaded")
+		}))
+		go func() {
+			if err := srv.Serve(lis); err != nil {
+				log.Printf("Test server error: %v", err)
+			}
+		}()
+		defer srv.Stop()
+
+		pool := NewConnectionPool(&Options{
+			Insecure:         true,
+			OutlierDetection: &OutlierDetection{ConsecutiveFailures: 2},
+			DialFunc: func(ctx context.Context, address string) (net.Conn, error) {
+				return lis.Dial()
+			},
+		})
+		defer pool.Close()
+
+		address := "passthrough:///bufnet"
+		conn, err := pool.Get(context.Background(), address)
+		require.NoError(t, err)
+		for i := 0; i < 2; i++ {
+			err = conn.Invoke(context.Background(), "/test.Service/Method", &voyagerv1.InstanceID{}, &voyagerv1.Response{})
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+		}
+
+		ejections := pool.outliers.ejections()
+		require.Len(t, ejections, 1)
+		assert.Equal(t, address, ejections[0].Address)
+	})
 }
 
 // TestClient_LoadBalancing tests load balancing strategies
@@ -497,48 +986,1406 @@ func TestClient_LoadBalancing(t *testing.T) {
 		mockClient.AssertExpectations(t)
 		mockPool.AssertExpectations(t)
 	})
-}
 
-// TestClient_Reregister tests service re-registration after health check failures
-func TestClient_Reregister(t *testing.T) {
-	t.Run("Re-register after health check failure", func(t *testing.T) {
+	t.Run("Weighted strategy", func(t *testing.T) {
 		mockClient := new(MockDiscoveryClient)
+		mockPool := new(MockConnectionPool)
+
 		cli := &Client{
 			discoverySvc: mockClient,
 			options: &Options{
-				HealthCheckInterval: 100 * time.Millisecond,
+				TTL: 30 * time.Second,
 			},
-			serviceName: "test-service",
-			instanceID:  "test-instance",
-			address:     "localhost",
-			port:        8080,
-			cache:       cache.New(30*time.Second, 10*time.Minute),
+			connectionPool: mockPool,
+			balancer:       newWeightedBalancer(),
+			cache:          cache.New(30*time.Second, 10*time.Minute),
 		}
 
-		// First health check fails
-		mockClient.On("HealthCheck", mock.Anything, mock.Anything).Return(
-			(*voyagerv1.HealthResponse)(nil),
-			status.Error(codes.Unavailable, "server unavailable"),
+		heavy, light, warming := uint32(3), uint32(1), uint32(0)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: []*voyagerv1.Registration{
+				{InstanceId: "instance-1", Address: "host1", Port: 8080, Weight: &heavy},
+				{InstanceId: "instance-2", Address: "host2", Port: 8080, Weight: &light},
+				{InstanceId: "instance-3", Address: "host3", Port: 8080, Weight: &warming},
+			}},
+			nil,
 		).Once()
 
-		// Re-registration succeeds
-		mockClient.On("Register", mock.Anything, mock.MatchedBy(func(req *voyagerv1.Registration) bool {
-			return req.ServiceName == "test-service" &&
-				req.InstanceId == "test-instance" &&
-				req.Address == "localhost" &&
-				req.Port == 8080
-		})).Return(&voyagerv1.Response{Success: true}, nil)
-
-		// Subsequent health checks succeed
-		mockClient.On("HealthCheck", mock.Anything, mock.Anything).Return(
-			&voyagerv1.HealthResponse{Status: voyagerv1.HealthResponse_HEALTHY},
-			nil,
+		conn, _ := grpc.NewClient(
+			"passthrough:///localhost:0",
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
 		)
+		defer func() {
+			if err := conn.Close(); err != nil {
+				t.Logf("failed to close conn: %v", err)
+			}
+		}()
 
-		cli.startHealthChecks()
-		time.Sleep(250 * time.Millisecond)
-		cli.stopHealthChecks()
+		// Traffic follows the 3:1 weights and skips the weight 0 instance
+		var picks []string
+		mockPool.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			picks = append(picks, args.String(1))
+		}).Return(conn, nil)
+
+		for i := 0; i < 8; i++ {
+			_, err := cli.Discover(context.Background(), "test-service")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []string{
+			"host1:8080", "host1:8080", "host2:8080", "host1:8080",
+			"host1:8080", "host1:8080", "host2:8080", "host1:8080",
+		}, picks)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Locality-aware strategy", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockPool := new(MockConnectionPool)
+
+		balancer := newLocalityBalancer("eu-west", "eu-west-1a", 0.5)
+		cli := &Client{
+			discoverySvc: mockClient,
+			options: &Options{
+				TTL: 30 * time.Second,
+			},
+			connectionPool: mockPool,
+			balancer:       balancer,
+			cache:          cache.New(30*time.Second, 10*time.Minute),
+		}
+
+		local := &voyagerv1.Locality{Region: "eu-west", Zone: "eu-west-1a"}
+		instances := []*voyagerv1.Registration{
+			{InstanceId: "instance-1", Address: "host1", Port: 8080, Locality: local,
+				Health: voyagerv1.HealthResponse_HEALTHY},
+			{InstanceId: "instance-2", Address: "host2", Port: 8080, Locality: local,
+				Health: voyagerv1.HealthResponse_UNHEALTHY},
+			{InstanceId: "instance-3", Address: "host3", Port: 8080,
+				Locality: &voyagerv1.Locality{Region: "eu-west", Zone: "eu-west-1b"},
+				Health:   voyagerv1.HealthResponse_HEALTHY},
+			{InstanceId: "instance-4", Address: "host4", Port: 8080,
+				Locality: &voyagerv1.Locality{Region: "us-east", Zone: "us-east-1a"},
+				Health:   voyagerv1.HealthResponse_HEALTHY},
+		}
+
+		// Unhealthy instances are fetched to measure locality health
+		mockClient.On("Discover", mock.Anything, &voyagerv1.ServiceQuery{
+			ServiceName: "test-service",
+		}).Return(&voyagerv1.ServiceList{Instances: instances}, nil).Once()
+
+		conn, _ := grpc.NewClient(
+			"passthrough:///localhost:0",
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		defer func() {
+			if err := conn.Close(); err != nil {
+				t.Logf("failed to close conn: %v", err)
+			}
+		}()
+		mockPool.On("Get", mock.Anything, "host1:8080").Return(conn, nil).Times(3)
+
+		// Half of the local zone is healthy, which is enough to stay there
+		for i := 0; i < 3; i++ {
+			_, err := cli.Discover(context.Background(), "test-service")
+			require.NoError(t, err)
+		}
+		mockClient.AssertExpectations(t)
+		mockPool.AssertExpectations(t)
+
+		// Without a healthy local instance one of three in the region is too
+		// little, so traffic spills over to every healthy instance
+		instances[0].Health = voyagerv1.HealthResponse_UNHEALTHY
+		picked := make(map[string]bool)
+		for i := 0; i < 4; i++ {
+			picked[balancer.Select("test-service", instances).InstanceId] = true
+		}
+		assert.Equal(t, map[string]bool{"instance-3": true, "instance-4": true}, picked)
+
+		// A lower threshold keeps traffic in the region
+		regional := newLocalityBalancer("eu-west", "eu-west-1a", 0.3)
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, "instance-3", regional.Select("test-service", instances).InstanceId)
+		}
+
+		// A zone without a region is matched on its name
+		zoneOnly := newLocalityBalancer("", "eu-west-1b", 0.5)
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, "instance-3", zoneOnly.Select("test-service", instances).InstanceId)
+		}
+	})
+
+	t.Run("Consistent hash strategy", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockPool := new(MockConnectionPool)
+
+		balancer := newRingHashBalancer()
+		cli := &Client{
+			discoverySvc: mockClient,
+			options: &Options{
+				TTL: 30 * time.Second,
+			},
+			connectionPool: mockPool,
+			balancer:       balancer,
+			cache:          cache.New(30*time.Second, 10*time.Minute),
+		}
+
+		var instances []*voyagerv1.Registration
+		for i := 1; i <= 5; i++ {
+			instances = append(instances, &voyagerv1.Registration{
+				InstanceId: fmt.Sprintf("instance-%d", i),
+				Address:    fmt.Sprintf("host%d", i),
+				Port:       8080,
+			})
+		}
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: instances}, nil,
+		).Once()
+
+		conn, _ := grpc.NewClient(
+			"passthrough:///localhost:0",
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		defer func() {
+			if err := conn.Close(); err != nil {
+				t.Logf("failed to close conn: %v", err)
+			}
+		}()
+		var picks []string
+		mockPool.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			picks = append(picks, args.String(1))
+		}).Return(conn, nil)
+
+		// The same key keeps reaching the same instance
+		for i := 0; i < 3; i++ {
+			_, err := cli.Discover(context.Background(), "test-service", WithHashKey("user-42"))
+			require.NoError(t, err)
+		}
+		require.Len(t, picks, 3)
+		assert.Equal(t, picks[0], picks[1])
+		assert.Equal(t, picks[0], picks[2])
+
+		// Lists filtered from the same cache entry reuse the ring
+		built := balancer.rings["test-service"]
+		_, err := cli.Discover(context.Background(), "test-service")
+		require.NoError(t, err)
+		assert.Same(t, built, balancer.rings["test-service"])
+
+		owners := func(instances []*voyagerv1.Registration) map[string]string {
+			owner := make(map[string]string)
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("user-%d", i)
+				owner[key] = balancer.SelectKey("test-service", key, instances).InstanceId
+			}
+			return owner
+		}
+		before := owners(instances)
+
+		// Only the keys of a removed instance move
+		after := owners(instances[1:])
+		for key, owner := range before {
+			if owner != "instance-1" {
+				assert.Equal(t, owner, after[key])
+			}
+		}
+
+		// A new instance only takes keys, about a sixth of them
+		grown := append(append([]*voyagerv1.Registration{}, instances...), &voyagerv1.Registration{
+			InstanceId: "instance-6", Address: "host6", Port: 8080,
+		})
+		moved := 0
+		for key, owner := range owners(grown) {
+			if owner != before[key] {
+				assert.Equal(t, "instance-6", owner)
+				moved++
+			}
+		}
+		assert.InDelta(t, 1000/6, moved, 100)
+
+		// Huge weights are scaled down to a bounded ring that still holds
+		// every instance in proportion
+		heavy := []*voyagerv1.Registration{
+			{InstanceId: "heavy-1", Weight: proto.Uint32(math.MaxUint32)},
+			{InstanceId: "heavy-2", Weight: proto.Uint32(math.MaxUint32 / 2)},
+			{InstanceId: "light", Weight: proto.Uint32(1)},
+		}
+		ring := buildHashRing(heavy)
+		assert.LessOrEqual(t, len(ring.points), maxRingSize+len(heavy))
+		points := make(map[string]int)
+		for _, point := range ring.points {
+			points[point.instance.InstanceId]++
+		}
+		assert.Equal(t, 1, points["light"])
+		assert.InDelta(t, 2, float64(points["heavy-1"])/float64(points["heavy-2"]), 0.01)
+	})
+}
+
+// TestClient_Watch tests that watch events keep the discovery cache current
+func TestClient_Watch(t *testing.T) {
+	mockClient := new(MockDiscoveryClient)
+	cli := &Client{
+		discoverySvc: mockClient,
+		options: &Options{
+			TTL:        30 * time.Second,
+			RetryDelay: 10 * time.Millisecond,
+		},
+		cache: cache.New(30*time.Second, 10*time.Minute),
+	}
+
+	instance1 := &voyagerv1.Registration{ServiceName: "test-service", InstanceId: "instance-1", Address: "host1", Port: 8080}
+	instance2 := &voyagerv1.Registration{ServiceName: "test-service", InstanceId: "instance-2", Address: "host2", Port: 8080}
+
+	stream := &MockWatchClient{events: make(chan *voyagerv1.ServiceEvent, 4)}
+	stream.events <- &voyagerv1.ServiceEvent{
+		Type:      voyagerv1.ServiceEvent_SNAPSHOT,
+		Instances: []*voyagerv1.Registration{instance1, instance2},
+		Revision:  10,
+	}
+	stream.events <- &voyagerv1.ServiceEvent{
+		Type:     voyagerv1.ServiceEvent_REMOVED,
+		Instance: instance1,
+		Revision: 11,
+	}
+	close(stream.events)
+
+	resumed := &MockWatchClient{events: make(chan *voyagerv1.ServiceEvent)}
+	defer close(resumed.events)
+
+	mockClient.On("Watch", mock.Anything, &voyagerv1.ServiceQuery{ServiceName: "test-service"}).Return(stream, nil).Once()
+	resumedCh := make(chan struct{})
+	mockClient.On("Watch", mock.Anything, &voyagerv1.ServiceQuery{
+		ServiceName:    "test-service",
+		ResumeRevision: 11,
+	}).Run(func(mock.Arguments) { close(resumedCh) }).Return(resumed, nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := cli.Watch(ctx, "test-service")
+	assert.NoError(t, err)
+
+	snapshot := <-events
+	assert.Equal(t, voyagerv1.ServiceEvent_SNAPSHOT, snapshot.Type)
+	removed := <-events
+	assert.Equal(t, voyagerv1.ServiceEvent_REMOVED, removed.Type)
+
+	cached, found := cli.cache.Get("test-service")
+	assert.True(t, found)
+	assert.Equal(t, []*voyagerv1.Registration{instance2}, cached)
+
+	// The broken stream is resumed from the last received revision
+	select {
+	case <-resumedCh:
+	case <-time.After(time.Second):
+		t.Fatal("Watch was not resumed")
+	}
+	mockClient.AssertExpectations(t)
+}
+
+// fakeResolverConn records resolver updates
+type fakeResolverConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+	errs   chan error
+}
+
+func (f *fakeResolverConn) UpdateState(state resolver.State) error {
+	f.states <- state
+	return nil
+}
+
+func (f *fakeResolverConn) ReportError(err error) {
+	f.errs <- err
+}
+
+// TestClient_ListServices tests that all catalog pages are collected
+func TestClient_ListServices(t *testing.T) {
+	mockClient := new(MockDiscoveryClient)
+	cli := &Client{discoverySvc: mockClient}
+
+	mockClient.On("ListServices", mock.Anything, &voyagerv1.ListServicesRequest{Prefix: "pay"}).
+		Return(&voyagerv1.ListServicesResponse{
+			Services:      []*voyagerv1.ServiceSummary{{Name: "payment-service", InstanceCount: 2}},
+			NextPageToken: "next",
+		}, nil).Once()
+	mockClient.On("ListServices", mock.Anything, &voyagerv1.ListServicesRequest{Prefix: "pay", PageToken: "next"}).
+		Return(&voyagerv1.ListServicesResponse{
+			Services: []*voyagerv1.ServiceSummary{{Name: "payout-service", InstanceCount: 1}},
+		}, nil).Once()
+
+	services, err := cli.ListServices(context.Background(), "pay")
+	assert.NoError(t, err)
+	assert.Len(t, services, 2)
+	assert.Equal(t, "payout-service", services[1].Name)
+	mockClient.AssertExpectations(t)
+
+	t.Run("Error", func(t *testing.T) {
+		mockClient.On("ListServices", mock.Anything, &voyagerv1.ListServicesRequest{Prefix: "broken"}).
+			Return(nil, status.Error(codes.Unavailable, "unavailable")).Once()
+
+		_, err := cli.ListServices(context.Background(), "broken")
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+// TestClient_Resolver tests that the voyager resolver follows watch events
+func TestClient_Resolver(t *testing.T) {
+	mockClient := new(MockDiscoveryClient)
+	cli := &Client{
+		discoverySvc: mockClient,
+		options: &Options{
+			TTL:        30 * time.Second,
+			RetryDelay: 10 * time.Millisecond,
+		},
+		cache: cache.New(30*time.Second, 10*time.Minute),
+	}
+
+	production1 := &voyagerv1.Registration{
+		ServiceName: "test-service",
+		InstanceId:  "instance-1",
+		Address:     "10.0.0.1",
+		Port:        8080,
+		Metadata:    map[string]string{"environment": "production"},
+		Health:      voyagerv1.HealthResponse_HEALTHY,
+	}
+	production2 := &voyagerv1.Registration{
+		ServiceName: "test-service",
+		InstanceId:  "instance-2",
+		Address:     "10.0.0.2",
+		Port:        8080,
+		Metadata:    map[string]string{"environment": "production"},
+		Health:      voyagerv1.HealthResponse_HEALTHY,
+	}
+	staging := &voyagerv1.Registration{
+		ServiceName: "test-service",
+		InstanceId:  "instance-3",
+		Address:     "10.0.0.3",
+		Port:        8080,
+		Metadata:    map[string]string{"environment": "staging"},
+		Health:      voyagerv1.HealthResponse_HEALTHY,
+	}
+	suspect := &voyagerv1.Registration{
+		ServiceName: "test-service",
+		InstanceId:  "instance-4",
+		Address:     "10.0.0.4",
+		Port:        8080,
+		Metadata:    map[string]string{"environment": "production"},
+		Health:      voyagerv1.HealthResponse_SUSPECT,
+	}
+
+	stream := &MockWatchClient{events: make(chan *voyagerv1.ServiceEvent, 4)}
+	stream.events <- &voyagerv1.ServiceEvent{
+		Type:      voyagerv1.ServiceEvent_SNAPSHOT,
+		Instances: []*voyagerv1.Registration{production1, staging, suspect},
+		Revision:  1,
+	}
+	stream.events <- &voyagerv1.ServiceEvent{Type: voyagerv1.ServiceEvent_ADDED, Instance: production2, Revision: 2}
+	stream.events <- &voyagerv1.ServiceEvent{Type: voyagerv1.ServiceEvent_REMOVED, Instance: production1, Revision: 3}
+
+	// The stream ends once the resolver cancels its watch
+	mockClient.On("Watch", mock.Anything, &voyagerv1.ServiceQuery{ServiceName: "test-service"}).
+		Run(func(args mock.Arguments) {
+			ctx := args.Get(0).(context.Context)
+			go func() {
+				<-ctx.Done()
+				close(stream.events)
+			}()
+		}).
+		Return(stream, nil).Once()
+
+	target, err := url.Parse("voyager:///test-service?selector=environment%3Dproduction")
+	assert.NoError(t, err)
+
+	cc := &fakeResolverConn{states: make(chan resolver.State, 4), errs: make(chan error, 4)}
+	r, err := cli.ResolverBuilder().Build(resolver.Target{URL: *target}, cc, resolver.BuildOptions{})
+	assert.NoError(t, err)
+	defer r.Close()
+
+	addrs := func() []string {
+		select {
+		case state := <-cc.states:
+			var result []string
+			for _, endpoint := range state.Endpoints {
+				assert.Equal(t, "production", InstanceMetadata(endpoint.Attributes)["environment"])
+				result = append(result, endpoint.Addresses[0].Addr)
+			}
+			return result
+		case <-time.After(time.Second):
+			t.Fatal("resolver state not updated")
+			return nil
+		}
+	}
+
+	// Suspect and non-matching instances are left out
+	assert.Equal(t, []string{"10.0.0.1:8080"}, addrs())
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, addrs())
+	assert.Equal(t, []string{"10.0.0.2:8080"}, addrs())
+
+	t.Run("Invalid target", func(t *testing.T) {
+		_, err := cli.ResolverBuilder().Build(resolver.Target{URL: url.URL{Scheme: ResolverScheme}}, cc, resolver.BuildOptions{})
+		assert.Error(t, err)
+	})
+}
+
+// TestClient_ResolverLastInstance tests that calls through the resolver fail
+// once the last instance is gone instead of reaching it
+func TestClient_ResolverLastInstance(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	var served atomic.Int64
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		served.Add(1)
+		if err := stream.RecvMsg(&voyagerv1.InstanceID{}); err != nil {
+			return err
+		}
+		return stream.SendMsg(&voyagerv1.Response{Success: true})
+	}))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	instance := &voyagerv1.Registration{
+		ServiceName: "test-service",
+		InstanceId:  "instance-1",
+		Address:     "10.0.0.1",
+		Port:        8080,
+		Health:      voyagerv1.HealthResponse_HEALTHY,
+	}
+	stream := &MockWatchClient{events: make(chan *voyagerv1.ServiceEvent, 2)}
+	stream.events <- &voyagerv1.ServiceEvent{
+		Type:      voyagerv1.ServiceEvent_SNAPSHOT,
+		Instances: []*voyagerv1.Registration{instance},
+		Revision:  1,
+	}
+	mockClient := new(MockDiscoveryClient)
+	mockClient.On("Watch", mock.Anything, &voyagerv1.ServiceQuery{ServiceName: "test-service"}).
+		Run(func(args mock.Arguments) {
+			ctx := args.Get(0).(context.Context)
+			go func() {
+				<-ctx.Done()
+				close(stream.events)
+			}()
+		}).
+		Return(stream, nil).Once()
+	cli := &Client{
+		discoverySvc: mockClient,
+		options:      &Options{TTL: 30 * time.Second, RetryDelay: 10 * time.Millisecond},
+		cache:        cache.New(30*time.Second, 10*time.Minute),
+	}
+
+	conn, err := grpc.NewClient("voyager:///test-service",
+		grpc.WithResolvers(cli.ResolverBuilder()),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	invoke := func(opts ...grpc.CallOption) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return conn.Invoke(ctx, "/test.Service/Method", &voyagerv1.InstanceID{}, &voyagerv1.Response{}, opts...)
+	}
+	require.NoError(t, invoke(grpc.WaitForReady(true)))
+
+	stream.events <- &voyagerv1.ServiceEvent{Type: voyagerv1.ServiceEvent_REMOVED, Instance: instance, Revision: 2}
+	assert.Eventually(t, func() bool {
+		return status.Code(invoke()) == codes.Unavailable
+	}, 2*time.Second, 20*time.Millisecond)
+	before := served.Load()
+	assert.Equal(t, codes.Unavailable, status.Code(invoke()))
+	assert.Equal(t, before, served.Load())
+}
+
+// TestClient_Reregister tests service re-registration after health check failures
+// TestClient_Session tests keepalives over a Session stream
+func TestClient_Session(t *testing.T) {
+	mockClient := &MockDiscoveryClient{sessions: true}
+	cli := &Client{
+		discoverySvc: mockClient,
+		options: &Options{
+			HealthCheckInterval: time.Hour,
+			RetryDelay:          10 * time.Millisecond,
+		},
+		cache: cache.New(30*time.Second, 10*time.Minute),
+	}
+	testRegistration(cli, "test-service", "test-instance")
+
+	first := newMockSessionClient()
+	second := newMockSessionClient()
+	mockClient.On("Session", mock.Anything).Run(first.bind).Return(first, nil).Once()
+	mockClient.On("Register", mock.Anything, mock.MatchedBy(func(req *voyagerv1.Registration) bool {
+		return req.InstanceId == "test-instance"
+	})).Return(&voyagerv1.Response{Success: true}, nil).Once()
+	mockClient.On("Session", mock.Anything).Run(second.bind).Return(second, nil).Once()
+
+	cli.startHealthChecks()
+	defer cli.stopHealthChecks()
+
+	receive := func(stream *MockSessionClient) *voyagerv1.SessionRequest {
+		select {
+		case req := <-stream.sent:
+			return req
+		case <-time.After(2 * time.Second):
+			t.Fatal("no keepalive sent")
+			return nil
+		}
+	}
+
+	// The session opens with a keepalive
+	req := receive(first)
+	assert.Equal(t, "test-service", req.ServiceName)
+	assert.Equal(t, "test-instance", req.InstanceId)
+
+	// Health changes go out on the session instead of a unary HealthCheck
+	cli.SetHealthStatus(voyagerv1.HealthResponse_UNHEALTHY)
+	assert.Equal(t, voyagerv1.HealthResponse_UNHEALTHY, receive(first).Status)
+
+	// An unknown instance is registered again before the session reopens
+	first.end <- status.Error(codes.NotFound, "instance is not registered")
+	assert.Equal(t, voyagerv1.HealthResponse_UNHEALTHY, receive(second).Status)
+	mockClient.AssertExpectations(t)
+}
+
+func TestClient_Reregister(t *testing.T) {
+	t.Run("Re-register after health check failure", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		cli := &Client{
+			discoverySvc: mockClient,
+			options: &Options{
+				HealthCheckInterval: 100 * time.Millisecond,
+			},
+			cache: cache.New(30*time.Second, 10*time.Minute),
+		}
+		testRegistration(cli, "test-service", "test-instance")
+
+		// First health check fails
+		mockClient.On("HealthCheck", mock.Anything, mock.Anything).Return(
+			(*voyagerv1.HealthResponse)(nil),
+			status.Error(codes.Unavailable, "server unavailable"),
+		).Once()
+
+		// Re-registration succeeds
+		mockClient.On("Register", mock.Anything, mock.MatchedBy(func(req *voyagerv1.Registration) bool {
+			return req.ServiceName == "test-service" &&
+				req.InstanceId == "test-instance" &&
+				req.Address == "localhost" &&
+				req.Port == 8080
+		})).Return(&voyagerv1.Response{Success: true}, nil)
+
+		// Subsequent health checks succeed
+		mockClient.On("HealthCheck", mock.Anything, mock.Anything).Return(
+			&voyagerv1.HealthResponse{Status: voyagerv1.HealthResponse_HEALTHY},
+			nil,
+		)
+
+		cli.startHealthChecks()
+		time.Sleep(250 * time.Millisecond)
+		cli.stopHealthChecks()
+
+		mockClient.AssertExpectations(t)
+	})
+}
+
+// TestClient_ServiceConn tests retries on other instances, the retry budget
+// and deadlines
+func TestClient_ServiceConn(t *testing.T) {
+	// Every host is served by its own in-memory server answering with a
+	// configurable code
+	type backend struct {
+		lis   *bufconn.Listener
+		code  codes.Code
+		calls atomic.Int64
+	}
+	backends := map[string]*backend{
+		"127.0.0.1:8080": {code: codes.Unavailable},
+		"127.0.0.2:8080": {code: codes.OK},
+	}
+	for _, b := range backends {
+		b := b
+		b.lis = bufconn.Listen(1024 * 1024)
+		srv := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+			b.calls.Add(1)
+			if err := stream.RecvMsg(&voyagerv1.InstanceID{}); err != nil {
+				return err
+			}
+			if b.code != codes.OK {
+				return status.Error(b.code, "backend failure")
+			}
+			return stream.SendMsg(&voyagerv1.Response{Success: true})
+		}))
+		go func() {
+			if err := srv.Serve(b.lis); err != nil {
+				log.Printf("Test server error: %v", err)
+			}
+		}()
+		defer srv.Stop()
+	}
+
+	newClient := func(policy CallRetryPolicy) *Client {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: []*voyagerv1.Registration{
+				{InstanceId: "instance-1", Address: "127.0.0.1", Port: 8080},
+				{InstanceId: "instance-2", Address: "127.0.0.2", Port: 8080},
+			}},
+			nil,
+		)
+		opts := &Options{
+			TTL:             30 * time.Second,
+			Insecure:        true,
+			CallRetryPolicy: &policy,
+			DialFunc: func(ctx context.Context, address string) (net.Conn, error) {
+				return backends[address].lis.DialContext(ctx)
+			},
+		}
+		return &Client{
+			discoverySvc:   mockClient,
+			options:        opts,
+			connectionPool: NewConnectionPool(opts),
+			balancer:       newRoundRobinBalancer(),
+			cache:          cache.New(30*time.Second, 10*time.Minute),
+		}
+	}
+	invoke := func(ctx context.Context, conn *ServiceConn) error {
+		return conn.Invoke(ctx, "/test.Service/Method", &voyagerv1.InstanceID{}, &voyagerv1.Response{})
+	}
+	reset := func() {
+		for _, b := range backends {
+			b.calls.Store(0)
+		}
+	}
+
+	t.Run("Retry on another instance", func(t *testing.T) {
+		reset()
+		cli := newClient(CallRetryPolicy{InitialBackoff: time.Millisecond})
+		conn := cli.ServiceConn("payment-service")
+
+		for i := 0; i < 4; i++ {
+			require.NoError(t, invoke(context.Background(), conn))
+		}
+		// Every call reached the healthy instance, after at most one failure
+		assert.Equal(t, int64(4), backends["127.0.0.2:8080"].calls.Load())
+		assert.LessOrEqual(t, backends["127.0.0.1:8080"].calls.Load(), int64(4))
+		assert.Positive(t, backends["127.0.0.1:8080"].calls.Load())
+	})
+
+	t.Run("Non-retryable code", func(t *testing.T) {
+		reset()
+		backends["127.0.0.1:8080"].code = codes.InvalidArgument
+		defer func() {
+			backends["127.0.0.1:8080"].code = codes.Unavailable
+		}()
+		cli := newClient(CallRetryPolicy{InitialBackoff: time.Millisecond})
+		conn := cli.ServiceConn("payment-service")
+
+		// Round robin starts with the first instance
+		err := invoke(context.Background(), conn)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, int64(0), backends["127.0.0.2:8080"].calls.Load())
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		reset()
+		cli := newClient(CallRetryPolicy{InitialBackoff: time.Second})
+		conn := cli.ServiceConn("payment-service")
+
+		// The backoff would outlast the call, so it fails at once
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		started := time.Now()
+		err := invoke(ctx, conn)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Less(t, time.Since(started), 150*time.Millisecond)
+		assert.Equal(t, int64(1), backends["127.0.0.1:8080"].calls.Load())
+	})
+
+	t.Run("Retry budget", func(t *testing.T) {
+		budget := newRetryBudget(0.5, 0.001)
+		assert.False(t, budget.withdraw())
+		budget.deposit()
+		budget.deposit()
+		assert.True(t, budget.withdraw())
+		assert.False(t, budget.withdraw())
+	})
+}
+
+// TestClient_HedgedConn tests hedging to a second instance and the hedge
+// budget
+func TestClient_HedgedConn(t *testing.T) {
+	var slowCalls, fastCalls atomic.Int64
+	handlers := map[string]func(grpc.ServerStream) error{
+		"127.0.0.1:8080": func(stream grpc.ServerStream) error {
+			slowCalls.Add(1)
+			if err := stream.SendHeader(metadata.Pairs("instance", "slow")); err != nil {
+				return err
+			}
+			stream.SetTrailer(metadata.Pairs("instance", "slow"))
+			select {
+			case <-time.After(500 * time.Millisecond):
+			case <-stream.Context().Done():
+				return stream.Context().Err()
+			}
+			return stream.SendMsg(&voyagerv1.Response{Success: true, Error: "slow"})
+		},
+		"127.0.0.2:8080": func(stream grpc.ServerStream) error {
+			fastCalls.Add(1)
+			if err := stream.SendHeader(metadata.Pairs("instance", "fast")); err != nil {
+				return err
+			}
+			stream.SetTrailer(metadata.Pairs("instance", "fast"))
+			return stream.SendMsg(&voyagerv1.Response{Success: true, Error: "fast"})
+		},
+	}
+	listeners := make(map[string]*bufconn.Listener)
+	for address, handler := range handlers {
+		handler := handler
+		lis := bufconn.Listen(1024 * 1024)
+		listeners[address] = lis
+		srv := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+			if err := stream.RecvMsg(&voyagerv1.InstanceID{}); err != nil {
+				return err
+			}
+			return handler(stream)
+		}))
+		go func() {
+			if err := srv.Serve(lis); err != nil {
+				log.Printf("Test server error: %v", err)
+			}
+		}()
+		defer srv.Stop()
+	}
+
+	newClient := func() *Client {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: []*voyagerv1.Registration{
+				{InstanceId: "instance-1", Address: "127.0.0.1", Port: 8080},
+				{InstanceId: "instance-2", Address: "127.0.0.2", Port: 8080},
+			}},
+			nil,
+		)
+		opts := &Options{
+			TTL:      30 * time.Second,
+			Insecure: true,
+			DialFunc: func(ctx context.Context, address string) (net.Conn, error) {
+				return listeners[address].DialContext(ctx)
+			},
+		}
+		return &Client{
+			discoverySvc:   mockClient,
+			options:        opts,
+			connectionPool: NewConnectionPool(opts),
+			balancer:       newRoundRobinBalancer(),
+			cache:          cache.New(30*time.Second, 10*time.Minute),
+		}
+	}
+
+	t.Run("Hedge after delay", func(t *testing.T) {
+		slowCalls.Store(0)
+		fastCalls.Store(0)
+		conn := newClient().HedgedConn("payment-service", HedgingPolicy{Delay: 20 * time.Millisecond})
+
+		// Round robin starts with the slow instance
+		reply := &voyagerv1.Response{}
+		var header, trailer metadata.MD
+		started := time.Now()
+		err := conn.Invoke(context.Background(), "/test.Service/Method", &voyagerv1.InstanceID{}, reply,
+			grpc.Header(&header), grpc.Trailer(&trailer))
+		require.NoError(t, err)
+		assert.Equal(t, "fast", reply.Error)
+		assert.Less(t, time.Since(started), 300*time.Millisecond)
+		assert.Equal(t, int64(1), slowCalls.Load())
+		assert.Equal(t, int64(1), fastCalls.Load())
+
+		// The cancelled slow attempt leaves the winner's metadata alone
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, []string{"fast"}, header.Get("instance"))
+		assert.Equal(t, []string{"fast"}, trailer.Get("instance"))
+	})
+
+	t.Run("Hedge budget", func(t *testing.T) {
+		slowCalls.Store(0)
+		fastCalls.Store(0)
+		conn := newClient().HedgedConn("payment-service", HedgingPolicy{
+			Delay:              20 * time.Millisecond,
+			BudgetRatio:        0.001,
+			MinHedgesPerSecond: 0.001,
+		})
+
+		// Without budget the call waits for the slow instance
+		reply := &voyagerv1.Response{}
+		err := conn.Invoke(context.Background(), "/test.Service/Method", &voyagerv1.InstanceID{}, reply)
+		require.NoError(t, err)
+		assert.Equal(t, "slow", reply.Error)
+		assert.Equal(t, int64(0), fastCalls.Load())
+	})
+
+	t.Run("Percentile delay", func(t *testing.T) {
+		window := newLatencyWindow()
+		_, ok := window.percentile(0.9)
+		assert.False(t, ok)
+		for i := 1; i <= 100; i++ {
+			window.add(time.Duration(i) * time.Millisecond)
+		}
+		delay, ok := window.percentile(0.9)
+		assert.True(t, ok)
+		assert.Equal(t, 91*time.Millisecond, delay)
+	})
+}
+
+// TestClient_StaleInstances tests serving last known instances while the
+// discovery service is unreachable
+func TestClient_StaleInstances(t *testing.T) {
+	instances := []*voyagerv1.Registration{
+		{ServiceName: "payment-service", InstanceId: "instance-1", Address: "host1", Port: 8080},
+	}
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	newClient := func(mockClient *MockDiscoveryClient, maxStaleness time.Duration) *Client {
+		return &Client{
+			discoverySvc: mockClient,
+			options: &Options{
+				TTL:          20 * time.Millisecond,
+				MaxStaleness: maxStaleness,
+			},
+			balancer:  newRoundRobinBalancer(),
+			cache:     cache.New(20*time.Millisecond, 10*time.Minute),
+			lastKnown: newLastKnown(maxStaleness),
+		}
+	}
+
+	t.Run("Serve stale and refresh", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: instances}, nil).Once()
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			(*voyagerv1.ServiceList)(nil), unavailable).Once()
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: instances}, nil)
+		cli := newClient(mockClient, 0)
+		defer cli.lastKnown.close()
+
+		_, err := cli.getServiceInstances(context.Background(), "payment-service", &discoverOptions{})
+		require.NoError(t, err)
+		_, stale := cli.Stale("payment-service")
+		assert.False(t, stale)
+
+		// The cache entry expires while the discovery service is down
+		time.Sleep(30 * time.Millisecond)
+		got, err := cli.getServiceInstances(context.Background(), "payment-service", &discoverOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, instances, got)
+		_, stale = cli.Stale("payment-service")
+		assert.True(t, stale)
+
+		// The background refresh ends the stale period
+		assert.Eventually(t, func() bool {
+			_, stale := cli.Stale("payment-service")
+			return !stale
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("Max staleness", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: instances}, nil).Once()
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			(*voyagerv1.ServiceList)(nil), unavailable)
+		cli := newClient(mockClient, 50*time.Millisecond)
+		defer cli.lastKnown.close()
+
+		_, err := cli.getServiceInstances(context.Background(), "payment-service", &discoverOptions{})
+		require.NoError(t, err)
+
+		time.Sleep(100 * time.Millisecond)
+		_, err = cli.getServiceInstances(context.Background(), "payment-service", &discoverOptions{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("Eviction", func(t *testing.T) {
+		known := newLastKnown(50 * time.Millisecond)
+		defer known.close()
+
+		known.store("old-service", instances)
+		time.Sleep(100 * time.Millisecond)
+		known.store("payment-service", instances)
+		known.mu.Lock()
+		assert.NotContains(t, known.entries, "old-service")
+		known.mu.Unlock()
+
+		// Without an age limit the least recently fetched list goes first
+		unbounded := newLastKnown(0)
+		defer unbounded.close()
+		unbounded.store("service-0", instances)
+		time.Sleep(time.Millisecond)
+		for i := 1; i <= maxLastKnownEntries; i++ {
+			unbounded.store(fmt.Sprintf("service-%d", i), instances)
+		}
+		unbounded.mu.Lock()
+		assert.Len(t, unbounded.entries, maxLastKnownEntries)
+		assert.NotContains(t, unbounded.entries, "service-0")
+		unbounded.mu.Unlock()
+	})
+
+	t.Run("Rejected query", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: instances}, nil).Once()
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			(*voyagerv1.ServiceList)(nil), status.Error(codes.PermissionDenied, "denied"))
+		cli := newClient(mockClient, 0)
+		defer cli.lastKnown.close()
+
+		_, err := cli.getServiceInstances(context.Background(), "payment-service", &discoverOptions{})
+		require.NoError(t, err)
+
+		time.Sleep(30 * time.Millisecond)
+		_, err = cli.getServiceInstances(context.Background(), "payment-service", &discoverOptions{})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+// TestClient_TokenSources tests token files, refreshing tokens and the
+// per-RPC credentials
+func TestClient_TokenSources(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("File token", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(path, []byte("first-token\n"), 0o600))
+
+		source := FileTokenSource(path)
+		token, err := source.Token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "first-token", token)
+
+		// A rotated token is read once the check interval passed
+		require.NoError(t, os.WriteFile(path, []byte("second-token"), 0o600))
+		require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+		token, err = source.Token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "first-token", token)
+
+		source.(*fileTokenSource).checked = time.Time{}
+		token, err = source.Token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "second-token", token)
+	})
+
+	t.Run("Refreshing token", func(t *testing.T) {
+		fetches := 0
+		fail := false
+		source := RefreshingTokenSource(func(context.Context) (string, time.Time, error) {
+			if fail {
+				return "", time.Time{}, errors.New("token endpoint down")
+			}
+			fetches++
+			return fmt.Sprintf("token-%d", fetches), time.Now().Add(150 * time.Millisecond), nil
+		}, 100*time.Millisecond)
+
+		token, err := source.Token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token)
+		token, _ = source.Token(ctx)
+		assert.Equal(t, "token-1", token)
+
+		// Within the leeway before expiry a new token is fetched
+		time.Sleep(60 * time.Millisecond)
+		token, _ = source.Token(ctx)
+		assert.Equal(t, "token-2", token)
+
+		// A failed refresh keeps the unexpired token
+		time.Sleep(60 * time.Millisecond)
+		fail = true
+		token, err = source.Token(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "token-2", token)
+
+		time.Sleep(100 * time.Millisecond)
+		_, err = source.Token(ctx)
+		assert.Error(t, err)
+	})
+
+	t.Run("Per-RPC credentials", func(t *testing.T) {
+		assert.Nil(t, newTokenCredentials(&Options{}))
+
+		creds := newTokenCredentials(&Options{TokenSource: StaticToken("secure-token"), Insecure: true})
+		md, err := creds.GetRequestMetadata(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer secure-token", md["authorization"])
+		assert.False(t, creds.RequireTransportSecurity())
+
+		creds = newTokenCredentials(&Options{TokenSource: TokenSourceFunc(func(context.Context) (string, error) {
+			return "", errors.New("no token")
+		})})
+		_, err = creds.GetRequestMetadata(ctx)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.True(t, creds.RequireTransportSecurity())
+	})
+}
+
+// TestClient_DiscoverOptions tests per-call timeouts, retries, filters,
+// strategy overrides and freshness
+func TestClient_DiscoverOptions(t *testing.T) {
+	one := []*voyagerv1.Registration{
+		{InstanceId: "instance-1", Address: "host1", Port: 8080},
+	}
+	two := []*voyagerv1.Registration{
+		{InstanceId: "instance-1", Address: "host1", Port: 8080},
+		{InstanceId: "instance-2", Address: "host2", Port: 8080},
+	}
+	newClient := func(mockClient *MockDiscoveryClient) *Client {
+		return &Client{
+			discoverySvc: mockClient,
+			options:      &Options{TTL: time.Minute, Timeout: time.Second},
+			balancer:     newRoundRobinBalancer(),
+			cache:        cache.New(time.Minute, 10*time.Minute),
+		}
+	}
+	selectWith := func(cli *Client, opts ...DiscoverOption) (*voyagerv1.Registration, error) {
+		callOpts, err := newDiscoverOptions(opts)
+		require.NoError(t, err)
+		return cli.selectInstance(context.Background(), "payment-service", callOpts, nil)
+	}
+
+	t.Run("Timeout and retries", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		var deadlines []time.Duration
+		mockClient.On("Discover", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			deadline, _ := args.Get(0).(context.Context).Deadline()
+			deadlines = append(deadlines, time.Until(deadline))
+		}).Return((*voyagerv1.ServiceList)(nil), status.Error(codes.Unavailable, "down")).Times(3)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: one}, nil)
+		cli := newClient(mockClient)
+
+		// Without retries the first failure is returned
+		_, err := selectWith(cli)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+
+		_, err = selectWith(cli,
+			WithDiscoverTimeout(100*time.Millisecond),
+			WithDiscoverRetries(2, time.Millisecond))
+		require.NoError(t, err)
+		require.Len(t, deadlines, 3)
+		assert.InDelta(t, float64(time.Second), float64(deadlines[0]), float64(50*time.Millisecond))
+		assert.InDelta(t, float64(100*time.Millisecond), float64(deadlines[1]), float64(50*time.Millisecond))
+	})
+
+	t.Run("Metadata filter", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.MatchedBy(func(q *voyagerv1.ServiceQuery) bool {
+			return len(q.Selector) == 2 &&
+				q.Selector[0].Key == "environment" && q.Selector[0].Values[0] == "production" &&
+				q.Selector[1].Key == "version" && q.Selector[1].Values[0] == "1.2.0"
+		})).Return(&voyagerv1.ServiceList{Instances: one}, nil).Once()
+		cli := newClient(mockClient)
+
+		_, err := selectWith(cli, WithMetadataFilter(map[string]string{
+			"version":     "1.2.0",
+			"environment": "production",
+		}))
+		require.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Strategy override", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: two}, nil).Once()
+		cli := newClient(mockClient)
+
+		first, err := selectWith(cli, WithDiscoverStrategy(ConsistentHash), WithHashKey("user-42"))
+		require.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			selected, err := selectWith(cli, WithDiscoverStrategy(ConsistentHash), WithHashKey("user-42"))
+			require.NoError(t, err)
+			assert.Equal(t, first.InstanceId, selected.InstanceId)
+		}
+		assert.Contains(t, cli.balancers, ConsistentHash)
+
+		// Calls without an override keep the client balancer
+		a, _ := selectWith(cli)
+		b, _ := selectWith(cli)
+		assert.NotEqual(t, a.InstanceId, b.InstanceId)
+	})
+
+	t.Run("Health-filtering strategy override", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.MatchedBy(func(q *voyagerv1.ServiceQuery) bool {
+			return q.HealthyOnly
+		})).Return(&voyagerv1.ServiceList{Instances: one}, nil).Once()
+		mockClient.On("Discover", mock.Anything, mock.MatchedBy(func(q *voyagerv1.ServiceQuery) bool {
+			return !q.HealthyOnly
+		})).Return(&voyagerv1.ServiceList{Instances: two}, nil).Once()
+		cli := newClient(mockClient)
+
+		_, err := selectWith(cli)
+		require.NoError(t, err)
+
+		// The cached healthy-only list is not enough for LocalityAware
+		_, err = selectWith(cli, WithDiscoverStrategy(LocalityAware))
+		require.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Cache bypass and max staleness", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: one}, nil).Times(3)
+		cli := newClient(mockClient)
+
+		_, err := selectWith(cli)
+		require.NoError(t, err)
+		_, err = selectWith(cli)
+		require.NoError(t, err)
+		mockClient.AssertNumberOfCalls(t, "Discover", 1)
+
+		_, err = selectWith(cli, WithCacheBypass())
+		require.NoError(t, err)
+		mockClient.AssertNumberOfCalls(t, "Discover", 2)
+
+		time.Sleep(30 * time.Millisecond)
+		_, err = selectWith(cli, WithDiscoverMaxStaleness(time.Minute))
+		require.NoError(t, err)
+		mockClient.AssertNumberOfCalls(t, "Discover", 2)
+		_, err = selectWith(cli, WithDiscoverMaxStaleness(10*time.Millisecond))
+		require.NoError(t, err)
+		mockClient.AssertNumberOfCalls(t, "Discover", 3)
+	})
+
+	t.Run("Minimum instances", func(t *testing.T) {
+		mockClient := new(MockDiscoveryClient)
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: one}, nil).Once()
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: two}, nil).Once()
+		mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+			&voyagerv1.ServiceList{Instances: one}, nil).Once()
+		cli := newClient(mockClient)
+
+		_, err := selectWith(cli)
+		require.NoError(t, err)
+
+		// The cached single instance is not enough, the lookup finds two
+		_, err = selectWith(cli, WithMinInstances(2))
+		require.NoError(t, err)
+
+		_, err = selectWith(cli, WithMinInstances(2), WithCacheBypass())
+		assert.ErrorContains(t, err, "1 healthy instances available for service payment-service, 2 required")
+		mockClient.AssertExpectations(t)
+	})
+}
+
+// TestClient_Resolve tests resolving instances without dialing and
+// broadcasting to all of them
+func TestClient_Resolve(t *testing.T) {
+	instances := []*voyagerv1.Registration{
+		{InstanceId: "instance-1", Address: "host1", Port: 8080},
+		{InstanceId: "instance-2", Address: "host2", Port: 8080},
+		{InstanceId: "instance-3", Address: "host3", Port: 8080},
+		{InstanceId: "instance-4", Address: "host4", Port: 8080},
+		{InstanceId: "unhealthy", Address: "host5", Port: 8080, Health: voyagerv1.HealthResponse_UNHEALTHY},
+		{InstanceId: "draining", Address: "host6", Port: 8080, Weight: proto.Uint32(0)},
+	}
+	mockClient := new(MockDiscoveryClient)
+	mockClient.On("Discover", mock.Anything, mock.Anything).Return(
+		&voyagerv1.ServiceList{Instances: instances}, nil)
+
+	opts := &Options{TTL: time.Minute, Insecure: true}
+	pool := NewConnectionPool(opts)
+	defer pool.Close()
+	cli := &Client{
+		discoverySvc:   mockClient,
+		options:        opts,
+		connectionPool: pool,
+		balancer:       newRoundRobinBalancer(),
+		cache:          cache.New(time.Minute, 10*time.Minute),
+	}
+
+	t.Run("Resolve", func(t *testing.T) {
+		resolved, err := cli.Resolve(context.Background(), "payment-service")
+		require.NoError(t, err)
+		var ids []string
+		for _, inst := range resolved {
+			ids = append(ids, inst.InstanceId)
+		}
+		assert.Equal(t, []string{"instance-1", "instance-2", "instance-3", "instance-4"}, ids)
+		assert.Zero(t, pool.ConnectionCount("host1:8080"))
+
+		// A second Resolve is served from the cache
+		_, err = cli.Resolve(context.Background(), "payment-service")
+		require.NoError(t, err)
+		mockClient.AssertNumberOfCalls(t, "Discover", 1)
+
+		_, err = cli.Resolve(context.Background(), "payment-service", WithMinInstances(5), WithCacheBypass())
+		assert.Error(t, err)
+	})
+
+	t.Run("Resolve all", func(t *testing.T) {
+		var inFlight, maxInFlight atomic.Int64
+		var called atomic.Int64
+		err := cli.ResolveAll(context.Background(), "payment-service", 2,
+			func(ctx context.Context, inst *voyagerv1.Registration, conn *grpc.ClientConn) error {
+				called.Add(1)
+				current := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					seen := maxInFlight.Load()
+					if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+						break
+					}
+				}
+				assert.Equal(t, instanceAddress(inst), conn.Target())
+				time.Sleep(20 * time.Millisecond)
+				if inst.InstanceId == "instance-3" {
+					return errors.New("cache flush failed")
+				}
+				return nil
+			})
+		assert.EqualError(t, err, "instance instance-3: cache flush failed")
+		assert.Equal(t, int64(5), called.Load())
+		assert.Equal(t, int64(2), maxInFlight.Load())
+	})
+
+	t.Run("Resolve all reaches instances without traffic", func(t *testing.T) {
+		cli.outliers = newOutlierDetector(&OutlierDetection{ConsecutiveFailures: 1})
+		defer func() { cli.outliers = nil }()
+		cli.outliers.record("host1:8080", status.Error(codes.Unavailable, "down"))
+
+		resolved, err := cli.Resolve(context.Background(), "payment-service")
+		require.NoError(t, err)
+		assert.Len(t, resolved, 3)
+
+		var mu sync.Mutex
+		var ids []string
+		err = cli.ResolveAll(context.Background(), "payment-service", 0,
+			func(ctx context.Context, inst *voyagerv1.Registration, conn *grpc.ClientConn) error {
+				mu.Lock()
+				defer mu.Unlock()
+				ids = append(ids, inst.InstanceId)
+				return nil
+			})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"instance-1", "instance-2", "instance-3", "instance-4", "draining"}, ids)
+	})
+}
+
+// TestClient_Registrations tests several instances registered by one client
+func TestClient_Registrations(t *testing.T) {
+	mockClient := &MockDiscoveryClient{sessions: true}
+	cli := &Client{
+		discoverySvc: mockClient,
+		options: &Options{
+			HealthCheckInterval: time.Hour,
+		},
+		cache: cache.New(30*time.Second, 10*time.Minute),
+	}
+	defer cli.stopHealthChecks()
+
+	var registered []*voyagerv1.Registration
+	mockClient.On("Register", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		registered = append(registered, args.Get(1).(*voyagerv1.Registration))
+	}).Return(&voyagerv1.Response{Success: true}, nil)
+
+	first := newMockSessionClient()
+	second := newMockSessionClient()
+	mockClient.On("Session", mock.Anything).Run(first.bind).Return(first, nil).Once()
+	mockClient.On("Session", mock.Anything).Run(second.bind).Return(second, nil).Once()
+
+	// receive returns the next keepalive of an instance
+	receive := func(stream *MockSessionClient, instanceID string) *voyagerv1.SessionRequest {
+		for {
+			select {
+			case req := <-stream.sent:
+				if req.InstanceId == instanceID {
+					return req
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("no keepalive sent for instance %s", instanceID)
+				return nil
+			}
+		}
+	}
+
+	api, err := cli.Register("api-service", "10.0.0.1", 8080, nil, WithWeight(2))
+	require.NoError(t, err)
+	receive(first, api.InstanceID())
+
+	admin, err := cli.Register("admin-service", "10.0.0.1", 9090, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, api.InstanceID(), admin.InstanceID())
+	assert.Equal(t, "admin-service", admin.ServiceName())
+	receive(first, admin.InstanceID())
+	assert.Len(t, cli.Registrations(), 2)
+
+	t.Run("Health per instance", func(t *testing.T) {
+		admin.SetHealthStatus(voyagerv1.HealthResponse_UNHEALTHY)
+		assert.Equal(t, voyagerv1.HealthResponse_UNHEALTHY, receive(first, admin.InstanceID()).Status)
+		assert.Equal(t, voyagerv1.HealthResponse_UNKNOWN, api.reportedHealth())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		require.NoError(t, api.Update(map[string]string{"version": "2"}))
+		reg := registered[len(registered)-1]
+		assert.Equal(t, api.InstanceID(), reg.InstanceId)
+		assert.Equal(t, "2", reg.Metadata["version"])
+		require.NotNil(t, reg.Weight)
+		assert.Equal(t, uint32(2), *reg.Weight)
+	})
+
+	t.Run("Re-register all on unknown instance", func(t *testing.T) {
+		before := len(registered)
+		first.end <- status.Error(codes.NotFound, "instance is not registered")
+
+		// Both instances are kept alive on the reopened stream
+		keepalives := map[string]voyagerv1.HealthResponse_Status{}
+		for len(keepalives) < 2 {
+			select {
+			case req := <-second.sent:
+				keepalives[req.InstanceId] = req.Status
+			case <-time.After(2 * time.Second):
+				t.Fatal("session not reopened")
+			}
+		}
+		assert.Equal(t, map[string]voyagerv1.HealthResponse_Status{
+			api.InstanceID():   voyagerv1.HealthResponse_UNKNOWN,
+			admin.InstanceID(): voyagerv1.HealthResponse_UNHEALTHY,
+		}, keepalives)
+
+		reregistered := map[string]*voyagerv1.Registration{}
+		for _, reg := range registered[before:] {
+			reregistered[reg.InstanceId] = reg
+		}
+		require.Len(t, reregistered, 2)
+		assert.Equal(t, "2", reregistered[api.InstanceID()].Metadata["version"])
+		assert.Equal(t, int32(9090), reregistered[admin.InstanceID()].Port)
+	})
 
+	t.Run("Deregister", func(t *testing.T) {
+		mockClient.On("Deregister", mock.Anything, &voyagerv1.InstanceID{
+			ServiceName: "api-service",
+			InstanceId:  api.InstanceID(),
+		}).Return(&voyagerv1.Response{Success: true}, nil).Once()
+		mockClient.On("Deregister", mock.Anything, &voyagerv1.InstanceID{
+			ServiceName: "admin-service",
+			InstanceId:  admin.InstanceID(),
+		}).Return(&voyagerv1.Response{Success: true}, nil).Once()
+
+		require.NoError(t, api.Deregister())
+		assert.Error(t, api.Deregister())
+		assert.Error(t, api.Update(nil))
+		assert.Equal(t, []*Registration{admin}, cli.Registrations())
+
+		// The remaining instance keeps the health checks running
+		cli.healthMutex.Lock()
+		assert.NotNil(t, cli.healthCheckCtx)
+		cli.healthMutex.Unlock()
+
+		require.NoError(t, cli.Deregister())
+		assert.Error(t, cli.Deregister())
+		cli.healthMutex.Lock()
+		assert.Nil(t, cli.healthCheckCtx)
+		cli.healthMutex.Unlock()
 		mockClient.AssertExpectations(t)
 	})
 }
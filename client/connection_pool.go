@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
 	"sync"
 	"sync/atomic"
@@ -21,9 +23,11 @@ type ConnectionPooler interface {
 
 // ConnectionPool implements a gRPC connection pool
 type ConnectionPool struct {
-	mu    sync.RWMutex
-	conns map[string]*pooledConnection
-	opts  *Options
+	mu       sync.RWMutex
+	conns    map[string]*pooledConnection
+	opts     *Options
+	latency  *latencyTracker
+	outliers *outlierDetector // nil without outlier detection
 }
 
 type pooledConnection struct {
@@ -46,8 +50,10 @@ func (pc *pooledConnection) GetState() connectivity.State {
 // NewConnectionPool creates a new connection pool
 func NewConnectionPool(opts *Options) *ConnectionPool {
 	return &ConnectionPool{
-		conns: make(map[string]*pooledConnection),
-		opts:  opts,
+		conns:    make(map[string]*pooledConnection),
+		opts:     opts,
+		latency:  newLatencyTracker(),
+		outliers: newOutlierDetector(opts.OutlierDetection),
 	}
 }
 
@@ -76,6 +82,14 @@ func (p *ConnectionPool) Get(ctx context.Context, address string) (*grpc.ClientC
 
 	dialOptions := []grpc.DialOption{
 		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(p.unaryInterceptor(address)),
+		grpc.WithChainStreamInterceptor(p.streamInterceptor(address)),
+	}
+
+	if p.opts.PooledAuth {
+		if creds := newTokenCredentials(p.opts); creds != nil {
+			dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(creds))
+		}
 	}
 
 	if p.opts.DialFunc != nil {
@@ -129,6 +143,68 @@ func (p *ConnectionPool) Close() {
 	p.conns = make(map[string]*pooledConnection)
 }
 
+// unaryInterceptor records the latency, load and result of unary calls to
+// address
+func (p *ConnectionPool) unaryInterceptor(address string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if p.outliers != nil {
+			p.outliers.start(address)
+		}
+		started := p.latency.start(address)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		p.latency.finish(address, started)
+		if p.outliers != nil {
+			p.outliers.record(address, err)
+		}
+		return err
+	}
+}
+
+// streamInterceptor records how long streams to address take to open and
+// how they end
+func (p *ConnectionPool) streamInterceptor(address string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if p.outliers != nil {
+			p.outliers.start(address)
+		}
+		started := p.latency.start(address)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		p.latency.finish(address, started)
+		if p.outliers == nil {
+			return stream, err
+		}
+		if err != nil {
+			p.outliers.record(address, err)
+			return nil, err
+		}
+		return &observedStream{ClientStream: stream, record: func(err error) {
+			p.outliers.record(address, err)
+		}}, nil
+	}
+}
+
+// observedStream reports how a client stream ended
+type observedStream struct {
+	grpc.ClientStream
+	once   sync.Once
+	record func(error)
+}
+
+// RecvMsg reports the first receive error; io.EOF is a clean end
+func (s *observedStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(func() {
+			if errors.Is(err, io.EOF) {
+				s.record(nil)
+				return
+			}
+			s.record(err)
+		})
+	}
+	return err
+}
+
 // monitorConnection watches connection state and cleans up when idle
 func (p *ConnectionPool) monitorConnection(address string, pc *pooledConnection) {
 	ticker := time.NewTicker(30 * time.Second)
@@ -140,6 +216,7 @@ func (p *ConnectionPool) monitorConnection(address string, pc *pooledConnection)
 			if atomic.LoadInt64(&pc.refCount) == 0 {
 				pc.Close()
 				delete(p.conns, address)
+				p.latency.forget(address)
 				p.mu.Unlock()
 				return
 			}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// fileTokenCheckInterval limits how often a token file is checked for changes
+const fileTokenCheckInterval = time.Second
+
+// TokenSource supplies the bearer token sent with every call
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc adapts a function to TokenSource
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken returns a TokenSource always returning token
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// fileTokenSource reads a token from a file and re-reads it when it changes
+type fileTokenSource struct {
+	path    string
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	checked time.Time
+}
+
+// FileTokenSource returns a TokenSource reading the token from a file, such
+// as a mounted Kubernetes secret. The file is checked for changes at most
+// once per second, so rotated tokens are picked up without a restart.
+func FileTokenSource(path string) TokenSource {
+	return &fileTokenSource{path: path}
+}
+
+// Token returns the current file content without surrounding whitespace
+func (s *fileTokenSource) Token(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.token != "" && now.Sub(s.checked) < fileTokenCheckInterval {
+		return s.token, nil
+	}
+	s.checked = now
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat token file: %w", err)
+	}
+	if s.token != "" && info.ModTime().Equal(s.modTime) {
+		return s.token, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("token file is empty")
+	}
+	s.token = token
+	s.modTime = info.ModTime()
+	return token, nil
+}
+
+// refreshingTokenSource caches a token until shortly before it expires
+type refreshingTokenSource struct {
+	fetch  func(ctx context.Context) (string, time.Time, error)
+	leeway time.Duration
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// RefreshingTokenSource returns a TokenSource caching the tokens returned by
+// fetch, together with their expiry, and fetching a new one leeway before the
+// current one expires
+func RefreshingTokenSource(fetch func(ctx context.Context) (token string, expiry time.Time, err error), leeway time.Duration) TokenSource {
+	return &refreshingTokenSource{fetch: fetch, leeway: leeway}
+}
+
+// Token returns the cached token or fetches a new one
+func (s *refreshingTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expiry) > s.leeway {
+		return s.token, nil
+	}
+
+	token, expiry, err := s.fetch(ctx)
+	if err != nil {
+		// The cached token is still good until it expires
+		if s.token != "" && time.Now().Before(s.expiry) {
+			return s.token, nil
+		}
+		return "", err
+	}
+	s.token, s.expiry = token, expiry
+	return token, nil
+}
+
+// tokenCredentials sends a TokenSource token as bearer authorization
+type tokenCredentials struct {
+	source     TokenSource
+	requireTLS bool
+}
+
+var _ credentials.PerRPCCredentials = (*tokenCredentials)(nil)
+
+// newTokenCredentials returns per-RPC credentials for the configured token
+// source, or nil without one
+func newTokenCredentials(opts *Options) credentials.PerRPCCredentials {
+	if opts.TokenSource == nil {
+		return nil
+	}
+	return &tokenCredentials{source: opts.TokenSource, requireTLS: !opts.Insecure}
+}
+
+// GetRequestMetadata adds the authorization header to a call
+func (c *tokenCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := c.source.Token(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "failed to get auth token: %v", err)
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity keeps tokens off plaintext connections unless the
+// client was configured with WithInsecure
+func (c *tokenCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
@@ -3,9 +3,14 @@ package client
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
 )
 
@@ -26,51 +31,196 @@ func (c *Client) startHealthChecks() {
 		}
 	}
 
-	log.Printf("Starting health checks for service %s, instance %s, interval: %v",
-		c.serviceName, c.instanceID, interval)
+	log.Printf("Starting health checks, interval: %v", interval)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	c.healthCheckCtx = ctx
 	c.healthCheckCancel = cancel
 
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
+	go c.runHealthChecks(ctx, interval)
+}
+
+// runHealthChecks keeps the registered instances alive over one Session
+// stream, reopening it when it breaks. Servers without the Session RPC get
+// unary health checks.
+func (c *Client) runHealthChecks(ctx context.Context, interval time.Duration) {
+	defer log.Printf("Health checks stopped")
+
+	retryDelay := c.options.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = time.Second
+	}
+
+	failures := 0
+	for {
+		opened := time.Now()
+		err := c.runSession(ctx, interval)
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch status.Code(err) {
+		case codes.Unimplemented:
+			log.Printf("Server does not support sessions, using unary health checks")
+			c.runUnaryHealthChecks(ctx, interval)
+			return
+		case codes.NotFound:
+			// The stream does not tell which instance the server lost, and
+			// registering again is harmless for the others
+			for _, r := range c.Registrations() {
+				r.reregister()
+			}
+		default:
+			log.Printf("Session broken: %v", err)
+		}
+
+		// A broken session is reopened at once, on another discovery server
+		// if its own went away; only repeated failures wait
+		if time.Since(opened) >= interval {
+			failures = 0
+		}
+		failures++
+		if failures == 1 {
+			continue
+		}
+
+		select {
+		case <-time.After(retryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runSession sends a keepalive for every registered instance every interval
+// on a new Session stream until the stream breaks or ctx is cancelled
+func (c *Client) runSession(ctx context.Context, interval time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.discoverySvc.Session(ctx)
+	if err != nil {
+		return err
+	}
 
+	recvErr := make(chan error, 1)
+	go func() {
 		for {
-			select {
-			case <-ticker.C:
-				c.sendHealthCheck()
-			case <-ctx.Done():
-				log.Printf("Health checks stopped for service %s instance %s",
-					c.serviceName, c.instanceID)
+			if _, err := stream.Recv(); err != nil {
+				recvErr <- err
 				return
 			}
 		}
 	}()
+
+	c.sessionMutex.Lock()
+	c.session = stream
+	c.sessionMutex.Unlock()
+	defer func() {
+		c.sessionMutex.Lock()
+		c.session = nil
+		c.sessionMutex.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for sent := c.sendKeepalives(stream); ; sent = c.sendKeepalives(stream) {
+		if sent != nil {
+			// A failed send only reports io.EOF; the status comes from Recv
+			if errors.Is(sent, io.EOF) {
+				return <-recvErr
+			}
+			return sent
+		}
+
+		select {
+		case <-ticker.C:
+		case err := <-recvErr:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
 
-// sendHealthCheck performs a single health check request
-func (c *Client) sendHealthCheck() {
-	if c.serviceName == "" || c.instanceID == "" {
-		return
+// sendKeepalives sends the current health of instances on a Session stream,
+// of all registered instances if none are given
+func (c *Client) sendKeepalives(stream voyagerv1.Discovery_SessionClient, registrations ...*Registration) error {
+	if len(registrations) == 0 {
+		registrations = c.Registrations()
 	}
 
+	c.sessionMutex.Lock()
+	defer c.sessionMutex.Unlock()
+	for _, r := range registrations {
+		err := stream.Send(&voyagerv1.SessionRequest{
+			ServiceName: r.serviceName,
+			InstanceId:  r.instanceID,
+			Status:      r.reportedHealth(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runUnaryHealthChecks sends a HealthCheck for every registered instance
+// every interval until ctx is cancelled
+func (c *Client) runUnaryHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, r := range c.Registrations() {
+				c.sendHealthCheck(r)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sendHealthCheck performs a single health check request for an instance
+func (c *Client) sendHealthCheck(r *Registration) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	_, err := c.discoverySvc.HealthCheck(ctx, &voyagerv1.HealthRequest{
-		ServiceName: c.serviceName,
-		InstanceId:  c.instanceID,
+		ServiceName: r.serviceName,
+		InstanceId:  r.instanceID,
+		Status:      r.reportedHealth(),
 	})
 
 	if err != nil {
 		log.Printf("Health check failed for service %s instance %s: %v",
-			c.serviceName, c.instanceID, err)
-		c.reregister()
+			r.serviceName, r.instanceID, err)
+		r.reregister()
 	}
 }
 
+// SetHealthStatus reports the health of every instance registered by the
+// client, and of instances it registers later. See Registration.SetHealthStatus.
+func (c *Client) SetHealthStatus(status voyagerv1.HealthResponse_Status) {
+	c.healthMutex.Lock()
+	c.healthStatus = status
+	c.healthMutex.Unlock()
+
+	for _, r := range c.Registrations() {
+		r.SetHealthStatus(status)
+	}
+}
+
+// reportedHealth returns the health state new instances report
+func (c *Client) reportedHealth() voyagerv1.HealthResponse_Status {
+	c.healthMutex.Lock()
+	defer c.healthMutex.Unlock()
+	return c.healthStatus
+}
+
 // stopHealthChecks terminates health check routines
 func (c *Client) stopHealthChecks() {
 	c.healthMutex.Lock()
@@ -82,19 +232,3 @@ func (c *Client) stopHealthChecks() {
 		c.healthCheckCtx = nil
 	}
 }
-
-// reregister attempts to re-register the service
-func (c *Client) reregister() {
-	log.Printf("Attempting to re-register service %s instance %s",
-		c.serviceName, c.instanceID)
-
-	if c.serviceName == "" {
-		return
-	}
-
-	if err := c.Register(c.serviceName, c.address, c.port, nil); err != nil {
-		log.Printf("Re-registration failed: %v", err)
-	} else {
-		log.Printf("Service %s re-registered successfully", c.serviceName)
-	}
-}
@@ -0,0 +1,266 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Hedging defaults for zero HedgingPolicy fields
+const (
+	DefaultHedgeDelay         = 50 * time.Millisecond
+	DefaultMaxHedgedAttempts  = 2
+	DefaultHedgeBudgetRatio   = 0.1
+	DefaultMinHedgesPerSecond = 1
+	hedgeLatencySamples       = 512
+	hedgeMinPercentileSamples = 20
+)
+
+// HedgingPolicy configures hedged calls. When an attempt has not answered
+// after the hedge delay, another attempt is sent to a different instance; the
+// first success wins and the other attempts are cancelled. The delay is
+// Delay, or the Percentile of recent call latencies once enough calls were
+// seen. Hedges are limited by a budget of BudgetRatio of the calls on top of
+// MinHedgesPerSecond, so a slow service does not receive double load.
+type HedgingPolicy struct {
+	Delay              time.Duration // Fixed hedge delay; 0 selects DefaultHedgeDelay
+	Percentile         float64       // Latency percentile between 0 and 1 used as delay; 0 uses Delay only
+	MaxAttempts        int           // Attempts including the first; 0 selects DefaultMaxHedgedAttempts
+	BudgetRatio        float64       // 0 selects DefaultHedgeBudgetRatio
+	MinHedgesPerSecond float64       // 0 selects DefaultMinHedgesPerSecond
+}
+
+// withDefaults fills zero fields with their defaults
+func (p HedgingPolicy) withDefaults() HedgingPolicy {
+	if p.Delay == 0 {
+		p.Delay = DefaultHedgeDelay
+	}
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = DefaultMaxHedgedAttempts
+	}
+	if p.BudgetRatio == 0 {
+		p.BudgetRatio = DefaultHedgeBudgetRatio
+	}
+	if p.MinHedgesPerSecond == 0 {
+		p.MinHedgesPerSecond = DefaultMinHedgesPerSecond
+	}
+	return p
+}
+
+// latencyWindow keeps the latencies of the most recent successful calls
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func newLatencyWindow() *latencyWindow {
+	return &latencyWindow{
+		samples: make([]time.Duration, 0, hedgeLatencySamples),
+	}
+}
+
+// add records the latency of a call
+func (w *latencyWindow) add(latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.samples) < hedgeLatencySamples {
+		w.samples = append(w.samples, latency)
+		return
+	}
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % hedgeLatencySamples
+}
+
+// percentile returns the latency below which share p of the calls finished,
+// or false while there are too few samples
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	sorted := append([]time.Duration(nil), w.samples...)
+	w.mu.Unlock()
+
+	if len(sorted) < hedgeMinPercentileSamples {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(p * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx], true
+}
+
+// HedgedConn returns a ServiceConn sending hedged unary calls. Use it for
+// idempotent calls only, as a call may run on several instances. Hedged
+// calls are not retried; a failed attempt starts the next hedge at once.
+func (c *Client) HedgedConn(serviceName string, policy HedgingPolicy, opts ...DiscoverOption) *ServiceConn {
+	sc := c.ServiceConn(serviceName, opts...)
+	policy = policy.withDefaults()
+	sc.hedging = &policy
+	sc.hedgeBudget = newRetryBudget(policy.BudgetRatio, policy.MinHedgesPerSecond)
+	sc.latencies = newLatencyWindow()
+	return sc
+}
+
+// hedgeDelay returns the wait before the next hedge
+func (sc *ServiceConn) hedgeDelay() time.Duration {
+	if sc.hedging.Percentile > 0 {
+		if delay, ok := sc.latencies.percentile(sc.hedging.Percentile); ok {
+			return delay
+		}
+	}
+	return sc.hedging.Delay
+}
+
+// hedgeResult is the outcome of one hedged attempt
+type hedgeResult struct {
+	attempt int
+	latency time.Duration
+	reply   proto.Message
+	targets *attemptTargets
+	err     error
+}
+
+// attemptTargets receive the header, trailer and peer of one hedged attempt,
+// so attempts finishing late do not overwrite the caller's values
+type attemptTargets struct {
+	header  metadata.MD
+	trailer metadata.MD
+	peer    peer.Peer
+}
+
+// callOptions replaces the caller's header, trailer and peer options with
+// ones writing to the attempt's targets
+func (t *attemptTargets) callOptions(opts []grpc.CallOption) []grpc.CallOption {
+	attemptOpts := make([]grpc.CallOption, 0, len(opts))
+	for _, opt := range opts {
+		switch opt.(type) {
+		case grpc.HeaderCallOption:
+			opt = grpc.Header(&t.header)
+		case grpc.TrailerCallOption:
+			opt = grpc.Trailer(&t.trailer)
+		case grpc.PeerCallOption:
+			opt = grpc.Peer(&t.peer)
+		}
+		attemptOpts = append(attemptOpts, opt)
+	}
+	return attemptOpts
+}
+
+// copyTo hands the attempt's values to the caller's targets in opts
+func (t *attemptTargets) copyTo(opts []grpc.CallOption) {
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			*o.HeaderAddr = t.header
+		case grpc.TrailerCallOption:
+			*o.TrailerAddr = t.trailer
+		case grpc.PeerCallOption:
+			*o.PeerAddr = t.peer
+		}
+	}
+}
+
+// invokeHedged performs a unary call as hedged attempts on different
+// instances and keeps the first success
+func (sc *ServiceConn) invokeHedged(ctx context.Context, callOpts *discoverOptions, method string, args, reply any, opts []grpc.CallOption) error {
+	replyMsg, ok := reply.(proto.Message)
+	if !ok {
+		// Attempts need replies of their own
+		return sc.invokeOnce(ctx, callOpts, make(map[string]bool), method, args, reply, opts)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sc.hedgeBudget.deposit()
+	tried := make(map[string]bool)
+	results := make(chan hedgeResult, sc.hedging.MaxAttempts)
+	attempts, pending := 0, 0
+	launch := func() {
+		attempts++
+		pending++
+		attempt := attempts
+		inst, err := sc.client.selectInstance(ctx, sc.serviceName, callOpts, tried)
+		if err != nil {
+			results <- hedgeResult{attempt: attempt, err: status.Error(codes.Unavailable, err.Error())}
+			return
+		}
+		address := instanceAddress(inst)
+		tried[address] = true
+		attemptReply := replyMsg.ProtoReflect().New().Interface()
+		targets := &attemptTargets{}
+		attemptOpts := targets.callOptions(opts)
+		go func() {
+			started := time.Now()
+			err := sc.invokeAddress(ctx, address, method, args, attemptReply, attemptOpts)
+			results <- hedgeResult{attempt: attempt, latency: time.Since(started), reply: attemptReply, targets: targets, err: err}
+		}()
+	}
+	// hedge launches another attempt if attempts and the budget allow it
+	hedge := func() bool {
+		if attempts >= sc.hedging.MaxAttempts {
+			return false
+		}
+		if !sc.hedgeBudget.withdraw() {
+			hedgesCounter.WithLabelValues("throttled").Inc()
+			return false
+		}
+		hedgesCounter.WithLabelValues("sent").Inc()
+		launch()
+		return true
+	}
+
+	launch()
+	timer := time.NewTimer(sc.hedgeDelay())
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case result := <-results:
+			pending--
+			// Late attempts are dropped, so the caller ends up with the
+			// header, trailer and peer of the attempt whose outcome is returned
+			if result.targets != nil {
+				result.targets.copyTo(opts)
+			}
+			if result.err == nil {
+				if result.attempt > 1 {
+					hedgesCounter.WithLabelValues("won").Inc()
+				}
+				sc.latencies.add(result.latency)
+				proto.Reset(replyMsg)
+				proto.Merge(replyMsg, result.reply)
+				return nil
+			}
+			lastErr = result.err
+			// A retryable failure starts the next hedge without waiting
+			// for the delay; any other failure is final
+			if !sc.policy.retryable(ctx, result.err) {
+				return result.err
+			}
+			if !hedge() && pending == 0 {
+				return lastErr
+			}
+		case <-timer.C:
+			if hedge() {
+				timer.Reset(sc.hedgeDelay())
+			}
+		case <-ctx.Done():
+			if lastErr != nil {
+				return lastErr
+			}
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyDecay is the time constant of the latency average. Older
+	// samples lose weight over it, and so does the estimate of an idle
+	// instance, so a recovered instance is tried again.
+	latencyDecay = 10 * time.Second
+	// initialLatency is assumed for instances without samples
+	initialLatency = 30 * time.Millisecond
+)
+
+// latencyTracker records RPC latency and outstanding requests per address
+type latencyTracker struct {
+	mu    sync.Mutex
+	loads map[string]*endpointLoad
+}
+
+// endpointLoad is the peak EWMA latency and the in-flight requests of one
+// address
+type endpointLoad struct {
+	ewma    float64 // nanoseconds
+	pending int64
+	updated time.Time
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		loads: make(map[string]*endpointLoad),
+	}
+}
+
+// start records a request sent to address
+func (t *latencyTracker) start(address string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	load := t.loadLocked(address)
+	load.pending++
+	return time.Now()
+}
+
+// finish records the latency of a completed request. Samples above the
+// average replace it at once; lower ones are blended in, so latency spikes
+// take effect immediately and recoveries gradually.
+func (t *latencyTracker) finish(address string, started time.Time) {
+	now := time.Now()
+	rtt := float64(now.Sub(started))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	load := t.loadLocked(address)
+	load.pending--
+	if rtt > load.ewma {
+		load.ewma = rtt
+	} else {
+		w := math.Exp(-float64(now.Sub(load.updated)) / float64(latencyDecay))
+		load.ewma = load.ewma*w + rtt*(1-w)
+	}
+	load.updated = now
+}
+
+// cost returns the expected wait at address: the decayed latency average
+// times the requests it would be queued behind
+func (t *latencyTracker) cost(address string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	load, exists := t.loads[address]
+	if !exists {
+		return float64(initialLatency)
+	}
+	w := math.Exp(-float64(time.Since(load.updated)) / float64(latencyDecay))
+	return load.ewma * w * float64(load.pending+1)
+}
+
+// forget drops the samples of an address
+func (t *latencyTracker) forget(address string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.loads, address)
+}
+
+// loadLocked returns the load of an address, creating it on first use.
+// Callers must hold t.mu.
+func (t *latencyTracker) loadLocked(address string) *endpointLoad {
+	load, exists := t.loads[address]
+	if !exists {
+		load = &endpointLoad{ewma: float64(initialLatency), updated: time.Now()}
+		t.loads[address] = load
+	}
+	return load
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics definitions
+var (
+	outlierEjectionsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "voyager_client_outlier_ejections_total",
+		Help: "Total outlier ejections",
+	})
+
+	ejectedAddressesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "voyager_client_ejected_addresses",
+		Help: "Number of instance addresses ejected or awaiting a half-open probe",
+	})
+
+	hedgesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voyager_client_hedges_total",
+		Help: "Hedged attempts by result (sent, won, throttled)",
+	}, []string{"result"})
+
+	staleListsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "voyager_client_stale_lists",
+		Help: "Number of instance lists served past their TTL while discovery is unreachable",
+	})
+
+	staleServesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voyager_client_stale_serves_total",
+		Help: "Total discoveries answered from a stale instance list by service",
+	}, []string{"service"})
+
+	staleDurationHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "voyager_client_stale_duration_seconds",
+		Help:    "Time instance lists were served stale until a refresh succeeded",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})
+)
@@ -6,6 +6,8 @@ import (
 	"crypto/tls"
 	"net"
 	"time"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
 )
 
 // BalancerStrategy defines load balancing strategy types
@@ -18,8 +20,30 @@ const (
 	Random
 	// LeastConnections selects instance with least active connections
 	LeastConnections
+	// Weighted spreads requests in proportion to instance weights using
+	// smooth weighted round robin
+	Weighted
+	// LocalityAware prefers instances in the client's zone, then its region,
+	// then anywhere, spilling over when too few local instances are healthy
+	LocalityAware
+	// ConsistentHash maps the WithHashKey of a call onto a hash ring, so
+	// the same key keeps reaching the same instance
+	ConsistentHash
+	// PeakEWMA picks the less loaded of two random instances, judged by
+	// their peak EWMA latency and outstanding requests
+	PeakEWMA
 )
 
+// DefaultTimeout is the default timeout of discovery lookups
+const DefaultTimeout = 3 * time.Second
+
+// DefaultWeight is the weight of instances registered without WithWeight
+const DefaultWeight = 1
+
+// DefaultLocalitySpillover is the healthy share below which the
+// LocalityAware strategy leaves a locality
+const DefaultLocalitySpillover = 0.5
+
 // Options holds configuration options for the Client
 type Options struct {
 	TTL                 time.Duration
@@ -31,11 +55,131 @@ type Options struct {
 	RetryDelay          time.Duration
 	HealthCheckInterval time.Duration
 	DialFunc            func(context.Context, string) (net.Conn, error)
+	Region              string            // Region the client runs in
+	Zone                string            // Zone the client runs in
+	LocalitySpillover   float64           // Healthy share below which LocalityAware leaves a locality
+	OutlierDetection    *OutlierDetection // Passive outlier detection; nil disables it
+	CallRetryPolicy     *CallRetryPolicy  // Retries of ServiceConn calls; nil selects the defaults
+	MaxStaleness        time.Duration     // Age up to which instance lists are used, cached or during discovery outages; 0 means no limit
+	DiscoveryEndpoints  []string          // Discovery servers in addition to the address passed to New
+	TokenSource         TokenSource       // Bearer token for discovery calls; nil sends none
+	PooledAuth          bool              // Send the token on pooled connections too
+	Timeout             time.Duration     // Timeout of discovery lookups
 }
 
 // Option configures the Client
 type Option func(*Options)
 
+// DiscoverOption configures a single Discover call
+type DiscoverOption interface {
+	applyDiscover(*discoverOptions)
+}
+
+// discoverOptions holds per-call Discover settings
+type discoverOptions struct {
+	selector     []*voyagerv1.LabelRequirement
+	hashKey      string
+	timeout      time.Duration     // 0 uses the client timeout
+	retries      int               // retries of failed lookups
+	retryDelay   time.Duration     // wait between lookup retries
+	strategy     *BalancerStrategy // nil uses the client balancer
+	maxStaleness time.Duration     // 0 uses the client setting
+	bypassCache  bool
+	minInstances int
+	err          error
+}
+
+// discoverOptionFunc adapts a function to DiscoverOption
+type discoverOptionFunc func(*discoverOptions)
+
+func (f discoverOptionFunc) applyDiscover(o *discoverOptions) {
+	f(o)
+}
+
+// WithHashKey routes the call by key with the ConsistentHash strategy, e.g.
+// a user ID for per-user caches. Other strategies ignore it.
+func WithHashKey(key string) DiscoverOption {
+	return discoverOptionFunc(func(o *discoverOptions) {
+		o.hashKey = key
+	})
+}
+
+// WithDiscoverTimeout overrides the client timeout for the lookups of the
+// call
+func WithDiscoverTimeout(timeout time.Duration) DiscoverOption {
+	return discoverOptionFunc(func(o *discoverOptions) {
+		o.timeout = timeout
+	})
+}
+
+// WithDiscoverRetries retries lookups failing with an unreachable discovery
+// server up to retries times, delay apart
+func WithDiscoverRetries(retries int, delay time.Duration) DiscoverOption {
+	return discoverOptionFunc(func(o *discoverOptions) {
+		o.retries = retries
+		o.retryDelay = delay
+	})
+}
+
+// WithDiscoverStrategy picks the instance of the call with another balancer
+// strategy than the client one
+func WithDiscoverStrategy(strategy BalancerStrategy) DiscoverOption {
+	return discoverOptionFunc(func(o *discoverOptions) {
+		o.strategy = &strategy
+	})
+}
+
+// WithDiscoverMaxStaleness overrides the client WithMaxStaleness for the call.
+// Last known lists are dropped once older than the client setting, so a
+// longer override cannot bring them back.
+func WithDiscoverMaxStaleness(maxStaleness time.Duration) DiscoverOption {
+	return discoverOptionFunc(func(o *discoverOptions) {
+		o.maxStaleness = maxStaleness
+	})
+}
+
+// WithCacheBypass looks instances up on the discovery service instead of the
+// cache, e.g. right after a deployment
+func WithCacheBypass() DiscoverOption {
+	return discoverOptionFunc(func(o *discoverOptions) {
+		o.bypassCache = true
+	})
+}
+
+// WithMinInstances fails the call unless at least n healthy instances are
+// available. A cached list with fewer instances is looked up again first.
+func WithMinInstances(n int) DiscoverOption {
+	return discoverOptionFunc(func(o *discoverOptions) {
+		o.minInstances = n
+	})
+}
+
+// RegisterOption configures a Register call
+type RegisterOption interface {
+	applyRegister(*registerOptions)
+}
+
+// registerOptions holds per-call Register settings
+type registerOptions struct {
+	weight *uint32
+}
+
+// registerOptionFunc adapts a function to RegisterOption
+type registerOptionFunc func(*registerOptions)
+
+func (f registerOptionFunc) applyRegister(o *registerOptions) {
+	f(o)
+}
+
+// WithWeight sets the relative share of traffic the instance receives.
+// Weight 0 keeps the instance registered without sending it traffic, e.g.
+// while it warms up.
+func WithWeight(weight uint32) RegisterOption {
+	return registerOptionFunc(func(o *registerOptions) {
+		o.weight = &weight
+	})
+}
+
 // WithTTL sets cache TTL
 func WithTTL(ttl time.Duration) Option {
 	return func(o *Options) {
@@ -43,6 +187,13 @@ func WithTTL(ttl time.Duration) Option {
 	}
 }
 
+// WithTimeout sets the timeout of discovery lookups
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = timeout
+	}
+}
+
 // WithInsecure disables transport security
 func WithInsecure() Option {
 	return func(o *Options) {
@@ -86,6 +237,75 @@ func WithHealthCheckInterval(interval time.Duration) Option {
 	}
 }
 
+// WithLocality sets the region and zone the client runs in. They are
+// registered with the instance and preferred by the LocalityAware strategy,
+// which matches a zone without a region by its name alone.
+func WithLocality(region, zone string) Option {
+	return func(o *Options) {
+		o.Region = region
+		o.Zone = zone
+	}
+}
+
+// WithLocalitySpillover sets the share of healthy instances, between 0 and
+// 1, a locality needs to keep receiving all traffic from LocalityAware
+func WithLocalitySpillover(threshold float64) Option {
+	return func(o *Options) {
+		o.LocalitySpillover = threshold
+	}
+}
+
+// WithOutlierDetection configures passive outlier detection; nil disables it
+func WithOutlierDetection(cfg *OutlierDetection) Option {
+	return func(o *Options) {
+		o.OutlierDetection = cfg
+	}
+}
+
+// WithCallRetryPolicy configures retries of calls made through a ServiceConn
+func WithCallRetryPolicy(policy CallRetryPolicy) Option {
+	return func(o *Options) {
+		o.CallRetryPolicy = &policy
+	}
+}
+
+// WithMaxStaleness limits how old an instance list may be, whether cached or
+// served while the discovery service is unreachable
+func WithMaxStaleness(maxStaleness time.Duration) Option {
+	return func(o *Options) {
+		o.MaxStaleness = maxStaleness
+	}
+}
+
+// WithDiscoveryEndpoints adds discovery servers to fail over to
+func WithDiscoveryEndpoints(endpoints ...string) Option {
+	return func(o *Options) {
+		o.DiscoveryEndpoints = append(o.DiscoveryEndpoints, endpoints...)
+	}
+}
+
+// WithAuthToken authenticates discovery calls with a static bearer token
+func WithAuthToken(token string) Option {
+	return func(o *Options) {
+		o.TokenSource = StaticToken(token)
+	}
+}
+
+// WithTokenSource authenticates discovery calls with tokens from source
+func WithTokenSource(source TokenSource) Option {
+	return func(o *Options) {
+		o.TokenSource = source
+	}
+}
+
+// WithPooledAuth sends the auth token on connections from the connection
+// pool as well, for services behind the same token
+func WithPooledAuth() Option {
+	return func(o *Options) {
+		o.PooledAuth = true
+	}
+}
+
 // WithDialFunc sets custom dialer function
 func WithDialFunc(dialFunc func(context.Context, string) (net.Conn, error)) Option {
 	return func(o *Options) {
@@ -103,5 +323,7 @@ func defaultOptions() *Options {
 		MaxRetries:          5,
 		RetryDelay:          2 * time.Second,
 		HealthCheckInterval: 0, // Auto-calculated
+		Timeout:             DefaultTimeout,
+		LocalitySpillover:   DefaultLocalitySpillover,
 	}
 }
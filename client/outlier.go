@@ -0,0 +1,311 @@
+package client
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// Outlier detection defaults for zero OutlierDetection fields
+const (
+	DefaultConsecutiveFailures = 5
+	DefaultFailureInterval     = 10 * time.Second
+	DefaultMinimumRequests     = 10
+	DefaultBaseEjectionTime    = 30 * time.Second
+	DefaultMaxEjectionTime     = 5 * time.Minute
+	DefaultMaxEjectionPercent  = 50
+	DefaultProbeTimeout        = 30 * time.Second
+)
+
+// OutlierDetection configures passive outlier detection. Calls through
+// pooled connections that fail with Unavailable, DeadlineExceeded,
+// ResourceExhausted or Internal count against their address. An address is
+// ejected after ConsecutiveFailures failures in a row, or once FailureRate
+// of at least MinimumRequests calls within Interval failed. Ejections last
+// BaseEjectionTime, doubling for every ejection in a row up to
+// MaxEjectionTime. Afterwards the address is half-open: one probe call is
+// let through and its result closes it again or renews the ejection. A probe
+// still running after ProbeTimeout lets the next call probe instead.
+type OutlierDetection struct {
+	ConsecutiveFailures int           // 0 selects DefaultConsecutiveFailures
+	FailureRate         float64       // Failed share between 0 and 1; 0 disables
+	MinimumRequests     int           // 0 selects DefaultMinimumRequests
+	Interval            time.Duration // Failure rate window; 0 selects DefaultFailureInterval
+	BaseEjectionTime    time.Duration // 0 selects DefaultBaseEjectionTime
+	MaxEjectionTime     time.Duration // 0 selects DefaultMaxEjectionTime
+	MaxEjectionPercent  int           // Instances of a service that may be ejected; 0 selects DefaultMaxEjectionPercent
+	ProbeTimeout        time.Duration // 0 selects DefaultProbeTimeout
+}
+
+// Ejection describes an address taken out of rotation
+type Ejection struct {
+	Address   string
+	Until     time.Time // End of the ejection; in the past while half-open
+	Ejections int       // Ejections in a row
+	HalfOpen  bool      // Waiting for a probe to decide
+}
+
+// withDefaults fills zero fields with their defaults
+func (o OutlierDetection) withDefaults() OutlierDetection {
+	if o.ConsecutiveFailures == 0 {
+		o.ConsecutiveFailures = DefaultConsecutiveFailures
+	}
+	if o.MinimumRequests == 0 {
+		o.MinimumRequests = DefaultMinimumRequests
+	}
+	if o.Interval == 0 {
+		o.Interval = DefaultFailureInterval
+	}
+	if o.BaseEjectionTime == 0 {
+		o.BaseEjectionTime = DefaultBaseEjectionTime
+	}
+	if o.MaxEjectionTime == 0 {
+		o.MaxEjectionTime = DefaultMaxEjectionTime
+	}
+	if o.MaxEjectionPercent == 0 {
+		o.MaxEjectionPercent = DefaultMaxEjectionPercent
+	}
+	if o.ProbeTimeout == 0 {
+		o.ProbeTimeout = DefaultProbeTimeout
+	}
+	return o
+}
+
+// outlierDetector tracks call results and ejections per address. State is
+// kept for the addresses of the cached instance lists only.
+type outlierDetector struct {
+	cfg       OutlierDetection
+	mu        sync.Mutex
+	addresses map[string]*addressOutlier
+	lists     map[string]map[string]bool // addresses of each cached list
+	listed    map[string]int             // number of lists holding an address
+}
+
+// addressOutlier is the failure history and ejection state of one address
+type addressOutlier struct {
+	consecutive  int
+	windowStart  time.Time
+	requests     int
+	failures     int
+	ejections    int
+	ejectedUntil time.Time // zero while the address is in rotation
+	recoveredAt  time.Time
+	probeStarted time.Time // zero unless a half-open probe is in flight
+}
+
+// newOutlierDetector returns a detector, or nil when cfg is nil
+func newOutlierDetector(cfg *OutlierDetection) *outlierDetector {
+	if cfg == nil {
+		return nil
+	}
+	return &outlierDetector{
+		cfg:       cfg.withDefaults(),
+		addresses: make(map[string]*addressOutlier),
+		lists:     make(map[string]map[string]bool),
+		listed:    make(map[string]int),
+	}
+}
+
+// halfOpen reports whether the ejection ended and awaits a probe
+func (a *addressOutlier) halfOpen(now time.Time) bool {
+	return !a.ejectedUntil.IsZero() && !now.Before(a.ejectedUntil)
+}
+
+// probing reports whether a half-open probe started less than timeout ago
+func (a *addressOutlier) probing(now time.Time, timeout time.Duration) bool {
+	return !a.probeStarted.IsZero() && now.Sub(a.probeStarted) < timeout
+}
+
+// start marks a call to a half-open address as its probe
+func (d *outlierDetector) start(address string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if a, exists := d.addresses[address]; exists && a.halfOpen(now) && !a.probing(now, d.cfg.ProbeTimeout) {
+		a.probeStarted = now
+	}
+}
+
+// listChanged records the addresses of a cached instance list and forgets
+// the state of addresses no cached list holds anymore
+func (d *outlierDetector) listChanged(key string, instances []*voyagerv1.Registration) {
+	addresses := make(map[string]bool, len(instances))
+	for _, inst := range instances {
+		addresses[instanceAddress(inst)] = true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	previous := d.lists[key]
+	for address := range addresses {
+		if !previous[address] {
+			d.listed[address]++
+		}
+	}
+	for address := range previous {
+		if addresses[address] {
+			continue
+		}
+		if d.listed[address]--; d.listed[address] > 0 {
+			continue
+		}
+		delete(d.listed, address)
+		if a, exists := d.addresses[address]; exists {
+			if !a.ejectedUntil.IsZero() {
+				ejectedAddressesGauge.Dec()
+			}
+			delete(d.addresses, address)
+		}
+	}
+
+	if len(addresses) == 0 {
+		delete(d.lists, key)
+	} else {
+		d.lists[key] = addresses
+	}
+}
+
+// record counts the result of a call and ejects or restores the address
+func (d *outlierDetector) record(address string, err error) {
+	failed := isOutlierFailure(err)
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	a, exists := d.addresses[address]
+	if !exists {
+		// Addresses that left the lists of a client are not tracked again
+		if len(d.lists) > 0 && d.listed[address] == 0 {
+			return
+		}
+		a = &addressOutlier{windowStart: now}
+		d.addresses[address] = a
+	}
+
+	switch {
+	case a.halfOpen(now):
+		a.probeStarted = time.Time{}
+		if failed {
+			d.ejectLocked(address, a, now)
+			return
+		}
+		log.Printf("Address %s recovered, returning it to rotation", address)
+		a.ejectedUntil = time.Time{}
+		a.recoveredAt = now
+		ejectedAddressesGauge.Dec()
+		return
+	case !a.ejectedUntil.IsZero():
+		// Late result of a call sent before the ejection
+		return
+	}
+
+	if now.Sub(a.windowStart) >= d.cfg.Interval {
+		a.windowStart = now
+		a.requests, a.failures = 0, 0
+	}
+	a.requests++
+	if failed {
+		a.failures++
+		a.consecutive++
+	} else {
+		a.consecutive = 0
+	}
+
+	if a.consecutive >= d.cfg.ConsecutiveFailures ||
+		d.cfg.FailureRate > 0 && a.requests >= d.cfg.MinimumRequests &&
+			float64(a.failures) >= d.cfg.FailureRate*float64(a.requests) {
+		ejectedAddressesGauge.Inc()
+		d.ejectLocked(address, a, now)
+	}
+}
+
+// ejectLocked takes an address out of rotation for an exponentially growing
+// time. Callers must hold d.mu.
+func (d *outlierDetector) ejectLocked(address string, a *addressOutlier, now time.Time) {
+	// Addresses that stayed healthy long enough start over from the base time
+	if !a.recoveredAt.IsZero() && now.Sub(a.recoveredAt) > d.cfg.MaxEjectionTime {
+		a.ejections = 0
+	}
+	a.ejections++
+
+	duration := d.cfg.BaseEjectionTime
+	for i := 1; i < a.ejections && duration < d.cfg.MaxEjectionTime; i++ {
+		duration *= 2
+	}
+	if duration > d.cfg.MaxEjectionTime {
+		duration = d.cfg.MaxEjectionTime
+	}
+
+	log.Printf("Ejecting address %s for %v after %d consecutive and %d of %d failures",
+		address, duration, a.consecutive, a.failures, a.requests)
+	a.ejectedUntil = now.Add(duration)
+	a.consecutive = 0
+	a.windowStart = now
+	a.requests, a.failures = 0, 0
+	outlierEjectionsCounter.Inc()
+}
+
+// filter drops ejected instances and half-open ones with a probe in flight,
+// keeping at least 100-MaxEjectionPercent percent of the instances
+func (d *outlierDetector) filter(instances []*voyagerv1.Registration) []*voyagerv1.Registration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	allowed := len(instances) * d.cfg.MaxEjectionPercent / 100
+	kept := make([]*voyagerv1.Registration, 0, len(instances))
+	for _, inst := range instances {
+		a, exists := d.addresses[instanceAddress(inst)]
+		excluded := exists && (now.Before(a.ejectedUntil) || a.halfOpen(now) && a.probing(now, d.cfg.ProbeTimeout))
+		if excluded && allowed > 0 {
+			allowed--
+			continue
+		}
+		kept = append(kept, inst)
+	}
+	return kept
+}
+
+// ejections returns the ejected and half-open addresses
+func (d *outlierDetector) ejections() []Ejection {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	var ejections []Ejection
+	for address, a := range d.addresses {
+		if a.ejectedUntil.IsZero() {
+			continue
+		}
+		ejections = append(ejections, Ejection{
+			Address:   address,
+			Until:     a.ejectedUntil,
+			Ejections: a.ejections,
+			HalfOpen:  a.halfOpen(now),
+		})
+	}
+	sort.Slice(ejections, func(i, j int) bool {
+		return ejections[i].Address < ejections[j].Address
+	})
+	return ejections
+}
+
+// isOutlierFailure reports whether a call error points at a bad instance,
+// or an unreachable discovery server, rather than at the request
+func isOutlierFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal:
+		return true
+	default:
+		return false
+	}
+}
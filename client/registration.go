@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// instanceSequence tells apart instance IDs generated in the same nanosecond
+var instanceSequence atomic.Uint64
+
+// Registration is a service instance registered by a Client. It is kept
+// alive, together with the other instances of the client, until it is
+// deregistered or the client closes.
+type Registration struct {
+	client      *Client
+	serviceName string
+	instanceID  string
+	address     string
+	port        int
+	registerMu  sync.Mutex // serializes Register calls of the instance
+	mu          sync.Mutex
+	metadata    map[string]string
+	opts        []RegisterOption // reused when the instance is registered again
+	health      voyagerv1.HealthResponse_Status
+}
+
+// ServiceName returns the name of the registered service
+func (r *Registration) ServiceName() string {
+	return r.serviceName
+}
+
+// InstanceID returns the ID the instance is registered under
+func (r *Registration) InstanceID() string {
+	return r.instanceID
+}
+
+// Update registers the instance again with new metadata, keeping its ID and
+// address. opts are applied on top of the options it was registered with.
+func (r *Registration) Update(metadata map[string]string, opts ...RegisterOption) error {
+	if !r.client.registered(r) {
+		return errors.New("instance not registered")
+	}
+
+	r.mu.Lock()
+	opts = append(append([]RegisterOption(nil), r.opts...), opts...)
+	r.mu.Unlock()
+
+	if err := r.register(metadata, opts); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.metadata = maps.Clone(metadata)
+	r.opts = opts
+	r.mu.Unlock()
+	return nil
+}
+
+// SetHealthStatus reports the health of the instance. An UNHEALTHY instance
+// stays registered but is not returned to healthy-only queries until it
+// reports HEALTHY again. The new state is sent right away.
+func (r *Registration) SetHealthStatus(status voyagerv1.HealthResponse_Status) {
+	r.mu.Lock()
+	r.health = status
+	r.mu.Unlock()
+
+	if !r.client.registered(r) {
+		return
+	}
+
+	c := r.client
+	c.sessionMutex.Lock()
+	stream := c.session
+	c.sessionMutex.Unlock()
+	if stream == nil || c.sendKeepalives(stream, r) != nil {
+		c.sendHealthCheck(r)
+	}
+}
+
+// Deregister removes the instance from the discovery service and stops its
+// heartbeats
+func (r *Registration) Deregister() error {
+	if !r.client.removeRegistration(r) {
+		return errors.New("instance not registered")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	resp, err := r.client.discoverySvc.Deregister(ctx, &voyagerv1.InstanceID{
+		ServiceName: r.serviceName,
+		InstanceId:  r.instanceID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !resp.Success {
+		return errors.New("deregistration failed: " + resp.Error)
+	}
+
+	return nil
+}
+
+// reportedHealth returns the health state the instance reports
+func (r *Registration) reportedHealth() voyagerv1.HealthResponse_Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.health
+}
+
+// register sends the instance to the discovery service
+func (r *Registration) register(metadata map[string]string, opts []RegisterOption) error {
+	r.registerMu.Lock()
+	defer r.registerMu.Unlock()
+
+	regOpts := &registerOptions{}
+	for _, opt := range opts {
+		opt.applyRegister(regOpts)
+	}
+
+	c := r.client
+	reg := &voyagerv1.Registration{
+		ServiceName: r.serviceName,
+		InstanceId:  r.instanceID,
+		Address:     r.address,
+		Port:        int32(r.port),
+		Metadata:    metadata,
+		Health:      r.reportedHealth(),
+		Weight:      regOpts.weight,
+	}
+	if c.options.Region != "" || c.options.Zone != "" {
+		reg.Locality = &voyagerv1.Locality{Region: c.options.Region, Zone: c.options.Zone}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	resp, err := c.discoverySvc.Register(ctx, reg)
+	if err != nil {
+		return fmt.Errorf("registration failed: %w", err)
+	}
+
+	if !resp.Success {
+		return errors.New("registration failed: " + resp.Error)
+	}
+	return nil
+}
+
+// reregister registers the instance again after the discovery service lost
+// it, with the metadata and options it was last registered with
+func (r *Registration) reregister() {
+	log.Printf("Attempting to re-register service %s instance %s",
+		r.serviceName, r.instanceID)
+
+	r.mu.Lock()
+	metadata, opts := r.metadata, r.opts
+	r.mu.Unlock()
+
+	if err := r.register(metadata, opts); err != nil {
+		log.Printf("Re-registration failed: %v", err)
+	} else {
+		log.Printf("Service %s re-registered successfully", r.serviceName)
+	}
+}
+
+// newInstanceID returns a unique ID for a new instance of this process
+func newInstanceID() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%d", hostname, time.Now().UnixNano(), instanceSequence.Add(1))
+}
+
+// addRegistration starts keeping an instance alive
+func (c *Client) addRegistration(r *Registration) {
+	c.registrationsMutex.Lock()
+	defer c.registrationsMutex.Unlock()
+
+	if c.registrations == nil {
+		c.registrations = make(map[string]*Registration)
+	}
+	c.registrations[r.instanceID] = r
+	c.startHealthChecks()
+}
+
+// removeRegistration stops keeping an instance alive, and stops the health
+// checks with the last one. It reports whether the instance was registered.
+func (c *Client) removeRegistration(r *Registration) bool {
+	c.registrationsMutex.Lock()
+	defer c.registrationsMutex.Unlock()
+
+	if c.registrations[r.instanceID] != r {
+		return false
+	}
+	delete(c.registrations, r.instanceID)
+	if len(c.registrations) == 0 {
+		c.stopHealthChecks()
+	}
+	return true
+}
+
+// registered reports whether an instance is kept alive by the client
+func (c *Client) registered(r *Registration) bool {
+	c.registrationsMutex.Lock()
+	defer c.registrationsMutex.Unlock()
+	return c.registrations[r.instanceID] == r
+}
+
+// Registrations returns the instances registered by the client
+func (c *Client) Registrations() []*Registration {
+	c.registrationsMutex.Lock()
+	defer c.registrationsMutex.Unlock()
+
+	registrations := make([]*Registration, 0, len(c.registrations))
+	for _, r := range c.registrations {
+		registrations = append(registrations, r)
+	}
+	return registrations
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// DefaultBroadcastConcurrency is the number of instances ResolveAll calls at
+// once when no limit is given
+const DefaultBroadcastConcurrency = 10
+
+// BroadcastFunc is called by ResolveAll for every instance with a pooled
+// connection to it
+type BroadcastFunc func(ctx context.Context, inst *voyagerv1.Registration, conn *grpc.ClientConn) error
+
+// Resolve returns the healthy instances of a service without dialing them,
+// e.g. for HTTP clients or admin pages. It goes through the same cache and
+// filters as Discover and accepts the same options.
+func (c *Client) Resolve(ctx context.Context, serviceName string, opts ...DiscoverOption) ([]*voyagerv1.Registration, error) {
+	callOpts, err := newDiscoverOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := c.candidates(ctx, serviceName, callOpts)
+	if err != nil {
+		return nil, err
+	}
+	return healthyInstances(instances), nil
+}
+
+// ResolveAll calls fn for every healthy instance of a service, at most
+// concurrency at a time (DefaultBroadcastConcurrency if not positive), e.g. to
+// invalidate caches everywhere. Unlike Resolve, it includes instances with
+// weight 0 and ejected outliers, which are alive but take no traffic. It
+// waits for all calls and returns their errors joined, each naming its
+// instance. Instances not started before ctx is done are reported with the
+// context error.
+func (c *Client) ResolveAll(ctx context.Context, serviceName string, concurrency int, fn BroadcastFunc, opts ...DiscoverOption) error {
+	callOpts, err := newDiscoverOptions(opts)
+	if err != nil {
+		return err
+	}
+	instances, err := c.requireInstances(ctx, serviceName, callOpts, healthyInstances)
+	if err != nil {
+		return err
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultBroadcastConcurrency
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	fail := func(inst *voyagerv1.Registration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, fmt.Errorf("instance %s: %w", inst.InstanceId, err))
+	}
+
+	slots := make(chan struct{}, concurrency)
+	for _, inst := range instances {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			fail(inst, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(inst *voyagerv1.Registration) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			address := instanceAddress(inst)
+			conn, err := c.connectionPool.Get(ctx, address)
+			if err != nil {
+				fail(inst, err)
+				return
+			}
+			defer c.connectionPool.Release(address)
+
+			if err := fn(ctx, inst, conn); err != nil {
+				fail(inst, err)
+			}
+		}(inst)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+	"github.com/kolkov/voyager/internal/selector"
+)
+
+// ResolverScheme is the target scheme served by the Voyager resolver, as in
+// grpc.NewClient("voyager:///payment-service")
+const ResolverScheme = "voyager"
+
+// instanceAttributesKey keys the instance attributes of resolved addresses
+type instanceAttributesKey struct{}
+
+// instanceMetadata is Registration.metadata in a form attributes can compare
+type instanceMetadata map[string]string
+
+// Equal reports whether two metadata maps hold the same entries
+func (m instanceMetadata) Equal(o any) bool {
+	other, ok := o.(instanceMetadata)
+	if !ok || len(m) != len(other) {
+		return false
+	}
+	for key, value := range m {
+		if otherValue, exists := other[key]; !exists || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// InstanceMetadata returns the Registration.metadata attached to an address
+// or endpoint attribute set by the Voyager resolver
+func InstanceMetadata(attrs *attributes.Attributes) map[string]string {
+	metadata, _ := attrs.Value(instanceAttributesKey{}).(instanceMetadata)
+	return metadata
+}
+
+// ResolverBuilder returns a resolver.Builder for the voyager scheme backed by
+// this client. Pass it to grpc.WithResolvers, or use RegisterResolver to make
+// it the global handler of the scheme. Targets may carry a label selector:
+// voyager:///payment-service?selector=environment%3Dproduction
+func (c *Client) ResolverBuilder() resolver.Builder {
+	return &resolverBuilder{client: c}
+}
+
+// RegisterResolver registers this client as the global voyager resolver.
+// Like resolver.Register it must be called during initialization.
+func (c *Client) RegisterResolver() {
+	resolver.Register(c.ResolverBuilder())
+}
+
+// resolverBuilder builds watch-driven resolvers
+type resolverBuilder struct {
+	client *Client
+}
+
+// Build starts resolving a voyager:///<service> target
+func (b *resolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	serviceName := target.Endpoint()
+	if serviceName == "" {
+		return nil, fmt.Errorf("voyager resolver: missing service name in target %q", target.String())
+	}
+
+	var requirements []*voyagerv1.LabelRequirement
+	if expr := target.URL.Query().Get("selector"); expr != "" {
+		parsed, err := selector.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("voyager resolver: %w", err)
+		}
+		requirements = parsed
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &voyagerResolver{
+		client:      b.client,
+		cc:          cc,
+		serviceName: serviceName,
+		selector:    requirements,
+		instances:   make(map[string]*voyagerv1.Registration),
+		cancel:      cancel,
+	}
+
+	r.wg.Add(1)
+	go r.run(ctx)
+	return r, nil
+}
+
+// Scheme returns the voyager scheme
+func (b *resolverBuilder) Scheme() string {
+	return ResolverScheme
+}
+
+// voyagerResolver pushes the instances of one service to a gRPC ClientConn
+type voyagerResolver struct {
+	client      *Client
+	cc          resolver.ClientConn
+	serviceName string
+	selector    []*voyagerv1.LabelRequirement
+	instances   map[string]*voyagerv1.Registration
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// run watches the service and updates the ClientConn until closed
+func (r *voyagerResolver) run(ctx context.Context) {
+	defer r.wg.Done()
+
+	for {
+		events, err := r.client.Watch(ctx, r.serviceName)
+		if err == nil {
+			for event := range events {
+				r.apply(event)
+			}
+			// Client.Watch only gives up when ctx is done
+			return
+		}
+
+		log.Printf("Resolver failed to watch service %s: %v", r.serviceName, err)
+		r.cc.ReportError(err)
+
+		select {
+		case <-time.After(r.client.options.RetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// apply updates the instance set with a watch event and pushes the new state
+func (r *voyagerResolver) apply(event *voyagerv1.ServiceEvent) {
+	switch event.Type {
+	case voyagerv1.ServiceEvent_SNAPSHOT:
+		r.instances = make(map[string]*voyagerv1.Registration, len(event.Instances))
+		for _, inst := range event.Instances {
+			r.instances[inst.InstanceId] = inst
+		}
+	case voyagerv1.ServiceEvent_ADDED, voyagerv1.ServiceEvent_UPDATED:
+		if event.Instance != nil {
+			r.instances[event.Instance.InstanceId] = event.Instance
+		}
+	case voyagerv1.ServiceEvent_REMOVED:
+		if event.Instance != nil {
+			delete(r.instances, event.Instance.InstanceId)
+		}
+	default:
+		return
+	}
+
+	r.updateState()
+}
+
+// updateState sends the healthy, matching instances that take traffic to
+// the ClientConn
+func (r *voyagerResolver) updateState() {
+	instances := make([]*voyagerv1.Registration, 0, len(r.instances))
+	for _, inst := range r.instances {
+		instances = append(instances, inst)
+	}
+	instances = selector.Filter(r.selector, servingInstances(healthyInstances(instances)))
+
+	if len(instances) == 0 {
+		// An empty state drops the addresses gRPC already has, so calls fail
+		// with Unavailable instead of reaching instances that are gone. The
+		// balancer rejects it with ErrBadResolverState, which is expected.
+		_ = r.cc.UpdateState(resolver.State{})
+		return
+	}
+
+	// A stable order avoids needless balancer updates
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].InstanceId < instances[j].InstanceId
+	})
+
+	state := resolver.State{
+		Addresses: make([]resolver.Address, 0, len(instances)),
+		Endpoints: make([]resolver.Endpoint, 0, len(instances)),
+	}
+	for _, inst := range instances {
+		attrs := attributes.New(instanceAttributesKey{}, instanceMetadata(inst.Metadata))
+		addr := resolver.Address{
+			Addr:       net.JoinHostPort(inst.Address, strconv.Itoa(int(inst.Port))),
+			Attributes: attrs,
+		}
+		state.Addresses = append(state.Addresses, addr)
+		state.Endpoints = append(state.Endpoints, resolver.Endpoint{
+			Addresses:  []resolver.Address{addr},
+			Attributes: attrs,
+		})
+	}
+
+	if err := r.cc.UpdateState(state); err != nil {
+		log.Printf("Resolver state for service %s rejected: %v", r.serviceName, err)
+	}
+}
+
+// ResolveNow is a no-op: updates are pushed by the watch
+func (r *voyagerResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+// Close stops watching the service
+func (r *voyagerResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"sort"
+	"strings"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+	"github.com/kolkov/voyager/internal/selector"
+)
+
+// selectorCacheSeparator separates service name and selector in cache keys
+const selectorCacheSeparator = "?"
+
+// WithSelector restricts Discover to instances whose metadata matches every
+// requirement. The selector is evaluated by the discovery server.
+func WithSelector(requirements ...*voyagerv1.LabelRequirement) DiscoverOption {
+	return discoverOptionFunc(func(o *discoverOptions) {
+		o.selector = append(o.selector, requirements...)
+	})
+}
+
+// WithLabelSelector parses a selector such as
+// "environment=production,version in (1.2, 1.3),!canary" and restricts
+// Discover to matching instances. Parse errors are returned by Discover.
+func WithLabelSelector(expr string) DiscoverOption {
+	return discoverOptionFunc(func(o *discoverOptions) {
+		requirements, err := selector.Parse(expr)
+		if err != nil {
+			o.err = err
+			return
+		}
+		o.selector = append(o.selector, requirements...)
+	})
+}
+
+// WithMetadataFilter restricts Discover to instances whose metadata contains
+// every key with the given value
+func WithMetadataFilter(metadata map[string]string) DiscoverOption {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	requirements := make([]*voyagerv1.LabelRequirement, 0, len(keys))
+	for _, key := range keys {
+		requirements = append(requirements, LabelEquals(key, metadata[key]))
+	}
+	return WithSelector(requirements...)
+}
+
+// LabelEquals requires metadata key to equal value
+func LabelEquals(key, value string) *voyagerv1.LabelRequirement {
+	return &voyagerv1.LabelRequirement{Key: key, Operator: voyagerv1.LabelRequirement_EQUALS, Values: []string{value}}
+}
+
+// LabelNotEquals requires metadata key to differ from value or be missing
+func LabelNotEquals(key, value string) *voyagerv1.LabelRequirement {
+	return &voyagerv1.LabelRequirement{Key: key, Operator: voyagerv1.LabelRequirement_NOT_EQUALS, Values: []string{value}}
+}
+
+// LabelIn requires metadata key to hold one of values
+func LabelIn(key string, values ...string) *voyagerv1.LabelRequirement {
+	return &voyagerv1.LabelRequirement{Key: key, Operator: voyagerv1.LabelRequirement_IN, Values: values}
+}
+
+// LabelNotIn requires metadata key to hold none of values or be missing
+func LabelNotIn(key string, values ...string) *voyagerv1.LabelRequirement {
+	return &voyagerv1.LabelRequirement{Key: key, Operator: voyagerv1.LabelRequirement_NOT_IN, Values: values}
+}
+
+// LabelExists requires metadata key to be present
+func LabelExists(key string) *voyagerv1.LabelRequirement {
+	return &voyagerv1.LabelRequirement{Key: key, Operator: voyagerv1.LabelRequirement_EXISTS}
+}
+
+// LabelDoesNotExist requires metadata key to be missing
+func LabelDoesNotExist(key string) *voyagerv1.LabelRequirement {
+	return &voyagerv1.LabelRequirement{Key: key, Operator: voyagerv1.LabelRequirement_DOES_NOT_EXIST}
+}
+
+// selectorCacheKey returns the cache key of a filtered instance list
+func selectorCacheKey(serviceName string, requirements []*voyagerv1.LabelRequirement) string {
+	if len(requirements) == 0 {
+		return serviceName
+	}
+	return serviceName + selectorCacheSeparator + selector.String(requirements)
+}
+
+// invalidateSelectorCache drops filtered instance lists of a service so they
+// are rebuilt from the updated full list
+func (c *Client) invalidateSelectorCache(serviceName string) {
+	prefix := serviceName + selectorCacheSeparator
+	for key := range c.cache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			c.cache.Delete(key)
+			if c.outliers != nil {
+				c.outliers.listChanged(key, nil)
+			}
+		}
+	}
+}
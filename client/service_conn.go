@@ -0,0 +1,274 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Call retry defaults for zero CallRetryPolicy fields
+const (
+	DefaultMaxAttempts         = 3
+	DefaultInitialBackoff      = 50 * time.Millisecond
+	DefaultMaxBackoff          = time.Second
+	DefaultBackoffMultiplier   = 2.0
+	DefaultRetryBudgetRatio    = 0.2
+	DefaultMinRetriesPerSecond = 10
+)
+
+// CallRetryPolicy configures retries of unary calls made through a
+// ServiceConn. A failed attempt is retried on another instance after an
+// exponential backoff with jitter, as long as attempts, the retry budget and
+// the call deadline allow it. The budget lets retries add at most
+// BudgetRatio of the calls on top of MinRetriesPerSecond, so retries cannot
+// multiply the load of an overloaded service.
+type CallRetryPolicy struct {
+	MaxAttempts         int           // Attempts including the first; 0 selects DefaultMaxAttempts
+	InitialBackoff      time.Duration // 0 selects DefaultInitialBackoff
+	MaxBackoff          time.Duration // 0 selects DefaultMaxBackoff
+	BackoffMultiplier   float64       // 0 selects DefaultBackoffMultiplier
+	PerAttemptTimeout   time.Duration // Timeout of each attempt; 0 leaves only the call deadline
+	RetryableCodes      []codes.Code  // nil retries Unavailable only
+	BudgetRatio         float64       // 0 selects DefaultRetryBudgetRatio
+	MinRetriesPerSecond float64       // 0 selects DefaultMinRetriesPerSecond
+}
+
+// withDefaults fills zero fields with their defaults
+func (p CallRetryPolicy) withDefaults() CallRetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = DefaultInitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = DefaultMaxBackoff
+	}
+	if p.BackoffMultiplier == 0 {
+		p.BackoffMultiplier = DefaultBackoffMultiplier
+	}
+	if p.RetryableCodes == nil {
+		p.RetryableCodes = []codes.Code{codes.Unavailable}
+	}
+	if p.BudgetRatio == 0 {
+		p.BudgetRatio = DefaultRetryBudgetRatio
+	}
+	if p.MinRetriesPerSecond == 0 {
+		p.MinRetriesPerSecond = DefaultMinRetriesPerSecond
+	}
+	return p
+}
+
+// backoff returns the jittered wait before retry number attempt
+func (p CallRetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt && backoff < float64(p.MaxBackoff); i++ {
+		backoff *= p.BackoffMultiplier
+	}
+	if backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	// Wait between half and all of the backoff so retries do not align
+	return time.Duration(backoff/2 + rand.Float64()*backoff/2)
+}
+
+// retryable reports whether an attempt error may be retried
+func (p CallRetryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	code := status.Code(err)
+	if code == codes.DeadlineExceeded && p.PerAttemptTimeout > 0 {
+		// Only the attempt timed out; the call has time left
+		return true
+	}
+	for _, retryable := range p.RetryableCodes {
+		if code == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// retryBudget is a token bucket refilled by calls and over time
+type retryBudget struct {
+	mu        sync.Mutex
+	ratio     float64
+	perSecond float64
+	tokens    float64
+	capacity  float64
+	refilled  time.Time
+}
+
+func newRetryBudget(ratio, perSecond float64) *retryBudget {
+	// Up to ten seconds of retries can be saved up
+	capacity := 10 * perSecond
+	if capacity < 10 {
+		capacity = 10
+	}
+	return &retryBudget{
+		ratio:     ratio,
+		perSecond: perSecond,
+		tokens:    perSecond,
+		capacity:  capacity,
+		refilled:  time.Now(),
+	}
+}
+
+// deposit adds the share of a call to the budget
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.capacity)
+}
+
+// withdraw takes a retry from the budget if one is left
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.tokens+now.Sub(b.refilled).Seconds()*b.perSecond, b.capacity)
+	b.refilled = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// ServiceConn is a grpc.ClientConnInterface for a service rather than an
+// instance. Every call picks an instance with the client's balancer, and
+// unary calls failing with a retryable code are retried on another
+// instance according to the client's CallRetryPolicy. Streams are not
+// retried.
+type ServiceConn struct {
+	client      *Client
+	serviceName string
+	opts        []DiscoverOption
+	policy      CallRetryPolicy
+	budget      *retryBudget
+	hedging     *HedgingPolicy // nil unless created by HedgedConn
+	hedgeBudget *retryBudget
+	latencies   *latencyWindow
+}
+
+var _ grpc.ClientConnInterface = (*ServiceConn)(nil)
+
+// ServiceConn returns a connection to a service for generated gRPC clients.
+// Discover options, such as a selector or hash key, apply to every call.
+func (c *Client) ServiceConn(serviceName string, opts ...DiscoverOption) *ServiceConn {
+	policy := CallRetryPolicy{}
+	if c.options.CallRetryPolicy != nil {
+		policy = *c.options.CallRetryPolicy
+	}
+	policy = policy.withDefaults()
+
+	return &ServiceConn{
+		client:      c,
+		serviceName: serviceName,
+		opts:        opts,
+		policy:      policy,
+		budget:      newRetryBudget(policy.BudgetRatio, policy.MinRetriesPerSecond),
+	}
+}
+
+// Invoke performs a unary call, retrying on other instances
+func (sc *ServiceConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	callOpts, err := newDiscoverOptions(sc.opts)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if sc.hedging != nil {
+		return sc.invokeHedged(ctx, callOpts, method, args, reply, opts)
+	}
+
+	sc.budget.deposit()
+	tried := make(map[string]bool)
+	for attempt := 1; ; attempt++ {
+		err = sc.invokeOnce(ctx, callOpts, tried, method, args, reply, opts)
+		if err == nil || attempt >= sc.policy.MaxAttempts || !sc.policy.retryable(ctx, err) {
+			return err
+		}
+
+		backoff := sc.policy.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+			return err
+		}
+		if !sc.budget.withdraw() {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// invokeOnce performs one attempt on an instance not tried yet, if any
+func (sc *ServiceConn) invokeOnce(ctx context.Context, callOpts *discoverOptions, tried map[string]bool, method string, args, reply any, opts []grpc.CallOption) error {
+	c := sc.client
+	inst, err := c.selectInstance(ctx, sc.serviceName, callOpts, tried)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	address := instanceAddress(inst)
+	tried[address] = true
+	return sc.invokeAddress(ctx, address, method, args, reply, opts)
+}
+
+// invokeAddress performs one attempt on a pooled connection to address
+func (sc *ServiceConn) invokeAddress(ctx context.Context, address string, method string, args, reply any, opts []grpc.CallOption) error {
+	c := sc.client
+	conn, err := c.connectionPool.Get(ctx, address)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer c.connectionPool.Release(address)
+
+	if sc.policy.PerAttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sc.policy.PerAttemptTimeout)
+		defer cancel()
+	}
+	return conn.Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream opens a stream on an instance picked by the balancer
+func (sc *ServiceConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	callOpts, err := newDiscoverOptions(sc.opts)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	c := sc.client
+	inst, err := c.selectInstance(ctx, sc.serviceName, callOpts, nil)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	address := instanceAddress(inst)
+	conn, err := c.connectionPool.Get(ctx, address)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	stream, err := conn.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		c.connectionPool.Release(address)
+		return nil, err
+	}
+	// The stream context is done once the stream finished
+	go func() {
+		<-stream.Context().Done()
+		c.connectionPool.Release(address)
+	}()
+	return stream, nil
+}
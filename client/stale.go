@@ -0,0 +1,183 @@
+package client
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+const (
+	// staleRefreshBackoff is the first wait before refreshing a stale list
+	staleRefreshBackoff = 500 * time.Millisecond
+	// maxStaleRefreshBackoff caps the wait between refresh attempts
+	maxStaleRefreshBackoff = 30 * time.Second
+	// maxLastKnownEntries caps the number of kept lists; the least recently
+	// fetched ones are evicted first
+	maxLastKnownEntries = 1024
+)
+
+// lastKnown keeps the instance lists fetched from the discovery server past
+// their cache TTL, so they can be served while the server is unreachable
+type lastKnown struct {
+	ctx     context.Context // cancelled when the client closes
+	cancel  context.CancelFunc
+	maxAge  time.Duration // lists older than this are evicted; 0 keeps them
+	mu      sync.Mutex
+	entries map[string]*lastKnownEntry
+}
+
+// lastKnownEntry is the last fetched instance list of a cache key
+type lastKnownEntry struct {
+	instances  []*voyagerv1.Registration
+	updated    time.Time
+	staleSince time.Time // zero while the list is fresh
+	refreshing bool      // a background refresh is running
+}
+
+func newLastKnown(maxAge time.Duration) *lastKnown {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lastKnown{
+		ctx:     ctx,
+		cancel:  cancel,
+		maxAge:  maxAge,
+		entries: make(map[string]*lastKnownEntry),
+	}
+}
+
+// store records a freshly fetched list and ends a stale period
+func (k *lastKnown) store(key string, instances []*voyagerv1.Registration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	entry, exists := k.entries[key]
+	if !exists {
+		entry = &lastKnownEntry{}
+		k.entries[key] = entry
+	}
+	if !entry.staleSince.IsZero() {
+		staleDuration := time.Since(entry.staleSince)
+		log.Printf("Instance list %s refreshed after %v stale", key, staleDuration.Round(time.Millisecond))
+		staleDurationHistogram.Observe(staleDuration.Seconds())
+		staleListsGauge.Dec()
+		entry.staleSince = time.Time{}
+	}
+	entry.instances = instances
+	entry.updated = time.Now()
+	entry.refreshing = false
+	k.prune()
+}
+
+// prune evicts the lists older than maxAge and, above maxLastKnownEntries,
+// the least recently fetched ones. The caller holds mu.
+func (k *lastKnown) prune() {
+	var oldestKey string
+	var oldest *lastKnownEntry
+	for key, entry := range k.entries {
+		if k.maxAge > 0 && time.Since(entry.updated) > k.maxAge {
+			k.evict(key)
+			continue
+		}
+		if oldest == nil || entry.updated.Before(oldest.updated) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	if len(k.entries) > maxLastKnownEntries {
+		k.evict(oldestKey)
+	}
+}
+
+// evict drops the list of key. The caller holds mu.
+func (k *lastKnown) evict(key string) {
+	if !k.entries[key].staleSince.IsZero() {
+		staleListsGauge.Dec()
+	}
+	delete(k.entries, key)
+}
+
+// serveStale returns the last known list of key unless it is older than
+// maxStaleness, marking it stale. refresh reports whether the caller has to
+// start the background refresh.
+func (k *lastKnown) serveStale(key string, maxStaleness time.Duration) (instances []*voyagerv1.Registration, refresh, ok bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	entry, exists := k.entries[key]
+	if !exists {
+		return nil, false, false
+	}
+	age := time.Since(entry.updated)
+	if k.maxAge > 0 && age > k.maxAge {
+		k.evict(key)
+		return nil, false, false
+	}
+	if maxStaleness > 0 && age > maxStaleness {
+		return nil, false, false
+	}
+	if entry.staleSince.IsZero() {
+		entry.staleSince = time.Now()
+		staleListsGauge.Inc()
+	}
+	refresh = !entry.refreshing
+	entry.refreshing = true
+	return entry.instances, refresh, true
+}
+
+// staleSince returns the earliest time a list of the service went stale
+func (k *lastKnown) staleSince(serviceName string) (time.Time, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var since time.Time
+	prefix := serviceName + selectorCacheSeparator
+	for key, entry := range k.entries {
+		if key != serviceName && !strings.HasPrefix(key, prefix) || entry.staleSince.IsZero() {
+			continue
+		}
+		if since.IsZero() || entry.staleSince.Before(since) {
+			since = entry.staleSince
+		}
+	}
+	return since, !since.IsZero()
+}
+
+// close stops background refreshes
+func (k *lastKnown) close() {
+	k.cancel()
+}
+
+// Stale reports whether instances of a service are served from a list kept
+// past its TTL because the discovery server is unreachable, and since when
+func (c *Client) Stale(serviceName string) (since time.Time, stale bool) {
+	if c.lastKnown == nil {
+		return time.Time{}, false
+	}
+	return c.lastKnown.staleSince(serviceName)
+}
+
+// refreshStale fetches a stale instance list with exponential backoff until
+// the discovery server answers or the client closes
+func (c *Client) refreshStale(key, serviceName string, requirements []*voyagerv1.LabelRequirement) {
+	ctx := c.lastKnown.ctx
+	backoff := staleRefreshBackoff
+	for {
+		// Jitter spreads the refreshes of many clients after an outage
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+
+		instances, err := c.fetchInstances(ctx, serviceName, &discoverOptions{selector: requirements})
+		if err == nil {
+			c.storeInstances(key, instances)
+			return
+		}
+		backoff = min(2*backoff, maxStaleRefreshBackoff)
+	}
+}
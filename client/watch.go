@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// Watch subscribes to instance changes of a service, or of every service when
+// serviceName is empty. Events are applied to the local discovery cache before
+// being delivered, so Discover stops returning removed instances immediately.
+// Broken streams are resumed from the last seen revision; the returned channel
+// is closed when ctx is done.
+func (c *Client) Watch(ctx context.Context, serviceName string) (<-chan *voyagerv1.ServiceEvent, error) {
+	stream, err := c.discoverySvc.Watch(ctx, &voyagerv1.ServiceQuery{ServiceName: serviceName})
+	if err != nil {
+		return nil, fmt.Errorf("watch failed: %w", err)
+	}
+
+	events := make(chan *voyagerv1.ServiceEvent, 16)
+	go c.runWatch(ctx, serviceName, stream, events)
+	return events, nil
+}
+
+// runWatch receives events until ctx is done, reopening the stream on errors
+func (c *Client) runWatch(ctx context.Context, serviceName string, stream voyagerv1.Discovery_WatchClient, events chan<- *voyagerv1.ServiceEvent) {
+	defer close(events)
+
+	var revision uint64
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Watch stream for service %q broken at revision %d: %v", serviceName, revision, err)
+
+			stream = c.reopenWatch(ctx, serviceName, revision)
+			if stream == nil {
+				return
+			}
+			continue
+		}
+
+		revision = event.Revision
+		c.applyWatchEvent(serviceName, event)
+
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reopenWatch retries the Watch call until it succeeds or ctx is done
+func (c *Client) reopenWatch(ctx context.Context, serviceName string, revision uint64) voyagerv1.Discovery_WatchClient {
+	for {
+		select {
+		case <-time.After(c.options.RetryDelay):
+		case <-ctx.Done():
+			return nil
+		}
+
+		stream, err := c.discoverySvc.Watch(ctx, &voyagerv1.ServiceQuery{
+			ServiceName:    serviceName,
+			ResumeRevision: revision,
+		})
+		if err == nil {
+			return stream
+		}
+		log.Printf("Failed to resume watch for service %q: %v", serviceName, err)
+	}
+}
+
+// applyWatchEvent updates cached instance lists with a watch event
+func (c *Client) applyWatchEvent(serviceName string, event *voyagerv1.ServiceEvent) {
+	if event.Type == voyagerv1.ServiceEvent_SNAPSHOT {
+		byService := make(map[string][]*voyagerv1.Registration)
+		if serviceName != "" {
+			byService[serviceName] = nil
+		}
+		for _, inst := range event.Instances {
+			byService[inst.ServiceName] = append(byService[inst.ServiceName], inst)
+		}
+		for name, instances := range byService {
+			c.storeInstances(name, instances)
+			c.invalidateSelectorCache(name)
+		}
+		return
+	}
+
+	if event.Instance == nil {
+		return
+	}
+
+	name := event.Instance.ServiceName
+	c.invalidateSelectorCache(name)
+
+	cached, found := c.cache.Get(name)
+	if !found {
+		return
+	}
+
+	current := cached.([]*voyagerv1.Registration)
+	updated := make([]*voyagerv1.Registration, 0, len(current)+1)
+	for _, inst := range current {
+		if inst.InstanceId != event.Instance.InstanceId {
+			updated = append(updated, inst)
+		}
+	}
+	if event.Type != voyagerv1.ServiceEvent_REMOVED {
+		updated = append(updated, event.Instance)
+	}
+
+	c.storeInstances(name, updated)
+}
@@ -8,13 +8,16 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/kolkov/voyager/server"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -33,17 +36,27 @@ var rootCmd = &cobra.Command{
 func init() {
 	flags := rootCmd.Flags()
 	flags.StringSlice("etcd-endpoints", []string{"http://localhost:2379"}, "ETCD endpoints")
+	flags.String("bolt-path", "", "bbolt file for single-node persistence (overrides etcd)")
 	flags.Duration("cache-ttl", 30*time.Second, "Cache TTL duration")
+	flags.Duration("suspect-after", 0, "Heartbeat silence before an instance is SUSPECT (default cache-ttl/2)")
+	flags.Duration("session-grace", 10*time.Second, "Time instances outlive a broken Session stream before they are deregistered")
 	flags.String("auth-token", "", "Authentication token")
 	flags.String("grpc-addr", ":50050", "gRPC server address")
+	flags.String("http-addr", "", "REST/JSON API address (empty to disable)")
+	flags.String("xds-addr", "", "xDS (ADS) control plane address for Envoy and proxyless gRPC (empty to disable)")
+	flags.String("dns-addr", "", "DNS server address for <service>.service.voyager. (empty to disable)")
 	flags.String("metrics-addr", ":2112", "Metrics HTTP address")
 	flags.Duration("log-interval", 15*time.Second, "Service logging interval")
 	flags.String("log-format", "text", "Log format (text/json)")
 	flags.Bool("debug", false, "Enable debug logging")
 
-	if err := viper.BindPFlags(flags); err != nil {
-		log.Fatalf("failed to bind flags: %v", err)
-	}
+	// Flags are bound under the keys of the config file and VOYAGER_*
+	// variables, which use underscores
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err := viper.BindPFlag(strings.ReplaceAll(flag.Name, "-", "_"), flag); err != nil {
+			log.Fatalf("failed to bind flag %s: %v", flag.Name, err)
+		}
+	})
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("voyager")
 }
@@ -52,13 +65,7 @@ func runServer(_ *cobra.Command, _ []string) {
 	log.Printf("Starting Voyager Discovery Server %s (commit: %s, built: %s)",
 		version, commit, date)
 
-	cfg := server.Config{
-		ETCDEndpoints: viper.GetStringSlice("etcd_endpoints"),
-		CacheTTL:      viper.GetDuration("cache_ttl"),
-		AuthToken:     viper.GetString("auth_token"),
-	}
-
-	srv, err := server.NewServer(cfg)
+	srv, err := server.NewServer(serverConfig())
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
@@ -78,6 +85,53 @@ func runServer(_ *cobra.Command, _ []string) {
 		}
 	}()
 
+	// Start REST/JSON API server
+	var httpSrv *http.Server
+	if addr := viper.GetString("http_addr"); addr != "" {
+		httpSrv = &http.Server{
+			Addr:              addr,
+			Handler:           srv.HTTPHandler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		go func() {
+			log.Printf("HTTP API server starting on %s", addr)
+			if err := httpSrv.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("HTTP API server failed: %v", err)
+			}
+		}()
+	}
+
+	// Start xDS control plane
+	var xdsSrv *grpc.Server
+	if addr := viper.GetString("xds_addr"); addr != "" {
+		xdsSrv = srv.XDSServer().GRPCServer()
+		xdsListener, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("Failed to listen: %v", err)
+		}
+
+		go func() {
+			log.Printf("xDS server starting on %s", addr)
+			if err := xdsSrv.Serve(xdsListener); err != nil {
+				log.Fatalf("xDS server failed: %v", err)
+			}
+		}()
+	}
+
+	// Start DNS server
+	var dnsSrv *server.DNSServer
+	if addr := viper.GetString("dns_addr"); addr != "" {
+		dnsSrv = srv.DNSServer()
+
+		go func() {
+			log.Printf("DNS server starting on %s", addr)
+			if err := dnsSrv.ListenAndServe(addr); err != nil {
+				log.Fatalf("DNS server failed: %v", err)
+			}
+		}()
+	}
+
 	// Start metrics server
 	metricsMux := http.NewServeMux()
 	metricsMux.Handle("/metrics", promhttp.Handler())
@@ -124,11 +178,30 @@ func runServer(_ *cobra.Command, _ []string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Shutdown HTTP API server
+	if httpSrv != nil {
+		if err := httpSrv.Shutdown(ctx); err != nil {
+			log.Printf("HTTP API server shutdown error: %v", err)
+		}
+	}
+
+	// Stop DNS server
+	if dnsSrv != nil {
+		if err := dnsSrv.Close(); err != nil {
+			log.Printf("DNS server shutdown error: %v", err)
+		}
+	}
+
 	// Shutdown metrics server
 	if err := metricsSrv.Shutdown(ctx); err != nil {
 		log.Printf("Metrics server shutdown error: %v", err)
 	}
 
+	// xDS streams never finish on their own; clients reconnect elsewhere
+	if xdsSrv != nil {
+		xdsSrv.Stop()
+	}
+
 	// Stop gRPC server gracefully
 	stopped := make(chan struct{})
 	go func() {
@@ -148,6 +221,19 @@ func runServer(_ *cobra.Command, _ []string) {
 	log.Println("Voyager discovery server stopped")
 }
 
+// serverConfig builds the server configuration from flags, environment and
+// config file
+func serverConfig() server.Config {
+	return server.Config{
+		ETCDEndpoints: viper.GetStringSlice("etcd_endpoints"),
+		BoltPath:      viper.GetString("bolt_path"),
+		CacheTTL:      viper.GetDuration("cache_ttl"),
+		SuspectAfter:  viper.GetDuration("suspect_after"),
+		SessionGrace:  viper.GetDuration("session_grace"),
+		AuthToken:     viper.GetString("auth_token"),
+	}
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFlags tests that command line flags reach the listeners and the
+// server configuration
+func TestFlags(t *testing.T) {
+	require.NoError(t, rootCmd.ParseFlags([]string{
+		"--dns-addr=:8600",
+		"--http-addr=:8080",
+		"--xds-addr=:18000",
+		"--bolt-path=/tmp/voyager.db",
+		"--suspect-after=7s",
+		"--session-grace=3s",
+	}))
+
+	assert.Equal(t, ":8600", viper.GetString("dns_addr"))
+	assert.Equal(t, ":8080", viper.GetString("http_addr"))
+	assert.Equal(t, ":18000", viper.GetString("xds_addr"))
+	assert.Equal(t, ":50050", viper.GetString("grpc_addr"))
+
+	cfg := serverConfig()
+	assert.Equal(t, "/tmp/voyager.db", cfg.BoltPath)
+	assert.Equal(t, 7*time.Second, cfg.SuspectAfter)
+	assert.Equal(t, 3*time.Second, cfg.SessionGrace)
+	assert.Equal(t, 30*time.Second, cfg.CacheTTL)
+}
@@ -34,6 +34,10 @@ func main() {
 	voyager, err := client.New(voyagerAddr,
 		client.WithInsecure(),
 		client.WithRetryPolicy(5, 2*time.Second),
+		client.WithCallRetryPolicy(client.CallRetryPolicy{
+			MaxAttempts:       3,
+			PerAttemptTimeout: 2 * time.Second,
+		}),
 	)
 	if err != nil {
 		log.Fatalf("Failed to create Voyager client: %v", err)
@@ -53,7 +57,7 @@ func main() {
 	port := listener.Addr().(*net.TCPAddr).Port
 
 	// Register service
-	err = voyager.Register("order-service", getLocalIP(), port, map[string]string{
+	registration, err := voyager.Register("order-service", getLocalIP(), port, map[string]string{
 		"environment": "production",
 		"version":     "1.0.0",
 	})
@@ -63,15 +67,17 @@ func main() {
 
 	// Create gRPC server
 	server := grpc.NewServer()
+	paymentConn := voyager.ServiceConn("payment-service",
+		client.WithSelector(client.LabelEquals("environment", "production")))
 	srv := &orderServer{
-		voyager: voyager,
+		payments: paymentv1.NewPaymentServiceClient(paymentConn),
 	}
 	orderv1.RegisterOrderServiceServer(server, srv)
 
 	reflection.Register(server)
 
 	// Graceful shutdown
-	go handleShutdown(voyager, server)
+	go handleShutdown(registration, server)
 
 	log.Printf("Order service started on port %d", port)
 	if servErr := server.Serve(listener); servErr != nil {
@@ -82,7 +88,7 @@ func main() {
 // orderServer implements order service
 type orderServer struct {
 	orderv1.UnimplementedOrderServiceServer
-	voyager *client.Client
+	payments paymentv1.PaymentServiceClient
 }
 
 // CreateOrder handles order creation
@@ -93,54 +99,20 @@ func (s *orderServer) CreateOrder(ctx context.Context, req *orderv1.CreateOrderR
 	// Generate order ID
 	orderID := "ord_" + req.UserId + "-" + strconv.FormatInt(time.Now().UnixNano(), 10)
 
-	// Discover payment service
-	paymentConn, err := s.voyager.Discover(ctx, "payment-service")
-	if err != nil {
-		log.Printf("Failed to discover payment service: %v", err)
-		return nil, status.Errorf(codes.Unavailable, "payment service unavailable")
-	}
-	defer func() {
-		if conErr := paymentConn.Close(); conErr != nil {
-			log.Printf("failed to close payment connection: %v", conErr)
-		}
-	}()
-
-	// Create payment client
-	paymentClient := paymentv1.NewPaymentServiceClient(paymentConn)
-
-	// Process payment
+	// Process payment on a payment service instance from the same
+	// environment; unavailable instances are retried on another one
 	paymentReq := &paymentv1.ProcessPaymentRequest{
 		OrderId:  orderID,
 		Amount:   req.TotalAmount,
 		Currency: "USD",
 	}
 
-	const maxRetries = 3
-	var paymentResp *paymentv1.ProcessPaymentResponse
-
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		paymentCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
-		paymentResp, err = paymentClient.ProcessPayment(paymentCtx, paymentReq)
-		cancel()
-
-		if err == nil && paymentResp.Success {
-			break
-		}
-
-		if err != nil {
-			log.Printf("Payment attempt %d/%d failed: %v", attempt, maxRetries, err)
-		} else {
-			log.Printf("Payment attempt %d/%d failed: %s", attempt, maxRetries, paymentResp.ErrorMessage)
-		}
-
-		if attempt < maxRetries {
-			backoff := time.Duration(attempt*attempt) * 500 * time.Millisecond
-			time.Sleep(backoff)
-		}
-	}
+	paymentCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
+	paymentResp, err := s.payments.ProcessPayment(paymentCtx, paymentReq)
 	if err != nil {
-		log.Printf("All payment attempts failed: %v", err)
+		log.Printf("Payment processing failed: %v", err)
 		return nil, status.Errorf(codes.Internal, "payment processing failed: %v", err)
 	}
 
@@ -159,7 +131,7 @@ func (s *orderServer) CreateOrder(ctx context.Context, req *orderv1.CreateOrderR
 }
 
 // handleShutdown gracefully shuts down the server
-func handleShutdown(voyager *client.Client, server *grpc.Server) {
+func handleShutdown(registration *client.Registration, server *grpc.Server) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
@@ -167,7 +139,7 @@ func handleShutdown(voyager *client.Client, server *grpc.Server) {
 	log.Println("Shutting down gracefully...")
 
 	// Deregister service
-	if err := voyager.Deregister(); err != nil {
+	if err := registration.Deregister(); err != nil {
 		log.Printf("Deregistration error: %v", err)
 	}
 
@@ -64,7 +64,7 @@ func main() {
 		"environment": "production",
 		"version":     "1.0.0",
 	}
-	err = voyager.Register("payment-service", "localhost", port, metadata)
+	_, err = voyager.Register("payment-service", "localhost", port, metadata)
 	if err != nil {
 		log.Fatalf("Registration failed: %v", err)
 	}
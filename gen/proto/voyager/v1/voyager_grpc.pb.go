@@ -26,6 +26,11 @@ type DiscoveryClient interface {
 	Deregister(ctx context.Context, in *InstanceID, opts ...grpc.CallOption) (*Response, error)
 	Discover(ctx context.Context, in *ServiceQuery, opts ...grpc.CallOption) (*ServiceList, error)
 	HealthCheck(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+	Watch(ctx context.Context, in *ServiceQuery, opts ...grpc.CallOption) (Discovery_WatchClient, error)
+	ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*ListServicesResponse, error)
+	// Keeps registered instances alive for as long as the stream is open.
+	// Instances announced on the stream are deregistered shortly after it breaks.
+	Session(ctx context.Context, opts ...grpc.CallOption) (Discovery_SessionClient, error)
 }
 
 type discoveryClient struct {
@@ -72,6 +77,78 @@ func (c *discoveryClient) HealthCheck(ctx context.Context, in *HealthRequest, op
 	return out, nil
 }
 
+func (c *discoveryClient) Watch(ctx context.Context, in *ServiceQuery, opts ...grpc.CallOption) (Discovery_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Discovery_ServiceDesc.Streams[0], "/voyager.v1.Discovery/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &discoveryWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Discovery_WatchClient interface {
+	Recv() (*ServiceEvent, error)
+	grpc.ClientStream
+}
+
+type discoveryWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *discoveryWatchClient) Recv() (*ServiceEvent, error) {
+	m := new(ServiceEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *discoveryClient) ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*ListServicesResponse, error) {
+	out := new(ListServicesResponse)
+	err := c.cc.Invoke(ctx, "/voyager.v1.Discovery/ListServices", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *discoveryClient) Session(ctx context.Context, opts ...grpc.CallOption) (Discovery_SessionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Discovery_ServiceDesc.Streams[1], "/voyager.v1.Discovery/Session", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &discoverySessionClient{stream}
+	return x, nil
+}
+
+type Discovery_SessionClient interface {
+	Send(*SessionRequest) error
+	Recv() (*SessionResponse, error)
+	grpc.ClientStream
+}
+
+type discoverySessionClient struct {
+	grpc.ClientStream
+}
+
+func (x *discoverySessionClient) Send(m *SessionRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *discoverySessionClient) Recv() (*SessionResponse, error) {
+	m := new(SessionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // DiscoveryServer is the server API for Discovery service.
 // All implementations should embed UnimplementedDiscoveryServer
 // for forward compatibility
@@ -80,6 +157,11 @@ type DiscoveryServer interface {
 	Deregister(context.Context, *InstanceID) (*Response, error)
 	Discover(context.Context, *ServiceQuery) (*ServiceList, error)
 	HealthCheck(context.Context, *HealthRequest) (*HealthResponse, error)
+	Watch(*ServiceQuery, Discovery_WatchServer) error
+	ListServices(context.Context, *ListServicesRequest) (*ListServicesResponse, error)
+	// Keeps registered instances alive for as long as the stream is open.
+	// Instances announced on the stream are deregistered shortly after it breaks.
+	Session(Discovery_SessionServer) error
 }
 
 // UnimplementedDiscoveryServer should be embedded to have forward compatible implementations.
@@ -98,6 +180,15 @@ func (UnimplementedDiscoveryServer) Discover(context.Context, *ServiceQuery) (*S
 func (UnimplementedDiscoveryServer) HealthCheck(context.Context, *HealthRequest) (*HealthResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
 }
+func (UnimplementedDiscoveryServer) Watch(*ServiceQuery, Discovery_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedDiscoveryServer) ListServices(context.Context, *ListServicesRequest) (*ListServicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListServices not implemented")
+}
+func (UnimplementedDiscoveryServer) Session(Discovery_SessionServer) error {
+	return status.Errorf(codes.Unimplemented, "method Session not implemented")
+}
 
 // UnsafeDiscoveryServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to DiscoveryServer will
@@ -182,6 +273,71 @@ func _Discovery_HealthCheck_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Discovery_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ServiceQuery)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DiscoveryServer).Watch(m, &discoveryWatchServer{stream})
+}
+
+type Discovery_WatchServer interface {
+	Send(*ServiceEvent) error
+	grpc.ServerStream
+}
+
+type discoveryWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *discoveryWatchServer) Send(m *ServiceEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Discovery_ListServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListServicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DiscoveryServer).ListServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/voyager.v1.Discovery/ListServices",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DiscoveryServer).ListServices(ctx, req.(*ListServicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Discovery_Session_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DiscoveryServer).Session(&discoverySessionServer{stream})
+}
+
+type Discovery_SessionServer interface {
+	Send(*SessionResponse) error
+	Recv() (*SessionRequest, error)
+	grpc.ServerStream
+}
+
+type discoverySessionServer struct {
+	grpc.ServerStream
+}
+
+func (x *discoverySessionServer) Send(m *SessionResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *discoverySessionServer) Recv() (*SessionRequest, error) {
+	m := new(SessionRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Discovery_ServiceDesc is the grpc.ServiceDesc for Discovery service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -205,7 +361,23 @@ var Discovery_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "HealthCheck",
 			Handler:    _Discovery_HealthCheck_Handler,
 		},
+		{
+			MethodName: "ListServices",
+			Handler:    _Discovery_ListServices_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Discovery_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Session",
+			Handler:       _Discovery_Session_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/voyager/v1/voyager.proto",
 }
@@ -0,0 +1,239 @@
+// Package selector evaluates label selectors against instance metadata
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// Validate checks that every requirement is well formed
+func Validate(requirements []*voyagerv1.LabelRequirement) error {
+	for _, req := range requirements {
+		if req.Key == "" {
+			return errors.New("selector key cannot be empty")
+		}
+		if strings.ContainsAny(req.Key, " \t,()=!") {
+			return fmt.Errorf("selector key %q contains invalid characters", req.Key)
+		}
+
+		switch req.Operator {
+		case voyagerv1.LabelRequirement_EQUALS, voyagerv1.LabelRequirement_NOT_EQUALS:
+			if len(req.Values) != 1 {
+				return fmt.Errorf("selector %q: %s needs exactly one value", req.Key, req.Operator)
+			}
+		case voyagerv1.LabelRequirement_IN, voyagerv1.LabelRequirement_NOT_IN:
+			if len(req.Values) == 0 {
+				return fmt.Errorf("selector %q: %s needs at least one value", req.Key, req.Operator)
+			}
+		case voyagerv1.LabelRequirement_EXISTS, voyagerv1.LabelRequirement_DOES_NOT_EXIST:
+			if len(req.Values) != 0 {
+				return fmt.Errorf("selector %q: %s takes no values", req.Key, req.Operator)
+			}
+		default:
+			return fmt.Errorf("selector %q: unknown operator %s", req.Key, req.Operator)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether labels satisfy every requirement
+func Matches(requirements []*voyagerv1.LabelRequirement, labels map[string]string) bool {
+	for _, req := range requirements {
+		value, exists := labels[req.Key]
+
+		var ok bool
+		switch req.Operator {
+		case voyagerv1.LabelRequirement_EQUALS:
+			ok = exists && len(req.Values) == 1 && value == req.Values[0]
+		case voyagerv1.LabelRequirement_NOT_EQUALS:
+			ok = !exists || len(req.Values) != 1 || value != req.Values[0]
+		case voyagerv1.LabelRequirement_IN:
+			ok = exists && contains(req.Values, value)
+		case voyagerv1.LabelRequirement_NOT_IN:
+			ok = !exists || !contains(req.Values, value)
+		case voyagerv1.LabelRequirement_EXISTS:
+			ok = exists
+		case voyagerv1.LabelRequirement_DOES_NOT_EXIST:
+			ok = !exists
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the instances whose metadata matches every requirement
+func Filter(requirements []*voyagerv1.LabelRequirement, instances []*voyagerv1.Registration) []*voyagerv1.Registration {
+	if len(requirements) == 0 {
+		return instances
+	}
+
+	matched := make([]*voyagerv1.Registration, 0, len(instances))
+	for _, inst := range instances {
+		if Matches(requirements, inst.Metadata) {
+			matched = append(matched, inst)
+		}
+	}
+	return matched
+}
+
+// Parse reads a comma separated selector such as
+// "environment=production,version in (1.2, 1.3),!canary". Supported forms
+// are key=value, key==value, key!=value, key in (...), key notin (...),
+// key and !key.
+func Parse(selector string) ([]*voyagerv1.LabelRequirement, error) {
+	var requirements []*voyagerv1.LabelRequirement
+
+	for _, term := range splitTerms(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		req, err := parseTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, req)
+	}
+
+	if err := Validate(requirements); err != nil {
+		return nil, err
+	}
+	return requirements, nil
+}
+
+// String formats requirements in the syntax accepted by Parse
+func String(requirements []*voyagerv1.LabelRequirement) string {
+	terms := make([]string, 0, len(requirements))
+	for _, req := range requirements {
+		switch req.Operator {
+		case voyagerv1.LabelRequirement_EQUALS:
+			terms = append(terms, req.Key+"="+firstValue(req))
+		case voyagerv1.LabelRequirement_NOT_EQUALS:
+			terms = append(terms, req.Key+"!="+firstValue(req))
+		case voyagerv1.LabelRequirement_IN:
+			terms = append(terms, req.Key+" in ("+sortedValues(req)+")")
+		case voyagerv1.LabelRequirement_NOT_IN:
+			terms = append(terms, req.Key+" notin ("+sortedValues(req)+")")
+		case voyagerv1.LabelRequirement_EXISTS:
+			terms = append(terms, req.Key)
+		case voyagerv1.LabelRequirement_DOES_NOT_EXIST:
+			terms = append(terms, "!"+req.Key)
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// splitTerms splits a selector on commas outside parentheses
+func splitTerms(selector string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range selector {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, selector[start:])
+}
+
+// parseTerm parses a single requirement
+func parseTerm(term string) (*voyagerv1.LabelRequirement, error) {
+	if strings.HasPrefix(term, "!") && !strings.Contains(term, "=") {
+		return &voyagerv1.LabelRequirement{
+			Key:      strings.TrimSpace(term[1:]),
+			Operator: voyagerv1.LabelRequirement_DOES_NOT_EXIST,
+		}, nil
+	}
+
+	if open := strings.Index(term, "("); open >= 0 {
+		if !strings.HasSuffix(term, ")") {
+			return nil, fmt.Errorf("selector %q: missing closing parenthesis", term)
+		}
+
+		fields := strings.Fields(term[:open])
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("selector %q: expected key in (...) or key notin (...)", term)
+		}
+
+		req := &voyagerv1.LabelRequirement{Key: fields[0]}
+		switch fields[1] {
+		case "in":
+			req.Operator = voyagerv1.LabelRequirement_IN
+		case "notin":
+			req.Operator = voyagerv1.LabelRequirement_NOT_IN
+		default:
+			return nil, fmt.Errorf("selector %q: unknown operator %q", term, fields[1])
+		}
+
+		for _, value := range strings.Split(term[open+1:len(term)-1], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				req.Values = append(req.Values, value)
+			}
+		}
+		return req, nil
+	}
+
+	for _, op := range []struct {
+		token    string
+		operator voyagerv1.LabelRequirement_Operator
+	}{
+		{"!=", voyagerv1.LabelRequirement_NOT_EQUALS},
+		{"==", voyagerv1.LabelRequirement_EQUALS},
+		{"=", voyagerv1.LabelRequirement_EQUALS},
+	} {
+		if key, value, found := strings.Cut(term, op.token); found {
+			return &voyagerv1.LabelRequirement{
+				Key:      strings.TrimSpace(key),
+				Operator: op.operator,
+				Values:   []string{strings.TrimSpace(value)},
+			}, nil
+		}
+	}
+
+	if strings.ContainsAny(term, " \t") {
+		return nil, fmt.Errorf("selector %q: unexpected whitespace", term)
+	}
+	return &voyagerv1.LabelRequirement{
+		Key:      term,
+		Operator: voyagerv1.LabelRequirement_EXISTS,
+	}, nil
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// firstValue returns the single value of an equality requirement
+func firstValue(req *voyagerv1.LabelRequirement) string {
+	if len(req.Values) == 0 {
+		return ""
+	}
+	return req.Values[0]
+}
+
+// sortedValues joins set values in a stable order
+func sortedValues(req *voyagerv1.LabelRequirement) string {
+	values := append([]string(nil), req.Values...)
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,105 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// TestParse tests selector parsing
+func TestParse(t *testing.T) {
+	requirements, err := Parse("environment=production, version in (1.2, 1.3),tier!=frontend,region notin (eu),canary,!debug,zone==a")
+	require.NoError(t, err)
+	require.Len(t, requirements, 7)
+
+	expected := []struct {
+		key      string
+		operator voyagerv1.LabelRequirement_Operator
+		values   []string
+	}{
+		{"environment", voyagerv1.LabelRequirement_EQUALS, []string{"production"}},
+		{"version", voyagerv1.LabelRequirement_IN, []string{"1.2", "1.3"}},
+		{"tier", voyagerv1.LabelRequirement_NOT_EQUALS, []string{"frontend"}},
+		{"region", voyagerv1.LabelRequirement_NOT_IN, []string{"eu"}},
+		{"canary", voyagerv1.LabelRequirement_EXISTS, nil},
+		{"debug", voyagerv1.LabelRequirement_DOES_NOT_EXIST, nil},
+		{"zone", voyagerv1.LabelRequirement_EQUALS, []string{"a"}},
+	}
+	for i, want := range expected {
+		assert.Equal(t, want.key, requirements[i].Key)
+		assert.Equal(t, want.operator, requirements[i].Operator)
+		assert.Equal(t, want.values, requirements[i].Values)
+	}
+
+	t.Run("Round trip", func(t *testing.T) {
+		reparsed, err := Parse(String(requirements))
+		require.NoError(t, err)
+		assert.Equal(t, String(requirements), String(reparsed))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, expr := range []string{
+			"=value",
+			"version in (1.2",
+			"version within (1.2)",
+			"version in ()",
+			"bad key",
+		} {
+			_, err := Parse(expr)
+			assert.Error(t, err, expr)
+		}
+	})
+}
+
+// TestMatches tests requirement evaluation
+func TestMatches(t *testing.T) {
+	labels := map[string]string{
+		"environment": "production",
+		"version":     "1.2",
+	}
+
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"environment=production", true},
+		{"environment=staging", false},
+		{"environment!=staging", true},
+		{"region!=eu", true},
+		{"version in (1.1, 1.2)", true},
+		{"version in (1.3)", false},
+		{"version notin (1.3)", true},
+		{"region notin (eu)", true},
+		{"version notin (1.2)", false},
+		{"version", true},
+		{"region", false},
+		{"!region", true},
+		{"!version", false},
+		{"environment=production,version in (1.3)", false},
+	}
+
+	for _, tt := range tests {
+		requirements, err := Parse(tt.selector)
+		require.NoError(t, err, tt.selector)
+		assert.Equal(t, tt.matches, Matches(requirements, labels), tt.selector)
+	}
+}
+
+// TestValidate tests requirement validation
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(nil))
+	assert.Error(t, Validate([]*voyagerv1.LabelRequirement{{Key: "env"}}))
+	assert.Error(t, Validate([]*voyagerv1.LabelRequirement{{
+		Key:      "env",
+		Operator: voyagerv1.LabelRequirement_EQUALS,
+	}}))
+	assert.Error(t, Validate([]*voyagerv1.LabelRequirement{{
+		Key:      "env",
+		Operator: voyagerv1.LabelRequirement_EXISTS,
+		Values:   []string{"production"},
+	}}))
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// boltBucket holds registrations keyed by service and instance ID
+var boltBucket = []byte("registrations")
+
+// boltRecord is the stored form of a registration
+type boltRecord struct {
+	Registration *voyagerv1.Registration `json:"registration"`
+	TTL          time.Duration           `json:"ttl"`
+}
+
+// BoltRegistry is a single-node registry persisted in a bbolt file. Reads and
+// expiry deadlines are served from memory; only registrations are written to
+// disk, so heartbeats cost no I/O. After a restart every stored instance gets
+// a fresh TTL to heartbeat again.
+type BoltRegistry struct {
+	mu    sync.Mutex
+	db    *bolt.DB
+	index *MemoryRegistry
+}
+
+// NewBoltRegistry opens or creates a bbolt registry file
+func NewBoltRegistry(path string) (*BoltRegistry, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+
+	r := &BoltRegistry{
+		db:    db,
+		index: NewMemoryRegistry(),
+	}
+
+	if err := r.load(); err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			return nil, fmt.Errorf("%w (close: %v)", err, closeErr)
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads stored registrations into the in-memory index
+func (r *BoltRegistry) load() error {
+	now := time.Now()
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(_, value []byte) error {
+			var record boltRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("failed to unmarshal registration: %w", err)
+			}
+			r.index.mu.Lock()
+			r.index.putLocked(record.Registration, now, now.Add(record.TTL))
+			r.index.mu.Unlock()
+			return nil
+		})
+	})
+}
+
+// Put stores a registration on disk and in the index
+func (r *BoltRegistry) Put(ctx context.Context, reg *voyagerv1.Registration, ttl time.Duration) (int64, error) {
+	data, err := json.Marshal(boltRecord{Registration: reg, TTL: ttl})
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(instanceKey(reg.ServiceName, reg.InstanceId)), data)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return r.index.Put(ctx, reg, ttl)
+}
+
+// Get returns a single registration
+func (r *BoltRegistry) Get(ctx context.Context, serviceName, instanceID string) (*voyagerv1.Registration, error) {
+	return r.index.Get(ctx, serviceName, instanceID)
+}
+
+// List returns registrations of a service or of all services
+func (r *BoltRegistry) List(ctx context.Context, serviceName string) ([]*voyagerv1.Registration, error) {
+	return r.index.List(ctx, serviceName)
+}
+
+// Delete removes a registration from disk and the index
+func (r *BoltRegistry) Delete(ctx context.Context, serviceName, instanceID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.index.Get(ctx, serviceName, instanceID); err != nil {
+		return 0, err
+	}
+
+	if err := r.deleteRecords([]*voyagerv1.Registration{{ServiceName: serviceName, InstanceId: instanceID}}); err != nil {
+		return 0, err
+	}
+	return r.index.Delete(ctx, serviceName, instanceID)
+}
+
+// KeepAlive extends the in-memory expiry of a registration
+func (r *BoltRegistry) KeepAlive(ctx context.Context, serviceName, instanceID string, ttl time.Duration) error {
+	return r.index.KeepAlive(ctx, serviceName, instanceID, ttl)
+}
+
+// LastSeen returns the last heartbeat time of every instance
+func (r *BoltRegistry) LastSeen(ctx context.Context) (map[string]time.Time, error) {
+	return r.index.LastSeen(ctx)
+}
+
+// Expire removes expired registrations from the index and disk
+func (r *BoltRegistry) Expire(ctx context.Context, now time.Time) ([]*voyagerv1.Registration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expired, err := r.index.Expire(ctx, now)
+	if err != nil || len(expired) == 0 {
+		return expired, err
+	}
+	return expired, r.deleteRecords(expired)
+}
+
+// deleteRecords removes registrations from disk
+func (r *BoltRegistry) deleteRecords(regs []*voyagerv1.Registration) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, reg := range regs {
+			if err := bucket.Delete([]byte(instanceKey(reg.ServiceName, reg.InstanceId))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Watch streams registry changes starting with a reset snapshot
+func (r *BoltRegistry) Watch(ctx context.Context) (<-chan RegistryEvent, error) {
+	return r.index.Watch(ctx)
+}
+
+// Close stops watchers and closes the database
+func (r *BoltRegistry) Close() error {
+	if err := r.index.Close(); err != nil {
+		return err
+	}
+	return r.db.Close()
+}
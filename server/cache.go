@@ -3,72 +3,137 @@ package server
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"time"
 
-	clientv3 "go.etcd.io/etcd/client/v3"
-
 	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
 )
 
-// refreshCache loads data from etcd into the in-memory cache
-func (s *Server) refreshCache() {
-	cacheRefreshCounter.Inc()
-
-	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
-	defer cancel()
+// registrySyncTimeout bounds the wait for the first registry snapshot
+const registrySyncTimeout = 5 * time.Second
 
-	resp, err := s.etcdClient.Get(ctx, "/services/", clientv3.WithPrefix())
+// syncRegistry subscribes to registry changes, loads the initial snapshot and
+// keeps the cache in sync in the background
+func (s *Server) syncRegistry() error {
+	events, err := s.registry.Watch(s.ctx)
 	if err != nil {
-		// Handle context cancellation gracefully
-		if err == context.Canceled {
-			log.Println("Cache refresh canceled, server shutting down")
-			return
+		return err
+	}
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			return context.Canceled
 		}
+		s.applyRegistryEvent(event)
+	case <-time.After(registrySyncTimeout):
+		return context.DeadlineExceeded
+	}
 
-		cacheRefreshErrors.Inc()
-		log.Printf("Failed to refresh cache: %v", err)
-		return
+	go s.consumeRegistryEvents(events)
+	return nil
+}
+
+// consumeRegistryEvents applies registry events until the watch ends
+func (s *Server) consumeRegistryEvents(events <-chan RegistryEvent) {
+	for event := range events {
+		s.applyRegistryEvent(event)
 	}
 
-	newCache := make(map[string]map[string]*voyagerv1.Registration)
+	if s.ctx.Err() == nil {
+		log.Println("WARNING: registry watch closed, cache is no longer updated")
+	}
+}
 
-	for _, kv := range resp.Kvs {
-		var reg voyagerv1.Registration
-		if err := json.Unmarshal(kv.Value, &reg); err != nil {
-			log.Printf("Failed to unmarshal registration: %v", err)
-			continue
-		}
+// applyRegistryEvent applies a single registry event to the cache
+func (s *Server) applyRegistryEvent(event RegistryEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch event.Type {
+	case RegistryReset:
+		s.resetLocked(event.Snapshot, event.Revision)
+	case RegistryPut:
+		s.applyPutLocked(event.Registration, event.Revision)
+	case RegistryDelete:
+		s.applyDeleteLocked(event.Registration.ServiceName, event.Registration.InstanceId, event.Revision)
+	}
+}
 
-		if _, exists := newCache[reg.ServiceName]; !exists {
-			newCache[reg.ServiceName] = make(map[string]*voyagerv1.Registration)
+// resetLocked replaces the cache with a registry snapshot taken at revision.
+// Heartbeat times of instances that are still present are kept. Callers must
+// hold s.mu for writing.
+func (s *Server) resetLocked(snapshot []*voyagerv1.Registration, revision int64) {
+	now := time.Now()
+	newInstances := make(map[string]map[string]*instanceInfo)
+
+	for _, reg := range snapshot {
+		if _, exists := newInstances[reg.ServiceName]; !exists {
+			newInstances[reg.ServiceName] = make(map[string]*instanceInfo)
 		}
 
-		newCache[reg.ServiceName][reg.InstanceId] = &reg
+		lastSeen := now
+		if info, exists := s.instances[reg.ServiceName][reg.InstanceId]; exists {
+			lastSeen = info.lastSeen
+		}
+		newInstances[reg.ServiceName][reg.InstanceId] = s.newInstanceInfo(reg, lastSeen)
 	}
 
-	s.mu.Lock()
-	s.services = newCache
-	s.mu.Unlock()
+	s.publishDiff(s.instances, newInstances)
+	s.instances = newInstances
+	s.revisions = make(map[string]int64)
+	s.baseRevision = revision
+}
+
+// knownRevisionLocked returns the last revision applied for an instance.
+// Callers must hold s.mu.
+func (s *Server) knownRevisionLocked(key string) int64 {
+	if revision, exists := s.revisions[key]; exists {
+		return revision
+	}
+	return s.baseRevision
 }
 
-// startCacheRefresher starts periodic cache refresher
-func (s *Server) startCacheRefresher() {
-	if s.inMemory {
+// applyPutLocked stores a registration unless a newer write for the instance
+// was already applied. Callers must hold s.mu for writing.
+func (s *Server) applyPutLocked(reg *voyagerv1.Registration, revision int64) {
+	key := instanceKey(reg.ServiceName, reg.InstanceId)
+	if revision <= s.knownRevisionLocked(key) {
 		return
 	}
+	s.revisions[key] = revision
 
-	ticker := time.NewTicker(s.cacheTTL / 2)
-	defer ticker.Stop()
+	if _, exists := s.instances[reg.ServiceName]; !exists {
+		s.instances[reg.ServiceName] = make(map[string]*instanceInfo)
+	}
 
-	for {
-		select {
-		case <-ticker.C:
-			s.refreshCache()
-		case <-s.ctx.Done():
-			log.Println("Stopping cache refresher, server shutting down")
-			return
+	var previous *voyagerv1.Registration
+	if info, exists := s.instances[reg.ServiceName][reg.InstanceId]; exists {
+		previous = info.registration
+	}
+
+	// Every write refreshes the TTL, so it counts as a heartbeat
+	info := s.newInstanceInfo(reg, time.Now())
+	s.instances[reg.ServiceName][reg.InstanceId] = info
+	s.publishRegistration(previous, info.registration)
+}
+
+// applyDeleteLocked removes a registration unless a newer write for the
+// instance was already applied. Callers must hold s.mu for writing.
+func (s *Server) applyDeleteLocked(serviceName, instanceID string, revision int64) {
+	key := instanceKey(serviceName, instanceID)
+	if revision < s.knownRevisionLocked(key) {
+		return
+	}
+	delete(s.revisions, key)
+
+	if service, exists := s.instances[serviceName]; exists {
+		if info, exists := service[instanceID]; exists {
+			delete(service, instanceID)
+			s.hub.publish(voyagerv1.ServiceEvent_REMOVED, info.registration)
+		}
+		if len(service) == 0 {
+			delete(s.instances, serviceName)
 		}
 	}
 }
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+const (
+	// defaultServicesPageSize is used when ListServicesRequest.page_size is 0
+	defaultServicesPageSize = 100
+	// maxServicesPageSize caps ListServicesRequest.page_size
+	maxServicesPageSize = 1000
+)
+
+// ListServices returns the service catalog ordered by name. Pages continue
+// after the last service of the previous page, so services added or removed
+// between calls never shift the remaining pages.
+func (s *Server) ListServices(_ context.Context, req *voyagerv1.ListServicesRequest) (*voyagerv1.ListServicesResponse, error) {
+	if req.PageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size cannot be negative")
+	}
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize == 0:
+		pageSize = defaultServicesPageSize
+	case pageSize > maxServicesPageSize:
+		pageSize = maxServicesPageSize
+	}
+
+	after, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.instances))
+	for name, instances := range s.instances {
+		if len(instances) == 0 || !strings.HasPrefix(name, req.Prefix) || name <= after {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp := &voyagerv1.ListServicesResponse{}
+	if len(names) > pageSize {
+		names = names[:pageSize]
+		resp.NextPageToken = encodePageToken(names[pageSize-1])
+	}
+	for _, name := range names {
+		resp.Services = append(resp.Services, summarizeService(name, s.instances[name]))
+	}
+	return resp, nil
+}
+
+// summarizeService counts the instances of a service and collects their
+// distinct metadata values
+func summarizeService(name string, instances map[string]*instanceInfo) *voyagerv1.ServiceSummary {
+	summary := &voyagerv1.ServiceSummary{
+		Name:          name,
+		InstanceCount: int32(len(instances)),
+		Metadata:      make(map[string]*voyagerv1.MetadataValues),
+	}
+
+	values := make(map[string]map[string]struct{})
+	for _, info := range instances {
+		if isHealthy(info.registration) {
+			summary.HealthyCount++
+		}
+		for key, value := range info.registration.Metadata {
+			if values[key] == nil {
+				values[key] = make(map[string]struct{})
+			}
+			values[key][value] = struct{}{}
+		}
+	}
+
+	for key, set := range values {
+		distinct := make([]string, 0, len(set))
+		for value := range set {
+			distinct = append(distinct, value)
+		}
+		sort.Strings(distinct)
+		summary.Metadata[key] = &voyagerv1.MetadataValues{Values: distinct}
+	}
+	return summary
+}
+
+// encodePageToken returns an opaque token continuing after a service name
+func encodePageToken(after string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(after))
+}
+
+// decodePageToken returns the service name a page token continues after
+func decodePageToken(token string) (string, error) {
+	after, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	return string(after), nil
+}
@@ -0,0 +1,492 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+const (
+	// DNSDomain is the zone answered by DNSServer
+	DNSDomain = "service.voyager."
+
+	// dnsUDPSize is the response limit for UDP queries without EDNS0
+	dnsUDPSize = 512
+	// dnsMaxSize is the largest message accepted or sent
+	dnsMaxSize = 65535
+	// dnsTCPTimeout bounds idle TCP connections
+	dnsTCPTimeout = 10 * time.Second
+)
+
+// DNSServer answers queries for <service>.service.voyager. from the server's
+// instance cache: A and AAAA records for instance addresses and SRV records
+// carrying Registration.port. Only HEALTHY instances are returned, so
+// instances drain out of DNS by reporting themselves UNHEALTHY. SRV targets
+// of IP registrations are <instance>.<service>.service.voyager. names, which
+// resolve to that single instance.
+type DNSServer struct {
+	server *Server
+
+	mu     sync.Mutex
+	udp    net.PacketConn
+	tcp    net.Listener
+	closed bool
+}
+
+// DNSServer returns a DNS server backed by this server's registry. Record
+// TTLs follow Config.CacheTTL.
+func (s *Server) DNSServer() *DNSServer {
+	return &DNSServer{server: s}
+}
+
+// ListenAndServe answers DNS queries on addr over UDP and TCP until Close
+func (d *DNSServer) ListenAndServe(addr string) error {
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		udp.Close()
+		return err
+	}
+	return d.Serve(udp, tcp)
+}
+
+// Serve answers DNS queries on the given listeners until Close. Either may
+// be nil.
+func (d *DNSServer) Serve(udp net.PacketConn, tcp net.Listener) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return net.ErrClosed
+	}
+	d.udp, d.tcp = udp, tcp
+	d.mu.Unlock()
+
+	errCh := make(chan error, 2)
+	if udp != nil {
+		go func() { errCh <- d.serveUDP(udp) }()
+	}
+	if tcp != nil {
+		go func() { errCh <- d.serveTCP(tcp) }()
+	}
+	if udp == nil && tcp == nil {
+		return errors.New("no DNS listeners")
+	}
+
+	err := <-errCh
+	closed := d.isClosed()
+	d.Close()
+	if closed {
+		return nil
+	}
+	return err
+}
+
+// Close stops the listeners
+func (d *DNSServer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	var err error
+	if d.udp != nil {
+		err = d.udp.Close()
+	}
+	if d.tcp != nil {
+		if tcpErr := d.tcp.Close(); err == nil {
+			err = tcpErr
+		}
+	}
+	return err
+}
+
+func (d *DNSServer) isClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+// serveUDP answers datagram queries
+func (d *DNSServer) serveUDP(conn net.PacketConn) error {
+	buf := make([]byte, dnsMaxSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		resp := d.answer(buf[:n], false)
+		if resp == nil {
+			continue
+		}
+		if _, err := conn.WriteTo(resp, addr); err != nil {
+			log.Printf("Failed to send DNS response to %s: %v", addr, err)
+		}
+	}
+}
+
+// serveTCP accepts connections carrying length-prefixed queries
+func (d *DNSServer) serveTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go d.serveTCPConn(conn)
+	}
+}
+
+// serveTCPConn answers queries on one connection until it goes idle
+func (d *DNSServer) serveTCPConn(conn net.Conn) {
+	defer conn.Close()
+
+	var length [2]byte
+	for {
+		if err := conn.SetDeadline(time.Now().Add(dnsTCPTimeout)); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+
+		resp := d.answer(query, true)
+		if resp == nil {
+			return
+		}
+		binary.BigEndian.PutUint16(length[:], uint16(len(resp)))
+		if _, err := conn.Write(append(length[:], resp...)); err != nil {
+			return
+		}
+	}
+}
+
+// answer builds the response to a raw query, or returns nil when the query
+// cannot be parsed far enough to reply
+func (d *DNSServer) answer(query []byte, overTCP bool) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil || header.Response {
+		return nil
+	}
+
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               header.ID,
+			Response:         true,
+			OpCode:           header.OpCode,
+			Authoritative:    true,
+			RecursionDesired: header.RecursionDesired,
+		},
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		resp.RCode = dnsmessage.RCodeFormatError
+		return d.pack(resp, dnsUDPSize)
+	}
+	resp.Questions = []dnsmessage.Question{question}
+
+	maxSize := dnsUDPSize
+	size, edns := ednsSize(&parser)
+	if overTCP {
+		maxSize = dnsMaxSize
+	} else if edns {
+		maxSize = size
+	}
+
+	if header.OpCode != 0 {
+		resp.RCode = dnsmessage.RCodeNotImplemented
+	} else {
+		d.resolve(&resp, question)
+	}
+	dnsQueriesCounter.WithLabelValues(question.Type.String(), resp.RCode.String()).Inc()
+
+	if edns {
+		opt := dnsmessage.Resource{Body: &dnsmessage.OPTResource{}}
+		if err := opt.Header.SetEDNS0(maxSize, resp.RCode, false); err == nil {
+			resp.Additionals = append(resp.Additionals, opt)
+		}
+	}
+
+	return d.pack(resp, maxSize)
+}
+
+// pack serializes a response, truncating it to fit maxSize
+func (d *DNSServer) pack(resp dnsmessage.Message, maxSize int) []byte {
+	packed, err := resp.Pack()
+	if err != nil {
+		log.Printf("Failed to pack DNS response: %v", err)
+		resp.Answers, resp.Authorities, resp.Additionals = nil, nil, nil
+		resp.RCode = dnsmessage.RCodeServerFailure
+		packed, _ = resp.Pack()
+		return packed
+	}
+	if len(packed) <= maxSize {
+		return packed
+	}
+
+	// Drop records until the answer fits; the client retries over TCP
+	resp.Truncated = true
+	resp.Authorities = nil
+	var opt []dnsmessage.Resource
+	for _, additional := range resp.Additionals {
+		if additional.Header.Type == dnsmessage.TypeOPT {
+			opt = append(opt, additional)
+		}
+	}
+	resp.Additionals = opt
+	for len(resp.Answers) > 0 {
+		resp.Answers = resp.Answers[:len(resp.Answers)-1]
+		if packed, err = resp.Pack(); err == nil && len(packed) <= maxSize {
+			return packed
+		}
+	}
+	packed, _ = resp.Pack()
+	return packed
+}
+
+// resolve fills in the answer to a single question
+func (d *DNSServer) resolve(resp *dnsmessage.Message, question dnsmessage.Question) {
+	name := strings.ToLower(question.Name.String())
+	if question.Class != dnsmessage.ClassINET && question.Class != dnsmessage.ClassANY {
+		resp.RCode = dnsmessage.RCodeRefused
+		return
+	}
+
+	if name == DNSDomain {
+		if question.Type == dnsmessage.TypeSOA {
+			resp.Answers = append(resp.Answers, d.soa())
+		} else {
+			resp.Authorities = append(resp.Authorities, d.soa())
+		}
+		return
+	}
+
+	relative, ok := strings.CutSuffix(name, "."+DNSDomain)
+	if !ok {
+		resp.RCode = dnsmessage.RCodeRefused
+		return
+	}
+
+	instances, found := d.lookup(relative)
+	if !found {
+		resp.RCode = dnsmessage.RCodeNameError
+		resp.Authorities = append(resp.Authorities, d.soa())
+		return
+	}
+
+	ttl := d.ttl()
+	rand.Shuffle(len(instances), func(i, j int) {
+		instances[i], instances[j] = instances[j], instances[i]
+	})
+
+	for _, inst := range instances {
+		ip := net.ParseIP(inst.Address)
+		header := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: ttl}
+
+		switch question.Type {
+		case dnsmessage.TypeA, dnsmessage.TypeAAAA, dnsmessage.TypeALL:
+			if ip != nil && (question.Type != dnsmessage.TypeAAAA || ip.To4() == nil) &&
+				(question.Type != dnsmessage.TypeA || ip.To4() != nil) {
+				resp.Answers = append(resp.Answers, addressRecord(header, ip))
+			}
+		case dnsmessage.TypeSRV:
+			target, err := d.srvTarget(inst, ip)
+			if err != nil {
+				log.Printf("Skipping DNS SRV record for instance %s/%s: %v", inst.ServiceName, inst.InstanceId, err)
+				continue
+			}
+			header.Type = dnsmessage.TypeSRV
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{
+				Header: header,
+				Body: &dnsmessage.SRVResource{
+					Priority: 1,
+					Weight:   srvWeight(inst),
+					Port:     uint16(inst.Port),
+					Target:   target,
+				},
+			})
+			if ip != nil {
+				resp.Additionals = append(resp.Additionals, addressRecord(
+					dnsmessage.ResourceHeader{Name: target, Class: dnsmessage.ClassINET, TTL: ttl}, ip))
+			}
+		}
+	}
+
+	if len(resp.Answers) == 0 {
+		resp.Authorities = append(resp.Authorities, d.soa())
+	}
+}
+
+// lookup returns the instances taking traffic named by <service> or
+// <instance>.<service> relative to DNSDomain
+func (d *DNSServer) lookup(relative string) ([]*voyagerv1.Registration, bool) {
+	s := d.server
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if instances, ok := s.dnsService(relative); ok {
+		var healthy []*voyagerv1.Registration
+		for _, info := range instances {
+			if receivesTraffic(info.registration) {
+				healthy = append(healthy, info.registration)
+			}
+		}
+		return healthy, true
+	}
+
+	label, serviceName, ok := strings.Cut(relative, ".")
+	if !ok {
+		return nil, false
+	}
+	instances, ok := s.dnsService(serviceName)
+	if !ok {
+		return nil, false
+	}
+	for _, info := range instances {
+		if dnsLabel(info.registration.InstanceId) != label {
+			continue
+		}
+		if receivesTraffic(info.registration) {
+			return []*voyagerv1.Registration{info.registration}, true
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+// dnsService finds a service by its case-insensitive DNS name. Callers must
+// hold s.mu.
+func (s *Server) dnsService(name string) (map[string]*instanceInfo, bool) {
+	if instances, ok := s.instances[name]; ok {
+		return instances, true
+	}
+	for serviceName, instances := range s.instances {
+		if strings.EqualFold(serviceName, name) {
+			return instances, true
+		}
+	}
+	return nil, false
+}
+
+// srvTarget names the host of an SRV record
+func (d *DNSServer) srvTarget(inst *voyagerv1.Registration, ip net.IP) (dnsmessage.Name, error) {
+	if ip == nil {
+		return dnsmessage.NewName(strings.TrimSuffix(inst.Address, ".") + ".")
+	}
+	return dnsmessage.NewName(dnsLabel(inst.InstanceId) + "." + strings.ToLower(inst.ServiceName) + "." + DNSDomain)
+}
+
+// soa is the zone's start of authority, returned with negative answers
+func (d *DNSServer) soa() dnsmessage.Resource {
+	ttl := d.ttl()
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName(DNSDomain),
+			Type:  dnsmessage.TypeSOA,
+			Class: dnsmessage.ClassINET,
+			TTL:   ttl,
+		},
+		Body: &dnsmessage.SOAResource{
+			NS:      dnsmessage.MustNewName("ns." + DNSDomain),
+			MBox:    dnsmessage.MustNewName("hostmaster." + DNSDomain),
+			Serial:  uint32(time.Now().Unix()),
+			Refresh: ttl,
+			Retry:   ttl,
+			Expire:  ttl,
+			MinTTL:  ttl,
+		},
+	}
+}
+
+// ttl returns the record TTL derived from the cache TTL
+func (d *DNSServer) ttl() uint32 {
+	seconds := uint32(d.server.cacheTTL / time.Second)
+	if seconds == 0 {
+		return 1
+	}
+	return seconds
+}
+
+// srvWeight caps the instance weight to the 16 bit SRV weight field
+func srvWeight(reg *voyagerv1.Registration) uint16 {
+	if weight := instanceWeight(reg); weight < math.MaxUint16 {
+		return uint16(weight)
+	}
+	return math.MaxUint16
+}
+
+// addressRecord returns an A or AAAA record for ip
+func addressRecord(header dnsmessage.ResourceHeader, ip net.IP) dnsmessage.Resource {
+	if ip4 := ip.To4(); ip4 != nil {
+		header.Type = dnsmessage.TypeA
+		body := &dnsmessage.AResource{}
+		copy(body.A[:], ip4)
+		return dnsmessage.Resource{Header: header, Body: body}
+	}
+
+	header.Type = dnsmessage.TypeAAAA
+	body := &dnsmessage.AAAAResource{}
+	copy(body.AAAA[:], ip.To16())
+	return dnsmessage.Resource{Header: header, Body: body}
+}
+
+// ednsSize returns the UDP payload size advertised by an EDNS0 OPT record
+func ednsSize(parser *dnsmessage.Parser) (int, bool) {
+	if err := parser.SkipAllQuestions(); err != nil {
+		return 0, false
+	}
+	if err := parser.SkipAllAnswers(); err != nil {
+		return 0, false
+	}
+	if err := parser.SkipAllAuthorities(); err != nil {
+		return 0, false
+	}
+	for {
+		header, err := parser.AdditionalHeader()
+		if err != nil {
+			return 0, false
+		}
+		if header.Type == dnsmessage.TypeOPT {
+			size := int(header.Class)
+			if size < dnsUDPSize {
+				size = dnsUDPSize
+			}
+			return size, true
+		}
+		if err := parser.SkipAdditional(); err != nil {
+			return 0, false
+		}
+	}
+}
+
+// dnsLabel turns an instance ID into a DNS label
+func dnsLabel(id string) string {
+	label := []byte(strings.ToLower(id))
+	for i, c := range label {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			label[i] = '-'
+		}
+	}
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return string(label)
+}
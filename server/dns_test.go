@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+	"google.golang.org/protobuf/proto"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// TestDNSServer tests A, AAAA and SRV answers
+func TestDNSServer(t *testing.T) {
+	srv := createInMemoryServer(t)
+	defer srv.Close()
+
+	for _, reg := range []*voyagerv1.Registration{
+		{ServiceName: "payment-service", InstanceId: "instance-1", Address: "10.0.0.1", Port: 8080},
+		{ServiceName: "payment-service", InstanceId: "instance-2", Address: "fd00::2", Port: 8081},
+		{ServiceName: "payment-service", InstanceId: "instance-3", Address: "payments.internal", Port: 8082},
+		{ServiceName: "payment-service", InstanceId: "instance-4", Address: "10.0.0.4", Port: 8083},
+		{ServiceName: "payment-service", InstanceId: "instance-5", Address: "10.0.0.5", Port: 8084, Weight: proto.Uint32(0)},
+	} {
+		_, err := srv.Register(context.Background(), reg)
+		require.NoError(t, err)
+	}
+	_, err := srv.HealthCheck(context.Background(), &voyagerv1.HealthRequest{
+		ServiceName: "payment-service",
+		InstanceId:  "instance-4",
+		Status:      voyagerv1.HealthResponse_UNHEALTHY,
+	})
+	require.NoError(t, err)
+
+	dns := srv.DNSServer()
+	query := func(name string, qtype dnsmessage.Type) *dnsmessage.Message {
+		msg := dnsmessage.Message{
+			Header: dnsmessage.Header{ID: 42, RecursionDesired: true},
+			Questions: []dnsmessage.Question{{
+				Name:  dnsmessage.MustNewName(name),
+				Type:  qtype,
+				Class: dnsmessage.ClassINET,
+			}},
+		}
+		packed, err := msg.Pack()
+		require.NoError(t, err)
+
+		resp := &dnsmessage.Message{}
+		require.NoError(t, resp.Unpack(dns.answer(packed, false)))
+		assert.Equal(t, uint16(42), resp.ID)
+		assert.True(t, resp.Authoritative)
+		return resp
+	}
+
+	t.Run("A", func(t *testing.T) {
+		resp := query("payment-service.service.voyager.", dnsmessage.TypeA)
+		require.Equal(t, dnsmessage.RCodeSuccess, resp.RCode)
+		require.Len(t, resp.Answers, 1)
+		assert.Equal(t, [4]byte{10, 0, 0, 1}, resp.Answers[0].Body.(*dnsmessage.AResource).A)
+		assert.Equal(t, uint32(60), resp.Answers[0].Header.TTL)
+	})
+
+	t.Run("AAAA", func(t *testing.T) {
+		resp := query("Payment-Service.service.voyager.", dnsmessage.TypeAAAA)
+		require.Equal(t, dnsmessage.RCodeSuccess, resp.RCode)
+		require.Len(t, resp.Answers, 1)
+		assert.Equal(t, net.ParseIP("fd00::2").To16(), net.IP(resp.Answers[0].Body.(*dnsmessage.AAAAResource).AAAA[:]))
+	})
+
+	t.Run("SRV", func(t *testing.T) {
+		resp := query("payment-service.service.voyager.", dnsmessage.TypeSRV)
+		require.Equal(t, dnsmessage.RCodeSuccess, resp.RCode)
+		require.Len(t, resp.Answers, 3)
+
+		targets := make(map[string]uint16)
+		for _, answer := range resp.Answers {
+			srv := answer.Body.(*dnsmessage.SRVResource)
+			targets[srv.Target.String()] = srv.Port
+		}
+		assert.Equal(t, map[string]uint16{
+			"instance-1.payment-service.service.voyager.": 8080,
+			"instance-2.payment-service.service.voyager.": 8081,
+			"payments.internal.":                          8082,
+		}, targets)
+		assert.Len(t, resp.Additionals, 2)
+	})
+
+	t.Run("Instance", func(t *testing.T) {
+		resp := query("instance-1.payment-service.service.voyager.", dnsmessage.TypeA)
+		require.Equal(t, dnsmessage.RCodeSuccess, resp.RCode)
+		require.Len(t, resp.Answers, 1)
+
+		resp = query("instance-4.payment-service.service.voyager.", dnsmessage.TypeA)
+		assert.Equal(t, dnsmessage.RCodeSuccess, resp.RCode)
+		assert.Empty(t, resp.Answers)
+	})
+
+	t.Run("Negative answers", func(t *testing.T) {
+		resp := query("missing.service.voyager.", dnsmessage.TypeA)
+		assert.Equal(t, dnsmessage.RCodeNameError, resp.RCode)
+		require.Len(t, resp.Authorities, 1)
+		assert.Equal(t, dnsmessage.TypeSOA, resp.Authorities[0].Header.Type)
+
+		resp = query("payment-service.service.voyager.", dnsmessage.TypeMX)
+		assert.Equal(t, dnsmessage.RCodeSuccess, resp.RCode)
+		assert.Empty(t, resp.Answers)
+
+		resp = query("example.com.", dnsmessage.TypeA)
+		assert.Equal(t, dnsmessage.RCodeRefused, resp.RCode)
+	})
+
+	t.Run("Truncation", func(t *testing.T) {
+		for i := 0; i < 40; i++ {
+			_, err := srv.Register(context.Background(), &voyagerv1.Registration{
+				ServiceName: "large-service",
+				InstanceId:  "instance-" + string(rune('a'+i%26)) + string(rune('a'+i/26)),
+				Address:     net.IPv4(10, 1, 0, byte(i)).String(),
+				Port:        8080,
+			})
+			require.NoError(t, err)
+		}
+
+		resp := query("large-service.service.voyager.", dnsmessage.TypeSRV)
+		assert.True(t, resp.Truncated)
+		assert.Less(t, len(resp.Answers), 40)
+	})
+
+	t.Run("Network", func(t *testing.T) {
+		udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+		require.NoError(t, err)
+
+		served := make(chan error, 1)
+		go func() { served <- dns.Serve(udp, tcp) }()
+
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, udp.LocalAddr().String())
+			},
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		addrs, err := resolver.LookupHost(ctx, "payment-service.service.voyager.")
+		require.NoError(t, err)
+		sort.Strings(addrs)
+		assert.Equal(t, []string{"10.0.0.1", "fd00::2"}, addrs)
+
+		// 40 SRV records do not fit in UDP and are fetched over TCP
+		_, records, err := resolver.LookupSRV(ctx, "", "", "large-service.service.voyager.")
+		require.NoError(t, err)
+		assert.Len(t, records, 40)
+
+		require.NoError(t, dns.Close())
+		select {
+		case err := <-served:
+			assert.NoError(t, err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("DNS server did not stop")
+		}
+	})
+}
@@ -9,6 +9,8 @@ import (
 )
 
 // EtcdAdapter provides abstraction for ETCD operations
+//
+// Deprecated: use EtcdRegistry, which implements Registry.
 type EtcdAdapter struct {
 	client *clientv3.Client
 }
@@ -0,0 +1,616 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+const (
+	// servicesPrefix is the etcd key prefix for all registrations
+	servicesPrefix = "/services/"
+	// etcdRelistInterval is the delay between failed re-list attempts
+	etcdRelistInterval = 5 * time.Second
+	// etcdRewatchDelay is the delay before resuming an interrupted watch
+	etcdRewatchDelay = time.Second
+	// heartbeatsPrefix holds the heartbeats each server recently handled
+	heartbeatsPrefix = "/heartbeats/"
+	// heartbeatShareDelay batches the heartbeats a server shares
+	heartbeatShareDelay = time.Second
+	// replicaLeaseTTL bounds how long the heartbeats of a stopped server stay
+	replicaLeaseTTL = time.Minute
+)
+
+// serviceKey returns the etcd key of a service instance
+func serviceKey(serviceName, instanceID string) string {
+	return fmt.Sprintf("%s%s/%s", servicesPrefix, serviceName, instanceID)
+}
+
+// parseServiceKey splits an etcd key into service name and instance ID
+func parseServiceKey(key string) (serviceName, instanceID string, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(key, servicesPrefix), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// EtcdRegistry stores registrations in etcd. Each instance owns one lease
+// that heartbeats keep alive, so expiry is handled by etcd itself. Heartbeat
+// times are tracked locally; every server shares the ones it handled in one
+// batched key per second, which the other servers watch.
+type EtcdRegistry struct {
+	client       *clientv3.Client
+	replicaID    string
+	mu           sync.Mutex
+	leases       map[string]clientv3.LeaseID
+	seen         map[string]time.Time // last heartbeat per instance key, from any server
+	pending      map[string]time.Time // heartbeats handled here and not shared yet
+	shareTimer   *time.Timer
+	replicaLease clientv3.LeaseID // lease of the shared heartbeats key
+	closed       bool
+}
+
+// NewEtcdRegistry connects to etcd
+func NewEtcdRegistry(endpoints []string) (*EtcdRegistry, error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 2 * time.Second, // Shorter timeout
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &EtcdRegistry{
+		client:    cli,
+		replicaID: fmt.Sprintf("%x", rand.Uint64()),
+		leases:    make(map[string]clientv3.LeaseID),
+		seen:      make(map[string]time.Time),
+		pending:   make(map[string]time.Time),
+	}, nil
+}
+
+// Put stores a registration, reusing the instance lease while it is alive
+func (r *EtcdRegistry) Put(ctx context.Context, reg *voyagerv1.Registration, ttl time.Duration) (int64, error) {
+	key := serviceKey(reg.ServiceName, reg.InstanceId)
+
+	jsonData, err := json.Marshal(reg)
+	if err != nil {
+		return 0, err
+	}
+
+	leaseID, err := r.ensureLease(ctx, key, ttl)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create lease: %w", err)
+	}
+
+	putResp, err := r.client.Put(ctx, key, string(jsonData), clientv3.WithLease(leaseID))
+	if err != nil {
+		return 0, err
+	}
+
+	r.setLease(key, leaseID)
+	r.recordHeartbeat(key)
+	return putResp.Header.Revision, nil
+}
+
+// Get returns a single registration
+func (r *EtcdRegistry) Get(ctx context.Context, serviceName, instanceID string) (*voyagerv1.Registration, error) {
+	resp, err := r.client.Get(ctx, serviceKey(serviceName, instanceID))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrInstanceNotFound
+	}
+
+	var reg voyagerv1.Registration
+	if err := json.Unmarshal(resp.Kvs[0].Value, &reg); err != nil {
+		return nil, err
+	}
+	return &reg, nil
+}
+
+// List returns registrations of a service or of all services
+func (r *EtcdRegistry) List(ctx context.Context, serviceName string) ([]*voyagerv1.Registration, error) {
+	prefix := servicesPrefix
+	if serviceName != "" {
+		prefix = servicesPrefix + serviceName + "/"
+	}
+
+	list, _, err := r.list(ctx, prefix)
+	return list, err
+}
+
+// list reads registrations under a prefix and returns the read revision
+func (r *EtcdRegistry) list(ctx context.Context, prefix string) ([]*voyagerv1.Registration, int64, error) {
+	resp, err := r.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	list := make([]*voyagerv1.Registration, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var reg voyagerv1.Registration
+		if err := json.Unmarshal(kv.Value, &reg); err != nil {
+			log.Printf("Failed to unmarshal registration: %v", err)
+			continue
+		}
+		r.setLease(string(kv.Key), clientv3.LeaseID(kv.Lease))
+		list = append(list, &reg)
+	}
+	return list, resp.Header.Revision, nil
+}
+
+// Delete removes a registration and revokes its lease
+func (r *EtcdRegistry) Delete(ctx context.Context, serviceName, instanceID string) (int64, error) {
+	key := serviceKey(serviceName, instanceID)
+
+	delResp, err := r.client.Delete(ctx, key, clientv3.WithPrevKV())
+	if err != nil {
+		return 0, err
+	}
+
+	r.takeLease(key)
+	if len(delResp.PrevKvs) == 0 {
+		return 0, ErrInstanceNotFound
+	}
+
+	// The deleted value names the lease; the watch may have already
+	// forgotten it
+	r.revokeLease(ctx, clientv3.LeaseID(delResp.PrevKvs[0].Lease))
+	return delResp.Header.Revision, nil
+}
+
+// KeepAlive refreshes the instance lease without rewriting the key
+func (r *EtcdRegistry) KeepAlive(ctx context.Context, serviceName, instanceID string, _ time.Duration) error {
+	key := serviceKey(serviceName, instanceID)
+
+	leaseID, err := r.leaseFor(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	err = r.keepAliveLease(ctx, leaseID)
+	if errors.Is(err, ErrInstanceNotFound) {
+		r.takeLease(key)
+	}
+	if err == nil {
+		r.recordHeartbeat(key)
+	}
+	return err
+}
+
+// LastSeen returns the heartbeats handled by this server and the ones other
+// servers shared through etcd while Watch runs, without querying etcd
+func (r *EtcdRegistry) LastSeen(context.Context) (map[string]time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]time.Time, len(r.seen))
+	for key, lastSeen := range r.seen {
+		serviceName, instanceID, ok := parseServiceKey(key)
+		if !ok {
+			continue
+		}
+		seen[instanceKey(serviceName, instanceID)] = lastSeen
+	}
+	return seen, nil
+}
+
+// recordHeartbeat notes a heartbeat handled by this server and schedules
+// sharing it with the others
+func (r *EtcdRegistry) recordHeartbeat(key string) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seen[key] = now
+	r.pending[key] = now
+	if r.shareTimer == nil && !r.closed {
+		r.shareTimer = time.AfterFunc(heartbeatShareDelay, r.shareHeartbeats)
+	}
+}
+
+// shareHeartbeats writes the heartbeats handled since the last call to the
+// key of this server. Lost batches are made up for by the next heartbeats.
+func (r *EtcdRegistry) shareHeartbeats() {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[string]time.Time)
+	r.shareTimer = nil
+	closed := r.closed
+	r.mu.Unlock()
+	if closed || len(pending) == 0 {
+		return
+	}
+
+	batch := make(map[string]int64, len(pending))
+	for key, lastSeen := range pending {
+		batch[key] = lastSeen.UnixNano()
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		log.Printf("Failed to encode heartbeats: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	leaseID, err := r.ensureReplicaLease(ctx)
+	if err != nil {
+		log.Printf("Failed to share heartbeats: %v", err)
+		return
+	}
+	if _, err := r.client.Put(ctx, heartbeatsPrefix+r.replicaID, string(data), clientv3.WithLease(leaseID)); err != nil {
+		log.Printf("Failed to share heartbeats: %v", err)
+	}
+}
+
+// ensureReplicaLease returns the live lease of the shared heartbeats key,
+// so the key of a stopped server goes away
+func (r *EtcdRegistry) ensureReplicaLease(ctx context.Context) (clientv3.LeaseID, error) {
+	r.mu.Lock()
+	leaseID := r.replicaLease
+	r.mu.Unlock()
+
+	if err := r.keepAliveLease(ctx, leaseID); err == nil {
+		return leaseID, nil
+	}
+
+	leaseID, err := r.grantLease(ctx, replicaLeaseTTL)
+	if err != nil {
+		return clientv3.NoLease, err
+	}
+	r.mu.Lock()
+	r.replicaLease = leaseID
+	r.mu.Unlock()
+	return leaseID, nil
+}
+
+// watchHeartbeats merges the heartbeats shared by other servers until ctx
+// is done. Batches missed while the watch restarts are made up for by the
+// next heartbeats.
+func (r *EtcdRegistry) watchHeartbeats(ctx context.Context) {
+	for {
+		watchCh := r.client.Watch(clientv3.WithRequireLeader(ctx), heartbeatsPrefix, clientv3.WithPrefix())
+		var err error
+		for resp := range watchCh {
+			if err = resp.Err(); err != nil {
+				break
+			}
+			for _, ev := range resp.Events {
+				if ev.Type == mvccpb.PUT && string(ev.Kv.Key) != heartbeatsPrefix+r.replicaID {
+					r.mergeHeartbeats(ev.Kv.Value)
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("Heartbeat watch interrupted: %v", err)
+		select {
+		case <-time.After(etcdRelistInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// mergeHeartbeats records the newer heartbeats of a batch shared by another
+// server, for instances that are still registered
+func (r *EtcdRegistry) mergeHeartbeats(data []byte) {
+	var batch map[string]int64
+	if err := json.Unmarshal(data, &batch); err != nil {
+		log.Printf("Failed to decode shared heartbeats: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, nanos := range batch {
+		if _, registered := r.leases[key]; !registered {
+			continue
+		}
+		if lastSeen := time.Unix(0, nanos); lastSeen.After(r.seen[key]) {
+			r.seen[key] = lastSeen
+		}
+	}
+}
+
+// Expire is a no-op: etcd removes keys when their lease expires
+func (r *EtcdRegistry) Expire(context.Context, time.Time) ([]*voyagerv1.Registration, error) {
+	return nil, nil
+}
+
+// Watch lists all registrations and then follows etcd watch events from that
+// revision. A disconnected watch resumes where it stopped; a compacted one
+// triggers a re-list, which is delivered as a new reset event.
+func (r *EtcdRegistry) Watch(ctx context.Context) (<-chan RegistryEvent, error) {
+	listCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	cacheRefreshCounter.Inc()
+	snapshot, revision, err := r.list(listCtx, servicesPrefix)
+	if err != nil {
+		cacheRefreshErrors.Inc()
+		return nil, err
+	}
+
+	events := make(chan RegistryEvent, watchBufferSize)
+	events <- RegistryEvent{Type: RegistryReset, Snapshot: snapshot, Revision: revision}
+
+	go r.runWatch(ctx, revision, events)
+	go r.watchHeartbeats(ctx)
+	return events, nil
+}
+
+// runWatch follows etcd watch events until ctx is done. An interrupted watch
+// resumes after the last delivered revision; only a compacted one re-lists.
+func (r *EtcdRegistry) runWatch(ctx context.Context, revision int64, events chan<- RegistryEvent) {
+	defer close(events)
+
+	for {
+		var err error
+		revision, err = r.watch(ctx, revision, events)
+		if ctx.Err() != nil {
+			log.Println("Stopping registry watcher, server shutting down")
+			return
+		}
+
+		cacheWatchRestarts.Inc()
+		if !errors.Is(err, rpctypes.ErrCompacted) {
+			log.Printf("Registry watch interrupted at revision %d: %v, resuming", revision, err)
+			select {
+			case <-time.After(etcdRewatchDelay):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		log.Printf("Registry watch interrupted at revision %d: %v, re-listing", revision, err)
+
+		var snapshot []*voyagerv1.Registration
+		for {
+			cacheRefreshCounter.Inc()
+			listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			snapshot, revision, err = r.list(listCtx, servicesPrefix)
+			cancel()
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			cacheRefreshErrors.Inc()
+			log.Printf("Failed to re-list registrations: %v", err)
+			select {
+			case <-time.After(etcdRelistInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case events <- RegistryEvent{Type: RegistryReset, Snapshot: snapshot, Revision: revision}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watch delivers etcd events after revision until the watch fails, and
+// returns the revision of the last event it delivered
+func (r *EtcdRegistry) watch(ctx context.Context, revision int64, events chan<- RegistryEvent) (int64, error) {
+	// Requiring a leader makes etcd cancel the watch on a partitioned
+	// member instead of silently serving no events.
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	watchCh := r.client.Watch(ctx, servicesPrefix,
+		clientv3.WithPrefix(),
+		clientv3.WithRev(revision+1),
+	)
+
+	for resp := range watchCh {
+		if err := resp.Err(); err != nil {
+			return revision, err
+		}
+
+		for _, ev := range resp.Events {
+			event, ok := r.convertEvent(ev)
+			if ok {
+				cacheWatchEvents.WithLabelValues(ev.Type.String()).Inc()
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return revision, ctx.Err()
+				}
+			}
+			revision = ev.Kv.ModRevision
+		}
+	}
+
+	return revision, errors.New("watch channel closed")
+}
+
+// convertEvent turns an etcd event into a registry event
+func (r *EtcdRegistry) convertEvent(ev *clientv3.Event) (RegistryEvent, bool) {
+	key := string(ev.Kv.Key)
+
+	switch ev.Type {
+	case mvccpb.PUT:
+		var reg voyagerv1.Registration
+		if err := json.Unmarshal(ev.Kv.Value, &reg); err != nil {
+			log.Printf("Failed to unmarshal registration: %v", err)
+			return RegistryEvent{}, false
+		}
+		r.setLease(key, clientv3.LeaseID(ev.Kv.Lease))
+		return RegistryEvent{Type: RegistryPut, Registration: &reg, Revision: ev.Kv.ModRevision}, true
+	case mvccpb.DELETE:
+		serviceName, instanceID, ok := parseServiceKey(key)
+		if !ok {
+			return RegistryEvent{}, false
+		}
+		r.takeLease(key)
+		return RegistryEvent{
+			Type:         RegistryDelete,
+			Registration: &voyagerv1.Registration{ServiceName: serviceName, InstanceId: instanceID},
+			Revision:     ev.Kv.ModRevision,
+		}, true
+	}
+	return RegistryEvent{}, false
+}
+
+// Close stops sharing heartbeats and releases the etcd connection
+func (r *EtcdRegistry) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	if r.shareTimer != nil {
+		r.shareTimer.Stop()
+		r.shareTimer = nil
+	}
+	replicaLease := r.replicaLease
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	r.revokeLease(ctx, replicaLease)
+
+	return r.client.Close()
+}
+
+// setLease records the lease attached to an instance key
+func (r *EtcdRegistry) setLease(key string, leaseID clientv3.LeaseID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.leases[key] = leaseID
+}
+
+// takeLease forgets and returns the lease attached to an instance key
+func (r *EtcdRegistry) takeLease(key string) clientv3.LeaseID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	leaseID := r.leases[key]
+	delete(r.leases, key)
+	delete(r.seen, key)
+	delete(r.pending, key)
+	return leaseID
+}
+
+// leaseFor returns the lease attached to an instance key, reading the key
+// when the lease was not seen yet
+func (r *EtcdRegistry) leaseFor(ctx context.Context, key string) (clientv3.LeaseID, error) {
+	r.mu.Lock()
+	leaseID, exists := r.leases[key]
+	r.mu.Unlock()
+	if exists {
+		return leaseID, nil
+	}
+
+	resp, err := r.client.Get(ctx, key)
+	if err != nil {
+		return clientv3.NoLease, err
+	}
+	if len(resp.Kvs) == 0 {
+		return clientv3.NoLease, ErrInstanceNotFound
+	}
+
+	leaseID = clientv3.LeaseID(resp.Kvs[0].Lease)
+	r.setLease(key, leaseID)
+	return leaseID, nil
+}
+
+// ensureLease returns a live lease for an instance key, reusing the existing
+// one when it can still be kept alive
+func (r *EtcdRegistry) ensureLease(ctx context.Context, key string, ttl time.Duration) (clientv3.LeaseID, error) {
+	r.mu.Lock()
+	leaseID := r.leases[key]
+	r.mu.Unlock()
+
+	err := r.keepAliveLease(ctx, leaseID)
+	if err == nil {
+		return leaseID, nil
+	}
+	if !errors.Is(err, ErrInstanceNotFound) {
+		log.Printf("Failed to keep lease %x alive, granting a new one: %v", leaseID, err)
+	}
+
+	return r.grantLease(ctx, ttl)
+}
+
+// grantLease creates a new lease lasting ttl
+func (r *EtcdRegistry) grantLease(ctx context.Context, ttl time.Duration) (clientv3.LeaseID, error) {
+	seconds := int64(ttl.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	resp, err := r.client.Grant(ctx, seconds)
+	if err != nil {
+		IncLeaseCounter("grant", "error")
+		return clientv3.NoLease, err
+	}
+	IncLeaseCounter("grant", "success")
+	return resp.ID, nil
+}
+
+// keepAliveLease refreshes a lease once. It returns ErrInstanceNotFound when
+// the lease is unknown or already expired.
+func (r *EtcdRegistry) keepAliveLease(ctx context.Context, leaseID clientv3.LeaseID) error {
+	if leaseID == clientv3.NoLease {
+		return ErrInstanceNotFound
+	}
+
+	resp, err := r.client.KeepAliveOnce(ctx, leaseID)
+	switch {
+	case errors.Is(err, rpctypes.ErrLeaseNotFound):
+		IncLeaseCounter("keepalive", "expired")
+		return ErrInstanceNotFound
+	case err != nil:
+		IncLeaseCounter("keepalive", "error")
+		return err
+	case resp.TTL <= 0:
+		IncLeaseCounter("keepalive", "expired")
+		return ErrInstanceNotFound
+	}
+
+	IncLeaseCounter("keepalive", "success")
+	return nil
+}
+
+// revokeLease releases an instance lease after deregistration
+func (r *EtcdRegistry) revokeLease(ctx context.Context, leaseID clientv3.LeaseID) {
+	if leaseID == clientv3.NoLease {
+		return
+	}
+
+	_, err := r.client.Revoke(ctx, leaseID)
+	switch {
+	case err == nil:
+		IncLeaseCounter("revoke", "success")
+	case errors.Is(err, rpctypes.ErrLeaseNotFound):
+		IncLeaseCounter("revoke", "expired")
+	default:
+		IncLeaseCounter("revoke", "error")
+		log.Printf("Failed to revoke lease %x: %v", leaseID, err)
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// instanceInfo tracks a registration and its health
+type instanceInfo struct {
+	// registration is the served copy carrying the effective health state
+	registration *voyagerv1.Registration
+	// reported is the state the instance reported itself
+	reported voyagerv1.HealthResponse_Status
+	lastSeen time.Time
+}
+
+// reportedHealth maps a self-reported state to the stored one. Instances can
+// only declare themselves unhealthy; anything else counts as healthy.
+func reportedHealth(status voyagerv1.HealthResponse_Status) voyagerv1.HealthResponse_Status {
+	if status == voyagerv1.HealthResponse_UNHEALTHY {
+		return voyagerv1.HealthResponse_UNHEALTHY
+	}
+	return voyagerv1.HealthResponse_HEALTHY
+}
+
+// newInstanceInfo tracks a stored registration last seen at lastSeen
+func (s *Server) newInstanceInfo(reg *voyagerv1.Registration, lastSeen time.Time) *instanceInfo {
+	info := &instanceInfo{
+		registration: reg,
+		reported:     reportedHealth(reg.Health),
+		lastSeen:     lastSeen,
+	}
+
+	if health := s.effectiveHealth(info, time.Now()); health != reg.Health {
+		info.registration = proto.Clone(reg).(*voyagerv1.Registration)
+		info.registration.Health = health
+	}
+	return info
+}
+
+// effectiveHealth combines the reported state with missed heartbeats
+func (s *Server) effectiveHealth(info *instanceInfo, now time.Time) voyagerv1.HealthResponse_Status {
+	switch {
+	case info.reported == voyagerv1.HealthResponse_UNHEALTHY:
+		return voyagerv1.HealthResponse_UNHEALTHY
+	case now.Sub(info.lastSeen) > s.suspectAfter:
+		return voyagerv1.HealthResponse_SUSPECT
+	default:
+		return voyagerv1.HealthResponse_HEALTHY
+	}
+}
+
+// updateHealthLocked re-evaluates the health of an instance and publishes
+// an UPDATED event when it changed. Callers must hold s.mu for writing.
+func (s *Server) updateHealthLocked(info *instanceInfo, now time.Time) {
+	health := s.effectiveHealth(info, now)
+	if health == info.registration.Health {
+		return
+	}
+
+	log.Printf("Instance %s/%s is now %s", info.registration.ServiceName,
+		info.registration.InstanceId, health)
+
+	updated := proto.Clone(info.registration).(*voyagerv1.Registration)
+	updated.Health = health
+	info.registration = updated
+	s.hub.publish(voyagerv1.ServiceEvent_UPDATED, updated)
+}
+
+// startHealthMonitor periodically marks instances that missed heartbeats
+func (s *Server) startHealthMonitor() {
+	interval := s.suspectAfter / 2
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.checkInstanceHealth()
+			case <-s.ctx.Done():
+				log.Println("Stopping health monitor, server shutting down")
+				return
+			}
+		}
+	}()
+}
+
+// checkInstanceHealth merges heartbeat times known to the registry and
+// re-evaluates every instance
+func (s *Server) checkInstanceHealth() {
+	seen, err := s.registry.LastSeen(s.ctx)
+	if err != nil {
+		log.Printf("Failed to read instance heartbeats: %v", err)
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for serviceName, instances := range s.instances {
+		for instanceID, info := range instances {
+			if lastSeen, exists := seen[instanceKey(serviceName, instanceID)]; exists && lastSeen.After(info.lastSeen) {
+				info.lastSeen = lastSeen
+			}
+			s.updateHealthLocked(info, now)
+		}
+	}
+}
+
+// isHealthy reports whether an instance may receive traffic
+func isHealthy(reg *voyagerv1.Registration) bool {
+	return reg.Health == voyagerv1.HealthResponse_HEALTHY
+}
+
+// instanceWeight returns the weight of an instance, 1 when it has none
+func instanceWeight(reg *voyagerv1.Registration) uint32 {
+	if reg.Weight == nil {
+		return 1
+	}
+	return *reg.Weight
+}
+
+// receivesTraffic reports whether an instance is healthy and not weighted
+// out with weight 0
+func receivesTraffic(reg *voyagerv1.Registration) bool {
+	return isHealthy(reg) && instanceWeight(reg) > 0
+}
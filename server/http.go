@@ -0,0 +1,235 @@
+package server
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+	"github.com/kolkov/voyager/internal/selector"
+)
+
+// maxHTTPBodySize limits JSON request bodies
+const maxHTTPBodySize = 1 << 20
+
+var (
+	httpMarshaler   = protojson.MarshalOptions{}
+	httpUnmarshaler = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// HTTPHandler returns the REST/JSON API. Messages use the protojson mapping
+// of voyager.v1 and requests are authenticated with the same token as gRPC,
+// sent in the Authorization header.
+//
+//	POST   /v1/register                                  Registration  -> Response
+//	POST   /v1/deregister                                InstanceID    -> Response
+//	DELETE /v1/services/{service}/instances/{instance}                 -> Response
+//	POST   /v1/heartbeat                                 HealthRequest -> HealthResponse
+//	POST   /v1/discover                                  ServiceQuery  -> ServiceList
+//	GET    /v1/services/{service}?healthy_only=&selector=              -> ServiceList
+//	GET    /v1/services?prefix=&page_size=&page_token=                 -> ListServicesResponse
+func (s *Server) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /v1/register", func(w http.ResponseWriter, r *http.Request) {
+		req := &voyagerv1.Registration{}
+		if decodeHTTPRequest(w, r, req) {
+			resp, err := s.Register(r.Context(), req)
+			writeHTTPResponse(w, resp, err)
+		}
+	})
+
+	mux.HandleFunc("POST /v1/deregister", func(w http.ResponseWriter, r *http.Request) {
+		req := &voyagerv1.InstanceID{}
+		if decodeHTTPRequest(w, r, req) {
+			resp, err := s.Deregister(r.Context(), req)
+			writeHTTPResponse(w, resp, err)
+		}
+	})
+
+	mux.HandleFunc("DELETE /v1/services/{service}/instances/{instance}", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Deregister(r.Context(), &voyagerv1.InstanceID{
+			ServiceName: r.PathValue("service"),
+			InstanceId:  r.PathValue("instance"),
+		})
+		writeHTTPResponse(w, resp, err)
+	})
+
+	mux.HandleFunc("POST /v1/heartbeat", func(w http.ResponseWriter, r *http.Request) {
+		req := &voyagerv1.HealthRequest{}
+		if decodeHTTPRequest(w, r, req) {
+			resp, err := s.HealthCheck(r.Context(), req)
+			writeHTTPResponse(w, resp, err)
+		}
+	})
+
+	mux.HandleFunc("POST /v1/discover", func(w http.ResponseWriter, r *http.Request) {
+		req := &voyagerv1.ServiceQuery{}
+		if decodeHTTPRequest(w, r, req) {
+			resp, err := s.Discover(r.Context(), req)
+			writeHTTPResponse(w, resp, err)
+		}
+	})
+
+	mux.HandleFunc("GET /v1/services/{service}", func(w http.ResponseWriter, r *http.Request) {
+		req, err := serviceQueryFromURL(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		resp, err := s.Discover(r.Context(), req)
+		writeHTTPResponse(w, resp, err)
+	})
+
+	mux.HandleFunc("GET /v1/services", func(w http.ResponseWriter, r *http.Request) {
+		req, err := listServicesRequestFromURL(r)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		resp, err := s.ListServices(r.Context(), req)
+		writeHTTPResponse(w, resp, err)
+	})
+
+	return s.authHTTP(mux)
+}
+
+// authHTTP checks the Authorization header like AuthInterceptor checks
+// gRPC metadata
+func (s *Server) authHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if token := r.Header.Get("Authorization"); token != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", token))
+		}
+		if err := s.authenticate(ctx); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeHTTPRequest reads a protojson request body into req. It writes the
+// error response and returns false on failure.
+func decodeHTTPRequest(w http.ResponseWriter, r *http.Request, req proto.Message) bool {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBodySize))
+	if err != nil {
+		writeHTTPError(w, status.Error(codes.InvalidArgument, "failed to read request body"))
+		return false
+	}
+	if err := httpUnmarshaler.Unmarshal(body, req); err != nil {
+		writeHTTPError(w, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// serviceQueryFromURL builds a ServiceQuery from the path and query string
+func serviceQueryFromURL(r *http.Request) (*voyagerv1.ServiceQuery, error) {
+	query := r.URL.Query()
+	req := &voyagerv1.ServiceQuery{ServiceName: r.PathValue("service")}
+
+	if value := query.Get("healthy_only"); value != "" {
+		healthyOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid healthy_only: %q", value)
+		}
+		req.HealthyOnly = healthyOnly
+	}
+
+	if expr := query.Get("selector"); expr != "" {
+		requirements, err := selector.Parse(expr)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		req.Selector = requirements
+	}
+	return req, nil
+}
+
+// listServicesRequestFromURL builds a ListServicesRequest from the query string
+func listServicesRequestFromURL(r *http.Request) (*voyagerv1.ListServicesRequest, error) {
+	query := r.URL.Query()
+	req := &voyagerv1.ListServicesRequest{
+		Prefix:    query.Get("prefix"),
+		PageToken: query.Get("page_token"),
+	}
+
+	if value := query.Get("page_size"); value != "" {
+		pageSize, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page_size: %q", value)
+		}
+		req.PageSize = int32(pageSize)
+	}
+	return req, nil
+}
+
+// writeHTTPResponse writes a protojson response or the error of a call
+func writeHTTPResponse(w http.ResponseWriter, resp proto.Message, err error) {
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeHTTPMessage(w, http.StatusOK, resp)
+}
+
+// writeHTTPError writes a gRPC status as a google.rpc.Status JSON body
+func writeHTTPError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeHTTPMessage(w, httpStatusFromCode(st.Code()), st.Proto())
+}
+
+// writeHTTPMessage marshals msg with the given HTTP status
+func writeHTTPMessage(w http.ResponseWriter, code int, msg proto.Message) {
+	body, err := httpMarshaler.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to marshal HTTP response: %v", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Failed to write HTTP response: %v", err)
+	}
+}
+
+// httpStatusFromCode maps gRPC codes to HTTP status codes
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// TestHTTPHandler tests the REST/JSON API
+func TestHTTPHandler(t *testing.T) {
+	srv := createInMemoryServer(t)
+	defer srv.Close()
+	srv.authToken = "test-token"
+
+	api := httptest.NewServer(srv.HTTPHandler())
+	defer api.Close()
+
+	call := func(method, path, body string, resp proto.Message) int {
+		req, err := http.NewRequest(method, api.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "test-token")
+
+		httpResp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer httpResp.Body.Close()
+
+		data, err := io.ReadAll(httpResp.Body)
+		require.NoError(t, err)
+		if resp != nil && httpResp.StatusCode == http.StatusOK {
+			require.NoError(t, protojson.Unmarshal(data, resp), string(data))
+		}
+		return httpResp.StatusCode
+	}
+
+	t.Run("Register and discover", func(t *testing.T) {
+		resp := &voyagerv1.Response{}
+		code := call(http.MethodPost, "/v1/register",
+			`{"serviceName":"test-service","instanceId":"instance-1","address":"127.0.0.1","port":8080,"metadata":{"environment":"production"}}`,
+			resp)
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, resp.Success)
+
+		code = call(http.MethodPost, "/v1/register",
+			`{"service_name":"test-service","instance_id":"instance-2","address":"127.0.0.2","port":8080,"metadata":{"environment":"staging"}}`,
+			resp)
+		require.Equal(t, http.StatusOK, code)
+
+		list := &voyagerv1.ServiceList{}
+		code = call(http.MethodPost, "/v1/discover", `{"serviceName":"test-service"}`, list)
+		require.Equal(t, http.StatusOK, code)
+		assert.Len(t, list.Instances, 2)
+
+		list = &voyagerv1.ServiceList{}
+		code = call(http.MethodGet, "/v1/services/test-service?healthy_only=true&selector=environment%3Dproduction", "", list)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, list.Instances, 1)
+		assert.Equal(t, "instance-1", list.Instances[0].InstanceId)
+
+		services := &voyagerv1.ListServicesResponse{}
+		code = call(http.MethodGet, "/v1/services?prefix=test&page_size=10", "", services)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, services.Services, 1)
+		assert.Equal(t, int32(2), services.Services[0].InstanceCount)
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		resp := &voyagerv1.HealthResponse{}
+		code := call(http.MethodPost, "/v1/heartbeat",
+			`{"serviceName":"test-service","instanceId":"instance-1","status":"UNHEALTHY"}`, resp)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, voyagerv1.HealthResponse_UNHEALTHY, resp.Status)
+
+		list := &voyagerv1.ServiceList{}
+		code = call(http.MethodGet, "/v1/services/test-service?healthy_only=1", "", list)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, list.Instances, 1)
+		assert.Equal(t, "instance-2", list.Instances[0].InstanceId)
+	})
+
+	t.Run("Deregister", func(t *testing.T) {
+		resp := &voyagerv1.Response{}
+		code := call(http.MethodPost, "/v1/deregister", `{"serviceName":"test-service","instanceId":"instance-1"}`, resp)
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, resp.Success)
+
+		code = call(http.MethodDelete, "/v1/services/test-service/instances/instance-2", "", resp)
+		require.Equal(t, http.StatusOK, code)
+
+		list := &voyagerv1.ServiceList{}
+		code = call(http.MethodGet, "/v1/services/test-service", "", list)
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, list.Instances)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/v1/register", `{"serviceName":"test-service"}`, nil))
+		assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/v1/register", `not json`, nil))
+		assert.Equal(t, http.StatusBadRequest, call(http.MethodGet, "/v1/services/test-service?selector=version%20in%20(1", "", nil))
+		assert.Equal(t, http.StatusBadRequest, call(http.MethodGet, "/v1/services/test-service?healthy_only=maybe", "", nil))
+		assert.Equal(t, http.StatusBadRequest, call(http.MethodGet, "/v1/services?page_size=ten", "", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, call(http.MethodGet, "/v1/register", "", nil))
+	})
+
+	t.Run("Auth", func(t *testing.T) {
+		httpResp, err := http.Get(api.URL + "/v1/services")
+		require.NoError(t, err)
+		httpResp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+		req, err := http.NewRequest(http.MethodGet, api.URL+"/v1/services", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "wrong-token")
+		httpResp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		httpResp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+	})
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// memoryEntry is a stored registration with its heartbeat times
+type memoryEntry struct {
+	registration *voyagerv1.Registration
+	lastSeen     time.Time
+	expiresAt    time.Time
+}
+
+// MemoryRegistry keeps registrations in process memory without persistence
+type MemoryRegistry struct {
+	mu       sync.Mutex
+	entries  map[string]map[string]*memoryEntry
+	revision int64
+	watchers registryWatchers
+}
+
+// NewMemoryRegistry creates an empty in-memory registry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		entries: make(map[string]map[string]*memoryEntry),
+	}
+}
+
+// Put stores a registration
+func (r *MemoryRegistry) Put(_ context.Context, reg *voyagerv1.Registration, ttl time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	return r.putLocked(reg, now, now.Add(ttl)), nil
+}
+
+// putLocked stores a registration with explicit heartbeat times
+func (r *MemoryRegistry) putLocked(reg *voyagerv1.Registration, lastSeen, expiresAt time.Time) int64 {
+	if _, exists := r.entries[reg.ServiceName]; !exists {
+		r.entries[reg.ServiceName] = make(map[string]*memoryEntry)
+	}
+	r.entries[reg.ServiceName][reg.InstanceId] = &memoryEntry{
+		registration: reg,
+		lastSeen:     lastSeen,
+		expiresAt:    expiresAt,
+	}
+
+	r.revision++
+	r.watchers.emit(RegistryEvent{Type: RegistryPut, Registration: reg, Revision: r.revision})
+	return r.revision
+}
+
+// Get returns a single registration
+func (r *MemoryRegistry) Get(_ context.Context, serviceName, instanceID string) (*voyagerv1.Registration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.entries[serviceName][instanceID]
+	if !exists {
+		return nil, ErrInstanceNotFound
+	}
+	return entry.registration, nil
+}
+
+// List returns registrations of a service or of all services
+func (r *MemoryRegistry) List(_ context.Context, serviceName string) ([]*voyagerv1.Registration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.listLocked(serviceName), nil
+}
+
+func (r *MemoryRegistry) listLocked(serviceName string) []*voyagerv1.Registration {
+	var list []*voyagerv1.Registration
+	for name, instances := range r.entries {
+		if serviceName != "" && name != serviceName {
+			continue
+		}
+		for _, entry := range instances {
+			list = append(list, entry.registration)
+		}
+	}
+	return list
+}
+
+// Delete removes a registration
+func (r *MemoryRegistry) Delete(_ context.Context, serviceName, instanceID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.entries[serviceName][instanceID]
+	if !exists {
+		return 0, ErrInstanceNotFound
+	}
+	return r.deleteLocked(entry.registration), nil
+}
+
+func (r *MemoryRegistry) deleteLocked(reg *voyagerv1.Registration) int64 {
+	service := r.entries[reg.ServiceName]
+	delete(service, reg.InstanceId)
+	if len(service) == 0 {
+		delete(r.entries, reg.ServiceName)
+	}
+
+	r.revision++
+	r.watchers.emit(RegistryEvent{Type: RegistryDelete, Registration: reg, Revision: r.revision})
+	return r.revision
+}
+
+// KeepAlive extends the expiry of a registration
+func (r *MemoryRegistry) KeepAlive(_ context.Context, serviceName, instanceID string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.entries[serviceName][instanceID]
+	if !exists {
+		return ErrInstanceNotFound
+	}
+	entry.lastSeen = time.Now()
+	entry.expiresAt = entry.lastSeen.Add(ttl)
+	return nil
+}
+
+// LastSeen returns the last heartbeat time of every instance
+func (r *MemoryRegistry) LastSeen(context.Context) (map[string]time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]time.Time)
+	for serviceName, instances := range r.entries {
+		for instanceID, entry := range instances {
+			seen[instanceKey(serviceName, instanceID)] = entry.lastSeen
+		}
+	}
+	return seen, nil
+}
+
+// Expire removes registrations whose deadline passed
+func (r *MemoryRegistry) Expire(_ context.Context, now time.Time) ([]*voyagerv1.Registration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []*voyagerv1.Registration
+	for serviceName, instances := range r.entries {
+		for instanceID, entry := range instances {
+			if now.After(entry.expiresAt) {
+				expired = append(expired, entry.registration)
+				log.Printf("Removed expired instance: %s/%s", serviceName, instanceID)
+			}
+		}
+	}
+
+	for _, reg := range expired {
+		r.deleteLocked(reg)
+	}
+	return expired, nil
+}
+
+// Watch streams registry changes starting with a reset snapshot
+func (r *MemoryRegistry) Watch(ctx context.Context) (<-chan RegistryEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.watchers.add(ctx, r.listLocked(""), r.revision), nil
+}
+
+// Close stops all watchers
+func (r *MemoryRegistry) Close() error {
+	r.watchers.closeAll()
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
 )
 
 // Metrics definitions
@@ -25,6 +27,11 @@ var (
 		Help: "Number of service instances",
 	}, []string{"service"})
 
+	serviceHealthGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "voyager_service_instances_by_health",
+		Help: "Number of service instances per health state",
+	}, []string{"service", "health"})
+
 	cacheRefreshCounter = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "voyager_cache_refreshes_total",
 		Help: "Total cache refresh operations",
@@ -34,6 +41,56 @@ var (
 		Name: "voyager_cache_refresh_errors_total",
 		Help: "Total cache refresh errors",
 	})
+
+	cacheWatchEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voyager_cache_watch_events_total",
+		Help: "Total etcd watch events applied to the cache",
+	}, []string{"type"})
+
+	cacheWatchRestarts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "voyager_cache_watch_restarts_total",
+		Help: "Total etcd watch restarts that required a full re-list",
+	})
+
+	leaseCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voyager_lease_operations_total",
+		Help: "Total ETCD lease operations by result",
+	}, []string{"operation", "status"})
+
+	watchersGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "voyager_watchers",
+		Help: "Number of active Watch streams",
+	})
+
+	watchEventsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voyager_watch_events_total",
+		Help: "Total registry change events published to watchers",
+	}, []string{"type"})
+
+	sessionsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "voyager_sessions",
+		Help: "Number of active Session streams",
+	})
+
+	sessionExpiredCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "voyager_session_expirations_total",
+		Help: "Total instances deregistered after their Session stream broke",
+	})
+
+	xdsStreamsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "voyager_xds_streams",
+		Help: "Number of active xDS streams",
+	})
+
+	xdsSnapshotsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "voyager_xds_snapshots_total",
+		Help: "Total xDS snapshots published",
+	})
+
+	dnsQueriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "voyager_dns_queries_total",
+		Help: "Total DNS queries by record type and response code",
+	}, []string{"type", "rcode"})
 )
 
 // MetricsHandler returns Prometheus metrics handler
@@ -46,13 +103,19 @@ func (s *Server) UpdateServiceMetrics() {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if s.inMemory {
-		for service, instances := range s.inMemoryInstances {
-			serviceInstancesGauge.WithLabelValues(service).Set(float64(len(instances)))
+	for service, instances := range s.instances {
+		serviceInstancesGauge.WithLabelValues(service).Set(float64(len(instances)))
+
+		counts := make(map[voyagerv1.HealthResponse_Status]int)
+		for _, info := range instances {
+			counts[info.registration.Health]++
 		}
-	} else {
-		for service, instances := range s.services {
-			serviceInstancesGauge.WithLabelValues(service).Set(float64(len(instances)))
+		for _, health := range []voyagerv1.HealthResponse_Status{
+			voyagerv1.HealthResponse_HEALTHY,
+			voyagerv1.HealthResponse_SUSPECT,
+			voyagerv1.HealthResponse_UNHEALTHY,
+		} {
+			serviceHealthGauge.WithLabelValues(service, health.String()).Set(float64(counts[health]))
 		}
 	}
 }
@@ -62,6 +125,11 @@ func IncRegistrationCounter(service string) {
 	registrationCounter.WithLabelValues(service).Inc()
 }
 
+// IncLeaseCounter increments lease operation counter
+func IncLeaseCounter(operation, status string) {
+	leaseCounter.WithLabelValues(operation, status).Inc()
+}
+
 // IncDiscoveryCounter increments discovery counter
 func IncDiscoveryCounter(service, status string) {
 	discoveryCounter.WithLabelValues(service, status).Inc()
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// ErrInstanceNotFound is returned by a Registry for unknown instances
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// Registry is the storage backend behind Server. Writes return the backend
+// revision they were applied at; Watch events carry the same revisions so the
+// server can tell its own writes apart from older changes.
+type Registry interface {
+	// Put creates or replaces a registration that expires after ttl
+	Put(ctx context.Context, reg *voyagerv1.Registration, ttl time.Duration) (int64, error)
+	// Get returns a registration or ErrInstanceNotFound
+	Get(ctx context.Context, serviceName, instanceID string) (*voyagerv1.Registration, error)
+	// List returns registrations of a service, or of every service when
+	// serviceName is empty
+	List(ctx context.Context, serviceName string) ([]*voyagerv1.Registration, error)
+	// Delete removes a registration or returns ErrInstanceNotFound
+	Delete(ctx context.Context, serviceName, instanceID string) (int64, error)
+	// KeepAlive extends the expiry of a registration by ttl or returns
+	// ErrInstanceNotFound when it has already expired
+	KeepAlive(ctx context.Context, serviceName, instanceID string, ttl time.Duration) error
+	// LastSeen returns the last heartbeat time of every instance keyed by
+	// service and instance ID, including heartbeats handled by other servers
+	// sharing the backend
+	LastSeen(ctx context.Context) (map[string]time.Time, error)
+	// Expire removes registrations whose TTL elapsed before now and returns
+	// them. Backends with native expiry may return nil.
+	Expire(ctx context.Context, now time.Time) ([]*voyagerv1.Registration, error)
+	// Watch streams changes until ctx is done. The first event is always a
+	// RegistryReset with the full registry; later resets follow whenever the
+	// backend had to re-list.
+	Watch(ctx context.Context) (<-chan RegistryEvent, error)
+	// Close releases backend resources
+	Close() error
+}
+
+// RegistryEventType defines registry change kinds
+type RegistryEventType int
+
+const (
+	// RegistryPut means a registration was created or replaced
+	RegistryPut RegistryEventType = iota
+	// RegistryDelete means a registration was removed or expired
+	RegistryDelete
+	// RegistryReset carries the complete registry in Snapshot
+	RegistryReset
+)
+
+// RegistryEvent is a single change reported by Registry.Watch
+type RegistryEvent struct {
+	Type RegistryEventType
+	// Registration is the stored value for puts. Deletes only guarantee
+	// ServiceName and InstanceId.
+	Registration *voyagerv1.Registration
+	// Snapshot holds every registration for resets
+	Snapshot []*voyagerv1.Registration
+	// Revision is the backend revision of the change or snapshot
+	Revision int64
+}
+
+// instanceKey identifies an instance across services
+func instanceKey(serviceName, instanceID string) string {
+	return serviceName + "/" + instanceID
+}
+
+// registryWatchers fans events out to Watch callers of in-process backends
+type registryWatchers struct {
+	mu       sync.Mutex
+	watchers map[*registryWatcher]struct{}
+}
+
+type registryWatcher struct {
+	ctx    context.Context
+	events chan RegistryEvent
+}
+
+// add registers a watcher and queues its initial reset event. Callers hold
+// the backend lock so that no change lands between snapshot and subscription.
+func (w *registryWatchers) add(ctx context.Context, snapshot []*voyagerv1.Registration, revision int64) <-chan RegistryEvent {
+	watcher := &registryWatcher{
+		ctx:    ctx,
+		events: make(chan RegistryEvent, watchBufferSize),
+	}
+	watcher.events <- RegistryEvent{Type: RegistryReset, Snapshot: snapshot, Revision: revision}
+
+	w.mu.Lock()
+	if w.watchers == nil {
+		w.watchers = make(map[*registryWatcher]struct{})
+	}
+	w.watchers[watcher] = struct{}{}
+	w.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		w.remove(watcher)
+	}()
+	return watcher.events
+}
+
+// emit delivers an event to every watcher. Callers hold the backend lock so
+// events arrive in revision order; a watcher whose context ended is dropped.
+func (w *registryWatchers) emit(event RegistryEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for watcher := range w.watchers {
+		select {
+		case watcher.events <- event:
+		case <-watcher.ctx.Done():
+			delete(w.watchers, watcher)
+			close(watcher.events)
+		}
+	}
+}
+
+// remove closes a watcher's channel
+func (w *registryWatchers) remove(watcher *registryWatcher) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, exists := w.watchers[watcher]; exists {
+		delete(w.watchers, watcher)
+		close(watcher.events)
+	}
+}
+
+// closeAll closes every watcher channel
+func (w *registryWatchers) closeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for watcher := range w.watchers {
+		close(watcher.events)
+	}
+	w.watchers = nil
+}
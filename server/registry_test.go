@@ -0,0 +1,315 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// TestRegistryConformance runs the same contract checks against every backend
+func TestRegistryConformance(t *testing.T) {
+	backends := map[string]func(t *testing.T) Registry{
+		"Memory": func(t *testing.T) Registry {
+			return NewMemoryRegistry()
+		},
+		"Bolt": func(t *testing.T) Registry {
+			registry, err := NewBoltRegistry(filepath.Join(t.TempDir(), "voyager.db"))
+			require.NoError(t, err)
+			return registry
+		},
+		"ETCD": func(t *testing.T) Registry {
+			endpoint, cleanup := startEmbeddedETCD(t)
+			t.Cleanup(cleanup)
+
+			registry, err := NewEtcdRegistry([]string{endpoint})
+			require.NoError(t, err)
+			return registry
+		},
+	}
+
+	for name, newRegistry := range backends {
+		t.Run(name, func(t *testing.T) {
+			registry := newRegistry(t)
+			defer func() {
+				assert.NoError(t, registry.Close())
+			}()
+			testRegistry(t, registry)
+		})
+	}
+}
+
+// testRegistry checks the Registry contract on an empty backend
+func testRegistry(t *testing.T, registry Registry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	reg := &voyagerv1.Registration{
+		ServiceName: "conformance-service",
+		InstanceId:  "instance-1",
+		Address:     "127.0.0.1",
+		Port:        8080,
+		Metadata:    map[string]string{"version": "v1"},
+	}
+
+	events, err := registry.Watch(ctx)
+	require.NoError(t, err)
+
+	reset := receiveRegistryEvent(t, events)
+	require.Equal(t, RegistryReset, reset.Type)
+	assert.Empty(t, reset.Snapshot)
+
+	t.Run("Put and Get", func(t *testing.T) {
+		revision, err := registry.Put(ctx, reg, time.Minute)
+		require.NoError(t, err)
+		assert.Greater(t, revision, reset.Revision)
+
+		got, err := registry.Get(ctx, reg.ServiceName, reg.InstanceId)
+		require.NoError(t, err)
+		assert.Equal(t, reg.Address, got.Address)
+		assert.Equal(t, reg.Metadata, got.Metadata)
+
+		event := receiveRegistryEvent(t, events)
+		assert.Equal(t, RegistryPut, event.Type)
+		assert.Equal(t, revision, event.Revision)
+		assert.Equal(t, reg.InstanceId, event.Registration.InstanceId)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		other := &voyagerv1.Registration{
+			ServiceName: "other-service",
+			InstanceId:  "instance-1",
+			Address:     "127.0.0.1",
+			Port:        9090,
+		}
+		_, err := registry.Put(ctx, other, time.Minute)
+		require.NoError(t, err)
+		receiveRegistryEvent(t, events)
+
+		list, err := registry.List(ctx, reg.ServiceName)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, reg.InstanceId, list[0].InstanceId)
+
+		all, err := registry.List(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		_, err = registry.Delete(ctx, other.ServiceName, other.InstanceId)
+		require.NoError(t, err)
+		receiveRegistryEvent(t, events)
+	})
+
+	t.Run("KeepAlive", func(t *testing.T) {
+		assert.NoError(t, registry.KeepAlive(ctx, reg.ServiceName, reg.InstanceId, time.Minute))
+		assert.ErrorIs(t, registry.KeepAlive(ctx, reg.ServiceName, "missing", time.Minute), ErrInstanceNotFound)
+
+		seen, err := registry.LastSeen(ctx)
+		require.NoError(t, err)
+		require.Contains(t, seen, instanceKey(reg.ServiceName, reg.InstanceId))
+		assert.WithinDuration(t, time.Now(), seen[instanceKey(reg.ServiceName, reg.InstanceId)], 2*time.Second)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		revision, err := registry.Delete(ctx, reg.ServiceName, reg.InstanceId)
+		require.NoError(t, err)
+
+		event := receiveRegistryEvent(t, events)
+		assert.Equal(t, RegistryDelete, event.Type)
+		assert.Equal(t, revision, event.Revision)
+		assert.Equal(t, reg.ServiceName, event.Registration.ServiceName)
+		assert.Equal(t, reg.InstanceId, event.Registration.InstanceId)
+
+		_, err = registry.Get(ctx, reg.ServiceName, reg.InstanceId)
+		assert.ErrorIs(t, err, ErrInstanceNotFound)
+
+		_, err = registry.Delete(ctx, reg.ServiceName, reg.InstanceId)
+		assert.ErrorIs(t, err, ErrInstanceNotFound)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		_, err := registry.Put(ctx, reg, time.Second)
+		require.NoError(t, err)
+		receiveRegistryEvent(t, events)
+
+		assert.Eventually(t, func() bool {
+			if _, err := registry.Expire(ctx, time.Now()); err != nil {
+				return false
+			}
+			_, err := registry.Get(ctx, reg.ServiceName, reg.InstanceId)
+			return err == ErrInstanceNotFound
+		}, 10*time.Second, 100*time.Millisecond)
+
+		event := receiveRegistryEvent(t, events)
+		assert.Equal(t, RegistryDelete, event.Type)
+		assert.ErrorIs(t, registry.KeepAlive(ctx, reg.ServiceName, reg.InstanceId, time.Minute), ErrInstanceNotFound)
+	})
+
+	t.Run("Watch snapshot", func(t *testing.T) {
+		_, err := registry.Put(ctx, reg, time.Minute)
+		require.NoError(t, err)
+		receiveRegistryEvent(t, events)
+
+		watchCtx, watchCancel := context.WithCancel(ctx)
+		defer watchCancel()
+
+		second, err := registry.Watch(watchCtx)
+		require.NoError(t, err)
+
+		event := receiveRegistryEvent(t, second)
+		require.Equal(t, RegistryReset, event.Type)
+		require.Len(t, event.Snapshot, 1)
+		assert.Equal(t, reg.InstanceId, event.Snapshot[0].InstanceId)
+	})
+}
+
+// receiveRegistryEvent waits for the next registry event
+func receiveRegistryEvent(t *testing.T, events <-chan RegistryEvent) RegistryEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "registry watch closed")
+		return event
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for registry event")
+		return RegistryEvent{}
+	}
+}
+
+// TestBoltRegistryPersistence tests that registrations survive a reopen
+func TestBoltRegistryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "voyager.db")
+	ctx := context.Background()
+
+	registry, err := NewBoltRegistry(path)
+	require.NoError(t, err)
+
+	reg := &voyagerv1.Registration{
+		ServiceName: "persistent-service",
+		InstanceId:  "instance-1",
+		Address:     "127.0.0.1",
+		Port:        8080,
+	}
+	_, err = registry.Put(ctx, reg, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, registry.Close())
+
+	registry, err = NewBoltRegistry(path)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, registry.Close())
+	}()
+
+	list, err := registry.List(ctx, reg.ServiceName)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, reg.Address, list[0].Address)
+}
+
+// TestEtcdRegistrySharedHeartbeats tests that servers sharing an etcd
+// cluster see the heartbeats handled by each other
+func TestEtcdRegistrySharedHeartbeats(t *testing.T) {
+	endpoint, cleanup := startEmbeddedETCD(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	first, err := NewEtcdRegistry([]string{endpoint})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, first.Close())
+	}()
+	second, err := NewEtcdRegistry([]string{endpoint})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, second.Close())
+	}()
+
+	events, err := second.Watch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, RegistryReset, receiveRegistryEvent(t, events).Type)
+
+	reg := &voyagerv1.Registration{
+		ServiceName: "shared-service",
+		InstanceId:  "instance-1",
+		Address:     "127.0.0.1",
+		Port:        8080,
+	}
+	_, err = first.Put(ctx, reg, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, RegistryPut, receiveRegistryEvent(t, events).Type)
+
+	key := instanceKey(reg.ServiceName, reg.InstanceId)
+	time.Sleep(heartbeatShareDelay)
+	heartbeat := time.Now()
+	require.NoError(t, first.KeepAlive(ctx, reg.ServiceName, reg.InstanceId, time.Minute))
+
+	assert.Eventually(t, func() bool {
+		seen, err := second.LastSeen(ctx)
+		return err == nil && !seen[key].Before(heartbeat)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Deregistered instances are forgotten
+	_, err = first.Delete(ctx, reg.ServiceName, reg.InstanceId)
+	require.NoError(t, err)
+	require.Equal(t, RegistryDelete, receiveRegistryEvent(t, events).Type)
+	seen, err := second.LastSeen(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, seen, key)
+}
+
+// TestEtcdRegistryWatchResume tests that an interrupted etcd watch reports
+// the last revision it delivered and resumes from there without a re-list
+func TestEtcdRegistryWatchResume(t *testing.T) {
+	endpoint, cleanup := startEmbeddedETCD(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	registry, err := NewEtcdRegistry([]string{endpoint})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, registry.Close())
+	}()
+
+	put := func(id string) int64 {
+		revision, err := registry.Put(ctx, &voyagerv1.Registration{
+			ServiceName: "resumed-service",
+			InstanceId:  id,
+			Address:     "127.0.0.1",
+			Port:        8080,
+		}, time.Minute)
+		require.NoError(t, err)
+		return revision
+	}
+	start := put("instance-1") - 1
+	last := put("instance-2")
+
+	events := make(chan RegistryEvent, 16)
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	done := make(chan int64)
+	go func() {
+		revision, _ := registry.watch(watchCtx, start, events)
+		done <- revision
+	}()
+	assert.Equal(t, "instance-1", receiveRegistryEvent(t, events).Registration.InstanceId)
+	assert.Equal(t, "instance-2", receiveRegistryEvent(t, events).Registration.InstanceId)
+	stopWatch()
+	assert.Equal(t, last, <-done)
+
+	// A change made while the watch was down arrives after resuming
+	put("instance-3")
+	resumeCtx, stopResume := context.WithCancel(ctx)
+	defer stopResume()
+	go registry.runWatch(resumeCtx, last, events)
+	event := receiveRegistryEvent(t, events)
+	assert.Equal(t, RegistryPut, event.Type)
+	assert.Equal(t, "instance-3", event.Registration.InstanceId)
+}
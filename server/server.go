@@ -2,47 +2,51 @@ package server
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
-	clientv3 "go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+	"github.com/kolkov/voyager/internal/selector"
 )
 
 // Config defines server configuration options
 type Config struct {
 	ETCDEndpoints []string
 	CacheTTL      time.Duration
-	AuthToken     string // Optional authentication token
-}
-
-// instanceInfo tracks registration and last seen time for in-memory mode
-type instanceInfo struct {
-	registration *voyagerv1.Registration
-	lastSeen     time.Time
+	AuthToken     string        // Optional authentication token
+	BoltPath      string        // Optional bbolt file for single-node persistence
+	Registry      Registry      // Optional storage backend, overrides ETCDEndpoints and BoltPath
+	SuspectAfter  time.Duration // Heartbeat silence before an instance is SUSPECT, defaults to CacheTTL/2
+	SessionGrace  time.Duration // Time instances outlive a broken Session stream, defaults to 10s
 }
 
 // Server implements voyagerv1.DiscoveryServer
 type Server struct {
 	voyagerv1.UnimplementedDiscoveryServer
-	etcdClient        *clientv3.Client
-	services          map[string]map[string]*voyagerv1.Registration
-	inMemoryInstances map[string]map[string]*instanceInfo
-	mu                sync.RWMutex
-	cacheTTL          time.Duration
-	inMemory          bool
-	janitorOnce       sync.Once
-	authToken         string
-	ctx               context.Context    // Context for lifecycle management
-	cancel            context.CancelFunc // Cancel function to stop background tasks
+	registry     Registry
+	instances    map[string]map[string]*instanceInfo
+	revisions    map[string]int64 // registry revision last applied per instance
+	baseRevision int64            // registry revision of the last snapshot
+	mu           sync.RWMutex
+	cacheTTL     time.Duration
+	suspectAfter time.Duration
+	sessionGrace time.Duration
+	sessions     map[string]*instanceSession // open Session streams per instance
+	sessionMu    sync.Mutex
+	janitorOnce  sync.Once
+	authToken    string
+	hub          *watchHub
+	ctx          context.Context    // Context for lifecycle management
+	cancel       context.CancelFunc // Cancel function to stop background tasks
 }
 
 // NewServer creates a new VoyagerSD server instance
@@ -51,45 +55,70 @@ func NewServer(cfg Config) (*Server, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	srv := &Server{
-		services:          make(map[string]map[string]*voyagerv1.Registration),
-		inMemoryInstances: make(map[string]map[string]*instanceInfo),
-		cacheTTL:          cfg.CacheTTL,
-		inMemory:          len(cfg.ETCDEndpoints) == 0,
-		authToken:         cfg.AuthToken,
-		ctx:               ctx,
-		cancel:            cancel,
+		instances:    make(map[string]map[string]*instanceInfo),
+		revisions:    make(map[string]int64),
+		cacheTTL:     cfg.CacheTTL,
+		suspectAfter: cfg.SuspectAfter,
+		sessionGrace: cfg.SessionGrace,
+		sessions:     make(map[string]*instanceSession),
+		authToken:    cfg.AuthToken,
+		hub:          newWatchHub(),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	if srv.suspectAfter <= 0 {
+		srv.suspectAfter = cfg.CacheTTL / 2
+	}
+	if srv.sessionGrace <= 0 {
+		srv.sessionGrace = defaultSessionGrace
 	}
 
-	if !srv.inMemory {
-		cli, err := clientv3.New(clientv3.Config{
-			Endpoints:   cfg.ETCDEndpoints,
-			DialTimeout: 2 * time.Second, // Shorter timeout
-		})
+	switch {
+	case cfg.Registry != nil:
+		srv.registry = cfg.Registry
+	case cfg.BoltPath != "":
+		registry, err := NewBoltRegistry(cfg.BoltPath)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		srv.registry = registry
+	case len(cfg.ETCDEndpoints) > 0:
+		registry, err := NewEtcdRegistry(cfg.ETCDEndpoints)
 		if err != nil {
 			log.Printf("WARNING: Failed to connect to ETCD: %v. Switching to in-memory mode", err)
-			srv.inMemory = true
 		} else {
-			srv.etcdClient = cli
-			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-			defer cancel()
-			if err := srv.loadInitialData(ctx); err != nil {
-				log.Printf("Warning: failed to load initial data: %v", err)
-				// Explicit fallback if initial load fails
-				srv.inMemory = true
-				if cliErr := cli.Close(); cliErr != nil {
-					log.Printf("failed to close etcd client: %v", cliErr)
-				}
-			} else {
-				go srv.startCacheRefresher()
+			srv.registry = registry
+		}
+	}
+
+	if srv.registry != nil {
+		if err := srv.syncRegistry(); err != nil {
+			log.Printf("Warning: failed to load initial data: %v", err)
+			if cfg.Registry != nil || cfg.BoltPath != "" {
+				srv.Close()
+				return nil, err
+			}
+
+			// Explicit fallback if initial etcd load fails
+			if closeErr := srv.registry.Close(); closeErr != nil {
+				log.Printf("failed to close registry: %v", closeErr)
 			}
+			srv.registry = nil
 		}
 	}
 
-	if srv.inMemory {
+	if srv.registry == nil {
 		log.Println("WARNING: Running in in-memory mode without persistence")
-		srv.startJanitor()
+		srv.registry = NewMemoryRegistry()
+		if err := srv.syncRegistry(); err != nil {
+			srv.Close()
+			return nil, err
+		}
 	}
 
+	srv.startJanitor()
+	srv.startHealthMonitor()
 	return srv, nil
 }
 
@@ -98,9 +127,9 @@ func (s *Server) Close() {
 	// Cancel context to stop all background goroutines
 	s.cancel()
 
-	if s.etcdClient != nil {
-		if err := s.etcdClient.Close(); err != nil {
-			log.Printf("failed to close etcd client: %v", err)
+	if s.registry != nil {
+		if err := s.registry.Close(); err != nil {
+			log.Printf("failed to close registry: %v", err)
 		}
 	}
 }
@@ -109,7 +138,10 @@ func (s *Server) Close() {
 func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
 	serverOpts := []grpc.ServerOption{}
 	if s.authToken != "" {
-		serverOpts = append(serverOpts, grpc.UnaryInterceptor(s.AuthInterceptor))
+		serverOpts = append(serverOpts,
+			grpc.UnaryInterceptor(s.AuthInterceptor),
+			grpc.StreamInterceptor(s.AuthStreamInterceptor),
+		)
 	}
 	serverOpts = append(serverOpts, opts...)
 
@@ -120,20 +152,47 @@ func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
 
 // AuthInterceptor provides authentication for gRPC methods
 func (s *Server) AuthInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if s.authToken != "" {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "missing metadata")
-		}
-
-		tokens := md.Get("authorization")
-		if len(tokens) == 0 || tokens[0] != s.authToken {
-			return nil, status.Error(codes.PermissionDenied, "invalid auth token")
-		}
+	if err := s.authenticate(ctx); err != nil {
+		return nil, err
 	}
 	return handler(ctx, req)
 }
 
+// AuthStreamInterceptor provides authentication for streaming gRPC methods
+func (s *Server) AuthStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authenticate(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// authenticate checks the authorization token in request metadata
+func (s *Server) authenticate(ctx context.Context) error {
+	if s.authToken == "" {
+		return nil
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing metadata")
+	}
+
+	tokens := md.Get("authorization")
+	if len(tokens) == 0 || bearerToken(tokens[0]) != s.authToken {
+		return status.Error(codes.PermissionDenied, "invalid auth token")
+	}
+	return nil
+}
+
+// bearerToken strips the optional "Bearer " scheme from an authorization value
+func bearerToken(value string) string {
+	const scheme = "Bearer "
+	if len(value) > len(scheme) && strings.EqualFold(value[:len(scheme)], scheme) {
+		return value[len(scheme):]
+	}
+	return value
+}
+
 // Register handles service registration
 func (s *Server) Register(ctx context.Context, req *voyagerv1.Registration) (*voyagerv1.Response, error) {
 	log.Printf("Registering service: %s, instance: %s, address: %s:%d",
@@ -145,47 +204,18 @@ func (s *Server) Register(ctx context.Context, req *voyagerv1.Registration) (*vo
 		return nil, status.Error(codes.InvalidArgument, "invalid registration data")
 	}
 
-	// For in-memory mode
-	if s.inMemory {
-		s.mu.Lock()
-		defer s.mu.Unlock()
-
-		if _, exists := s.inMemoryInstances[req.ServiceName]; !exists {
-			s.inMemoryInstances[req.ServiceName] = make(map[string]*instanceInfo)
-		}
-
-		s.inMemoryInstances[req.ServiceName][req.InstanceId] = &instanceInfo{
-			registration: req,
-			lastSeen:     time.Now(),
-		}
-		return &voyagerv1.Response{Success: true}, nil
-	}
-
-	// ETCD mode
-	key := fmt.Sprintf("/services/%s/%s", req.ServiceName, req.InstanceId)
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to marshal registration")
-	}
-
-	leaseResp, err := s.etcdClient.Grant(ctx, int64(s.cacheTTL.Seconds()))
-	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to create lease")
-	}
+	reg := proto.Clone(req).(*voyagerv1.Registration)
+	reg.Health = reportedHealth(req.Health)
 
-	_, err = s.etcdClient.Put(ctx, key, string(jsonData), clientv3.WithLease(leaseResp.ID))
+	revision, err := s.registry.Put(ctx, reg, s.cacheTTL)
 	if err != nil {
+		log.Printf("Failed to store registration: %v", err)
 		return nil, status.Error(codes.Internal, "failed to store registration")
 	}
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if _, exists := s.services[req.ServiceName]; !exists {
-		s.services[req.ServiceName] = make(map[string]*voyagerv1.Registration)
-	}
-
-	s.services[req.ServiceName][req.InstanceId] = req
+	s.applyPutLocked(reg, revision)
+	s.mu.Unlock()
 
 	return &voyagerv1.Response{Success: true}, nil
 }
@@ -199,26 +229,24 @@ func (s *Server) Discover(_ context.Context, req *voyagerv1.ServiceQuery) (*voya
 		IncDiscoveryCounter(req.ServiceName, discoveryStatus)
 	}()
 
+	if err := selector.Validate(req.Selector); err != nil {
+		discoveryStatus = "invalid"
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if s.inMemory {
-		list := &voyagerv1.ServiceList{}
-		if instances, exists := s.inMemoryInstances[req.ServiceName]; exists {
-			for _, info := range instances {
-				list.Instances = append(list.Instances, info.registration)
-			}
-		} else {
-			discoveryStatus = "not_found"
-		}
-		return list, nil
-	}
-
-	// ETCD implementation
 	list := &voyagerv1.ServiceList{}
-	if instances, exists := s.services[req.ServiceName]; exists {
-		for _, inst := range instances {
-			list.Instances = append(list.Instances, inst)
+	if instances, exists := s.instances[req.ServiceName]; exists {
+		for _, info := range instances {
+			if req.HealthyOnly && !isHealthy(info.registration) {
+				continue
+			}
+			if !selector.Matches(req.Selector, info.registration.Metadata) {
+				continue
+			}
+			list.Instances = append(list.Instances, info.registration)
 		}
 	} else {
 		discoveryStatus = "not_found"
@@ -231,120 +259,108 @@ func (s *Server) HealthCheck(ctx context.Context, req *voyagerv1.HealthRequest)
 	log.Printf("Health check received for service %s instance %s",
 		req.ServiceName, req.InstanceId)
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.inMemory {
-		if service, exists := s.inMemoryInstances[req.ServiceName]; exists {
-			if info, exists := service[req.InstanceId]; exists {
-				info.lastSeen = time.Now()
-				return &voyagerv1.HealthResponse{
-					Status: voyagerv1.HealthResponse_HEALTHY,
-				}, nil
-			}
-		}
+	health, err := s.heartbeat(ctx, req.ServiceName, req.InstanceId, req.Status)
+	if err != nil {
 		return &voyagerv1.HealthResponse{
 			Status: voyagerv1.HealthResponse_UNHEALTHY,
 		}, nil
 	}
+	return &voyagerv1.HealthResponse{
+		Status: health,
+	}, nil
+}
 
-	// For ETCD, refresh TTL by re-storing the existing value
-	if service, exists := s.services[req.ServiceName]; exists {
-		if reg, exists := service[req.InstanceId]; exists {
-			key := fmt.Sprintf("/services/%s/%s", req.ServiceName, req.InstanceId)
-
-			// Marshal the existing registration instead of using empty string
-			jsonData, err := json.Marshal(reg)
-			if err != nil {
-				log.Printf("Failed to marshal registration: %v", err)
-				return &voyagerv1.HealthResponse{
-					Status: voyagerv1.HealthResponse_UNHEALTHY,
-				}, nil
-			}
-
-			leaseResp, err := s.etcdClient.Grant(ctx, int64(s.cacheTTL.Seconds()))
-			if err != nil {
-				log.Printf("Failed to create lease: %v", err)
-				return &voyagerv1.HealthResponse{
-					Status: voyagerv1.HealthResponse_UNHEALTHY,
-				}, nil
-			}
-
-			_, err = s.etcdClient.Put(ctx, key, string(jsonData), clientv3.WithLease(leaseResp.ID))
-			if err != nil {
-				log.Printf("Failed to refresh TTL: %v", err)
-			}
+// heartbeat refreshes an instance and stores its self-reported state,
+// returning the effective health. ErrInstanceNotFound is returned for
+// instances that are not registered.
+func (s *Server) heartbeat(ctx context.Context, serviceName, instanceID string, status voyagerv1.HealthResponse_Status) (voyagerv1.HealthResponse_Status, error) {
+	reported := reportedHealth(status)
 
-			return &voyagerv1.HealthResponse{
-				Status: voyagerv1.HealthResponse_HEALTHY,
-			}, nil
+	s.mu.RLock()
+	var changed *voyagerv1.Registration
+	if info, exists := s.instances[serviceName][instanceID]; exists && info.reported != reported {
+		changed = proto.Clone(info.registration).(*voyagerv1.Registration)
+		changed.Health = reported
+	}
+	s.mu.RUnlock()
+
+	// A changed self-reported state is stored so every server sees it; plain
+	// heartbeats only refresh the TTL
+	var err error
+	if changed != nil {
+		var revision int64
+		revision, err = s.registry.Put(ctx, changed, s.cacheTTL)
+		if err == nil {
+			s.mu.Lock()
+			s.applyPutLocked(changed, revision)
+			s.mu.Unlock()
+		}
+	} else {
+		err = s.registry.KeepAlive(ctx, serviceName, instanceID, s.cacheTTL)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrInstanceNotFound) {
+			log.Printf("Failed to refresh instance %s/%s: %v", serviceName, instanceID, err)
 		}
+		return voyagerv1.HealthResponse_UNHEALTHY, err
 	}
 
-	return &voyagerv1.HealthResponse{
-		Status: voyagerv1.HealthResponse_UNHEALTHY,
-	}, nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	health := reported
+	if info, exists := s.instances[serviceName][instanceID]; exists {
+		now := time.Now()
+		info.lastSeen = now
+		s.updateHealthLocked(info, now)
+		health = info.registration.Health
+	}
+	return health, nil
 }
 
 // Deregister removes a service instance
 func (s *Server) Deregister(ctx context.Context, req *voyagerv1.InstanceID) (*voyagerv1.Response, error) {
-	if s.inMemory {
-		s.mu.Lock()
-		defer s.mu.Unlock()
-
-		if service, exists := s.inMemoryInstances[req.ServiceName]; exists {
-			delete(service, req.InstanceId)
-			if len(service) == 0 {
-				delete(s.inMemoryInstances, req.ServiceName)
-			}
-		}
-
+	revision, err := s.registry.Delete(ctx, req.ServiceName, req.InstanceId)
+	if errors.Is(err, ErrInstanceNotFound) {
 		return &voyagerv1.Response{Success: true}, nil
 	}
-
-	// ETCD mode
-	key := fmt.Sprintf("/services/%s/%s", req.ServiceName, req.InstanceId)
-
-	_, err := s.etcdClient.Delete(ctx, key)
 	if err != nil {
+		log.Printf("Failed to deregister instance: %v", err)
 		return nil, status.Error(codes.Internal, "failed to deregister")
 	}
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if service, exists := s.services[req.ServiceName]; exists {
-		delete(service, req.InstanceId)
-		if len(service) == 0 {
-			delete(s.services, req.ServiceName)
-		}
-	}
+	s.applyDeleteLocked(req.ServiceName, req.InstanceId, revision)
+	// Tombstone so a late watch event for the old value is ignored
+	s.revisions[instanceKey(req.ServiceName, req.InstanceId)] = revision
+	s.mu.Unlock()
 
 	return &voyagerv1.Response{Success: true}, nil
 }
 
+// publishRegistration emits ADDED or UPDATED for a stored registration.
+// Re-registering identical data is not a change. Callers must hold s.mu.
+func (s *Server) publishRegistration(previous, current *voyagerv1.Registration) {
+	switch {
+	case previous == nil:
+		s.hub.publish(voyagerv1.ServiceEvent_ADDED, current)
+	case !proto.Equal(previous, current):
+		s.hub.publish(voyagerv1.ServiceEvent_UPDATED, current)
+	}
+}
+
 // LogCurrentServices logs current service state
 func (s *Server) LogCurrentServices() {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	log.Println("=== Current registered services ===")
-	if s.inMemory {
-		for service, instances := range s.inMemoryInstances {
-			log.Printf("  %s: %d instances", service, len(instances))
-			for id, info := range instances {
-				log.Printf("    - ID: %s, Address: %s:%d, LastSeen: %s",
-					id, info.registration.Address, info.registration.Port,
-					info.lastSeen.Format(time.RFC3339))
-			}
-		}
-	} else {
-		for service, instances := range s.services {
-			log.Printf("  %s: %d instances", service, len(instances))
-			for id, reg := range instances {
-				log.Printf("    - ID: %s, Address: %s:%d",
-					id, reg.Address, reg.Port)
-			}
+	for service, instances := range s.instances {
+		log.Printf("  %s: %d instances", service, len(instances))
+		for id, info := range instances {
+			log.Printf("    - ID: %s, Address: %s:%d, LastSeen: %s",
+				id, info.registration.Address, info.registration.Port,
+				info.lastSeen.Format(time.RFC3339))
 		}
 	}
 	log.Println("==================================")
@@ -381,55 +397,11 @@ func (s *Server) startJanitor() {
 	})
 }
 
-// cleanupExpiredInstances removes instances that haven't been seen within TTL
+// cleanupExpiredInstances removes instances that haven't been seen within
+// TTL. The cache follows through the registry watch; backends with native
+// expiry ignore it.
 func (s *Server) cleanupExpiredInstances() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	now := time.Now()
-	for serviceName, instances := range s.inMemoryInstances {
-		for instanceID, info := range instances {
-			if now.Sub(info.lastSeen) > s.cacheTTL {
-				delete(instances, instanceID)
-				log.Printf("Removed expired instance: %s/%s", serviceName, instanceID)
-			}
-		}
-		if len(instances) == 0 {
-			delete(s.inMemoryInstances, serviceName)
-		}
-	}
-}
-
-// loadInitialData loads existing registrations from ETCD
-func (s *Server) loadInitialData(ctx context.Context) error {
-	if s.inMemory {
-		return nil
+	if _, err := s.registry.Expire(s.ctx, time.Now()); err != nil {
+		log.Printf("Failed to expire instances: %v", err)
 	}
-
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
-	defer cancel()
-
-	resp, err := s.etcdClient.Get(ctx, "/services/", clientv3.WithPrefix())
-	if err != nil {
-		return err
-	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for _, kv := range resp.Kvs {
-		var reg voyagerv1.Registration
-		if err := json.Unmarshal(kv.Value, &reg); err != nil {
-			log.Printf("Failed to unmarshal registration: %v", err)
-			continue
-		}
-
-		if _, exists := s.services[reg.ServiceName]; !exists {
-			s.services[reg.ServiceName] = make(map[string]*voyagerv1.Registration)
-		}
-
-		s.services[reg.ServiceName][reg.InstanceId] = &reg
-	}
-
-	return nil
 }
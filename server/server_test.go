@@ -2,9 +2,11 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"testing"
@@ -12,13 +14,16 @@ import (
 
 	"github.com/phayes/freeport"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/server/v3/embed"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
 )
@@ -104,7 +109,29 @@ func TestNewServer(t *testing.T) {
 		})
 		require.NoError(t, err)
 		defer srv.Close()
-		assert.True(t, srv.inMemory)
+		assert.IsType(t, &MemoryRegistry{}, srv.registry)
+	})
+
+	t.Run("Bolt mode", func(t *testing.T) {
+		srv, err := NewServer(Config{
+			BoltPath: filepath.Join(t.TempDir(), "voyager.db"),
+			CacheTTL: time.Minute,
+		})
+		require.NoError(t, err)
+		defer srv.Close()
+		assert.IsType(t, &BoltRegistry{}, srv.registry)
+	})
+
+	t.Run("Custom registry", func(t *testing.T) {
+		registry := NewMemoryRegistry()
+		srv, err := NewServer(Config{
+			ETCDEndpoints: []string{"http://invalid-host:2379"},
+			Registry:      registry,
+			CacheTTL:      time.Minute,
+		})
+		require.NoError(t, err)
+		defer srv.Close()
+		assert.Same(t, registry, srv.registry)
 	})
 
 	t.Run("ETCD mode", func(t *testing.T) {
@@ -122,8 +149,7 @@ func TestNewServer(t *testing.T) {
 		require.NoError(t, err, "Failed to create server")
 		defer srv.Close()
 
-		assert.False(t, srv.inMemory)
-		assert.NotNil(t, srv.etcdClient)
+		assert.IsType(t, &EtcdRegistry{}, srv.registry)
 
 		// Test registration
 		reg := &voyagerv1.Registration{
@@ -143,7 +169,7 @@ func TestNewServer(t *testing.T) {
 		})
 		require.NoError(t, err, "Discovery failed")
 		require.Len(t, list.Instances, 1)
-		assert.Equal(t, reg, list.Instances[0])
+		assert.True(t, proto.Equal(withHealth(reg, voyagerv1.HealthResponse_HEALTHY), list.Instances[0]))
 
 		// Test health check
 		healthResp, err := srv.HealthCheck(ctx, &voyagerv1.HealthRequest{
@@ -180,8 +206,257 @@ func TestNewServer(t *testing.T) {
 		})
 		require.NoError(t, err)
 		defer srv.Close()
-		assert.True(t, srv.inMemory)
+		assert.IsType(t, &MemoryRegistry{}, srv.registry)
+	})
+}
+
+// TestCacheWatcher tests that changes made by other replicas reach the cache
+func TestCacheWatcher(t *testing.T) {
+	endpoint, cleanup := startEmbeddedETCD(t)
+	defer cleanup()
+	time.Sleep(500 * time.Millisecond) // Give server time to stabilize
+
+	srv, err := NewServer(Config{
+		ETCDEndpoints: []string{endpoint},
+		CacheTTL:      30 * time.Second,
+	})
+	require.NoError(t, err)
+	defer srv.Close()
+	require.IsType(t, &EtcdRegistry{}, srv.registry)
+
+	// A second client plays the role of another voyagerd replica
+	other, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{endpoint},
+		DialTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if closeErr := other.Close(); closeErr != nil {
+			t.Logf("failed to close etcd client: %v", closeErr)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reg := &voyagerv1.Registration{
+		ServiceName: "remote-service",
+		InstanceId:  "instance-1",
+		Address:     "127.0.0.1",
+		Port:        8080,
+	}
+	data, err := json.Marshal(reg)
+	require.NoError(t, err)
+
+	_, err = other.Put(ctx, serviceKey(reg.ServiceName, reg.InstanceId), string(data))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		list, discoverErr := srv.Discover(ctx, &voyagerv1.ServiceQuery{ServiceName: reg.ServiceName})
+		return discoverErr == nil && len(list.Instances) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = other.Delete(ctx, serviceKey(reg.ServiceName, reg.InstanceId))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		list, discoverErr := srv.Discover(ctx, &voyagerv1.ServiceQuery{ServiceName: reg.ServiceName})
+		return discoverErr == nil && len(list.Instances) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	t.Run("Local writes are not applied twice", func(t *testing.T) {
+		stream := &fakeWatchStream{ctx: ctx, events: make(chan *voyagerv1.ServiceEvent, 16)}
+		go func() {
+			_ = srv.Watch(&voyagerv1.ServiceQuery{ServiceName: "local-service"}, stream)
+		}()
+		assert.Equal(t, voyagerv1.ServiceEvent_SNAPSHOT, receiveEvent(t, stream).Type)
+
+		local := &voyagerv1.Registration{
+			ServiceName: "local-service",
+			InstanceId:  "instance-1",
+			Address:     "127.0.0.1",
+			Port:        8081,
+		}
+		_, err := srv.Register(ctx, local)
+		require.NoError(t, err)
+		_, err = srv.Deregister(ctx, &voyagerv1.InstanceID{
+			ServiceName: local.ServiceName,
+			InstanceId:  local.InstanceId,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, voyagerv1.ServiceEvent_ADDED, receiveEvent(t, stream).Type)
+		assert.Equal(t, voyagerv1.ServiceEvent_REMOVED, receiveEvent(t, stream).Type)
+
+		// The echoed watch events for both writes must not resurrect the instance
+		select {
+		case event := <-stream.events:
+			t.Fatalf("unexpected event %s", event.Type)
+		case <-time.After(500 * time.Millisecond):
+		}
+	})
+}
+
+// TestCacheWatcherCompaction tests that a registry watch resuming from a
+// compacted revision re-lists etcd and resynchronizes the cache
+func TestCacheWatcherCompaction(t *testing.T) {
+	endpoint, cleanup := startEmbeddedETCD(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	etcdRegistry, err := NewEtcdRegistry([]string{endpoint})
+	require.NoError(t, err)
+	registry := &pausedEtcdRegistry{EtcdRegistry: etcdRegistry, resume: make(chan struct{})}
+
+	stale := &voyagerv1.Registration{
+		ServiceName: "compacted-service",
+		InstanceId:  "instance-1",
+		Address:     "127.0.0.1",
+		Port:        8080,
+	}
+	_, err = etcdRegistry.Put(ctx, stale, time.Minute)
+	require.NoError(t, err)
+
+	srv, err := NewServer(Config{Registry: registry, CacheTTL: 30 * time.Second})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	stream := &fakeWatchStream{ctx: ctx, events: make(chan *voyagerv1.ServiceEvent, 16)}
+	go func() {
+		_ = srv.Watch(&voyagerv1.ServiceQuery{ServiceName: stale.ServiceName}, stream)
+	}()
+	snapshot := receiveEvent(t, stream)
+	require.Equal(t, voyagerv1.ServiceEvent_SNAPSHOT, snapshot.Type)
+	require.Len(t, snapshot.Instances, 1)
+
+	// While the watch is down the instance is replaced and etcd compacts
+	// the revisions the watch would resume from
+	_, err = etcdRegistry.Delete(ctx, stale.ServiceName, stale.InstanceId)
+	require.NoError(t, err)
+	fresh := proto.Clone(stale).(*voyagerv1.Registration)
+	fresh.InstanceId = "instance-2"
+	revision, err := etcdRegistry.Put(ctx, fresh, time.Minute)
+	require.NoError(t, err)
+	_, err = etcdRegistry.client.Compact(ctx, revision)
+	require.NoError(t, err)
+
+	restarts := testutil.ToFloat64(cacheWatchRestarts)
+	close(registry.resume)
+
+	// The re-list resets the cache, publishing the difference to watchers
+	changes := map[string]voyagerv1.ServiceEvent_Type{}
+	for i := 0; i < 2; i++ {
+		event := receiveEvent(t, stream)
+		changes[event.Instance.InstanceId] = event.Type
+	}
+	assert.Equal(t, map[string]voyagerv1.ServiceEvent_Type{
+		stale.InstanceId: voyagerv1.ServiceEvent_REMOVED,
+		fresh.InstanceId: voyagerv1.ServiceEvent_ADDED,
+	}, changes)
+	assert.Equal(t, restarts+1, testutil.ToFloat64(cacheWatchRestarts))
+
+	list, err := srv.Discover(ctx, &voyagerv1.ServiceQuery{ServiceName: stale.ServiceName})
+	require.NoError(t, err)
+	require.Len(t, list.Instances, 1)
+	assert.Equal(t, fresh.InstanceId, list.Instances[0].InstanceId)
+
+	// Changes after the re-list arrive through the new watch
+	_, err = etcdRegistry.Delete(ctx, fresh.ServiceName, fresh.InstanceId)
+	require.NoError(t, err)
+	assert.Equal(t, voyagerv1.ServiceEvent_REMOVED, receiveEvent(t, stream).Type)
+}
+
+// pausedEtcdRegistry delivers the initial snapshot of an EtcdRegistry but
+// follows changes only once resume is closed, so the watch resumes from an
+// old revision
+type pausedEtcdRegistry struct {
+	*EtcdRegistry
+	resume chan struct{}
+}
+
+func (r *pausedEtcdRegistry) Watch(ctx context.Context) (<-chan RegistryEvent, error) {
+	snapshot, revision, err := r.list(ctx, servicesPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan RegistryEvent, watchBufferSize)
+	events <- RegistryEvent{Type: RegistryReset, Snapshot: snapshot, Revision: revision}
+	go func() {
+		select {
+		case <-r.resume:
+			r.runWatch(ctx, revision, events)
+		case <-ctx.Done():
+			close(events)
+		}
+	}()
+	return events, nil
+}
+
+// TestLeaseReuse tests that heartbeats keep one lease alive without rewriting the key
+func TestLeaseReuse(t *testing.T) {
+	endpoint, cleanup := startEmbeddedETCD(t)
+	defer cleanup()
+	time.Sleep(500 * time.Millisecond) // Give server time to stabilize
+
+	srv, err := NewServer(Config{
+		ETCDEndpoints: []string{endpoint},
+		CacheTTL:      30 * time.Second,
+	})
+	require.NoError(t, err)
+	defer srv.Close()
+	require.IsType(t, &EtcdRegistry{}, srv.registry)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	registry := srv.registry.(*EtcdRegistry)
+
+	reg := registerTestService(t, srv)
+	key := serviceKey(reg.ServiceName, reg.InstanceId)
+
+	leaseID, err := registry.leaseFor(ctx, key)
+	require.NoError(t, err)
+	require.NotEqual(t, clientv3.NoLease, leaseID)
+
+	before, err := registry.client.Get(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, before.Kvs, 1)
+
+	for i := 0; i < 3; i++ {
+		resp, healthErr := srv.HealthCheck(ctx, &voyagerv1.HealthRequest{
+			ServiceName: reg.ServiceName,
+			InstanceId:  reg.InstanceId,
+		})
+		require.NoError(t, healthErr)
+		assert.Equal(t, voyagerv1.HealthResponse_HEALTHY, resp.Status)
+	}
+
+	after, err := registry.client.Get(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, after.Kvs, 1)
+	assert.Equal(t, before.Kvs[0].ModRevision, after.Kvs[0].ModRevision, "heartbeat must not rewrite the key")
+	assert.Equal(t, int64(leaseID), after.Kvs[0].Lease)
+
+	// Re-registration keeps the same lease
+	_, err = srv.Register(ctx, reg)
+	require.NoError(t, err)
+	reusedID, err := registry.leaseFor(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, leaseID, reusedID)
+
+	// Deregistration revokes the lease
+	_, err = srv.Deregister(ctx, &voyagerv1.InstanceID{
+		ServiceName: reg.ServiceName,
+		InstanceId:  reg.InstanceId,
 	})
+	require.NoError(t, err)
+
+	ttl, err := registry.client.TimeToLive(ctx, leaseID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), ttl.TTL)
 }
 
 // TestAuthInterceptor tests authentication middleware
@@ -196,6 +471,14 @@ func TestAuthInterceptor(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("Bearer token", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer test-token"))
+		_, err := srv.AuthInterceptor(ctx, nil, nil, func(_ context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		assert.NoError(t, err)
+	})
+
 	t.Run("Invalid token", func(t *testing.T) {
 		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "wrong-token"))
 		_, err := srv.AuthInterceptor(ctx, nil, nil, nil)
@@ -231,7 +514,7 @@ func TestRegisterAndDiscover(t *testing.T) {
 	})
 	require.NoError(t, err)
 	require.Len(t, list.Instances, 1)
-	assert.Equal(t, reg, list.Instances[0])
+	assert.True(t, proto.Equal(withHealth(reg, voyagerv1.HealthResponse_HEALTHY), list.Instances[0]))
 
 	// Discover non-existing service should return empty list
 	list, err = srv.Discover(context.Background(), &voyagerv1.ServiceQuery{
@@ -241,6 +524,165 @@ func TestRegisterAndDiscover(t *testing.T) {
 	assert.Len(t, list.Instances, 0)
 }
 
+// TestDiscoverSelector tests metadata selector filtering
+func TestDiscoverSelector(t *testing.T) {
+	srv := createInMemoryServer(t)
+	defer srv.Close()
+
+	ctx := context.Background()
+	for i, env := range []string{"production", "staging", "production"} {
+		_, err := srv.Register(ctx, &voyagerv1.Registration{
+			ServiceName: "payment-service",
+			InstanceId:  fmt.Sprintf("instance-%d", i),
+			Address:     "127.0.0.1",
+			Port:        int32(8080 + i),
+			Metadata:    map[string]string{"environment": env, "version": fmt.Sprintf("1.%d", i)},
+		})
+		require.NoError(t, err)
+	}
+
+	discover := func(requirements ...*voyagerv1.LabelRequirement) []string {
+		list, err := srv.Discover(ctx, &voyagerv1.ServiceQuery{
+			ServiceName: "payment-service",
+			Selector:    requirements,
+		})
+		require.NoError(t, err)
+
+		var ids []string
+		for _, inst := range list.Instances {
+			ids = append(ids, inst.InstanceId)
+		}
+		return ids
+	}
+
+	assert.ElementsMatch(t, []string{"instance-0", "instance-2"}, discover(&voyagerv1.LabelRequirement{
+		Key:      "environment",
+		Operator: voyagerv1.LabelRequirement_EQUALS,
+		Values:   []string{"production"},
+	}))
+	assert.ElementsMatch(t, []string{"instance-1"}, discover(&voyagerv1.LabelRequirement{
+		Key:      "environment",
+		Operator: voyagerv1.LabelRequirement_NOT_EQUALS,
+		Values:   []string{"production"},
+	}))
+	assert.ElementsMatch(t, []string{"instance-0", "instance-1"}, discover(&voyagerv1.LabelRequirement{
+		Key:      "version",
+		Operator: voyagerv1.LabelRequirement_IN,
+		Values:   []string{"1.0", "1.1"},
+	}))
+	assert.ElementsMatch(t, []string{"instance-2"}, discover(
+		&voyagerv1.LabelRequirement{
+			Key:      "version",
+			Operator: voyagerv1.LabelRequirement_NOT_IN,
+			Values:   []string{"1.0", "1.1"},
+		},
+		&voyagerv1.LabelRequirement{
+			Key:      "environment",
+			Operator: voyagerv1.LabelRequirement_EXISTS,
+		},
+	))
+	assert.Empty(t, discover(&voyagerv1.LabelRequirement{
+		Key:      "environment",
+		Operator: voyagerv1.LabelRequirement_DOES_NOT_EXIST,
+	}))
+
+	t.Run("Invalid selector", func(t *testing.T) {
+		_, err := srv.Discover(ctx, &voyagerv1.ServiceQuery{
+			ServiceName: "payment-service",
+			Selector:    []*voyagerv1.LabelRequirement{{Key: "environment"}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+// TestListServices tests the service catalog in memory and ETCD modes
+func TestListServices(t *testing.T) {
+	modes := map[string]func(t *testing.T) *Server{
+		"Memory": createInMemoryServer,
+		"ETCD": func(t *testing.T) *Server {
+			endpoint, cleanup := startEmbeddedETCD(t)
+			t.Cleanup(cleanup)
+
+			srv, err := NewServer(Config{ETCDEndpoints: []string{endpoint}, CacheTTL: time.Minute})
+			require.NoError(t, err)
+			require.IsType(t, &EtcdRegistry{}, srv.registry)
+			return srv
+		},
+	}
+
+	for name, newServer := range modes {
+		t.Run(name, func(t *testing.T) {
+			srv := newServer(t)
+			defer srv.Close()
+			ctx := context.Background()
+
+			for i, env := range []string{"production", "staging", "production"} {
+				_, err := srv.Register(ctx, &voyagerv1.Registration{
+					ServiceName: "payment-service",
+					InstanceId:  fmt.Sprintf("instance-%d", i),
+					Address:     "127.0.0.1",
+					Port:        int32(8080 + i),
+					Metadata:    map[string]string{"environment": env},
+				})
+				require.NoError(t, err)
+			}
+			for _, service := range []string{"order-service", "pricing-service", "profile-service"} {
+				_, err := srv.Register(ctx, &voyagerv1.Registration{
+					ServiceName: service,
+					InstanceId:  "instance-0",
+					Address:     "127.0.0.1",
+					Port:        9090,
+				})
+				require.NoError(t, err)
+			}
+			_, err := srv.HealthCheck(ctx, &voyagerv1.HealthRequest{
+				ServiceName: "payment-service",
+				InstanceId:  "instance-1",
+				Status:      voyagerv1.HealthResponse_UNHEALTHY,
+			})
+			require.NoError(t, err)
+
+			resp, err := srv.ListServices(ctx, &voyagerv1.ListServicesRequest{})
+			require.NoError(t, err)
+			require.Len(t, resp.Services, 4)
+			assert.Empty(t, resp.NextPageToken)
+
+			payment := resp.Services[1]
+			assert.Equal(t, "payment-service", payment.Name)
+			assert.Equal(t, int32(3), payment.InstanceCount)
+			assert.Equal(t, int32(2), payment.HealthyCount)
+			require.Contains(t, payment.Metadata, "environment")
+			assert.Equal(t, []string{"production", "staging"}, payment.Metadata["environment"].Values)
+
+			t.Run("Prefix and pagination", func(t *testing.T) {
+				var names []string
+				req := &voyagerv1.ListServicesRequest{Prefix: "p", PageSize: 2}
+				for pages := 0; ; pages++ {
+					require.Less(t, pages, 3)
+					resp, err := srv.ListServices(ctx, req)
+					require.NoError(t, err)
+					for _, service := range resp.Services {
+						names = append(names, service.Name)
+					}
+					if resp.NextPageToken == "" {
+						break
+					}
+					req.PageToken = resp.NextPageToken
+				}
+				assert.Equal(t, []string{"payment-service", "pricing-service", "profile-service"}, names)
+			})
+
+			t.Run("Invalid request", func(t *testing.T) {
+				_, err := srv.ListServices(ctx, &voyagerv1.ListServicesRequest{PageToken: "not base64!"})
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+				_, err = srv.ListServices(ctx, &voyagerv1.ListServicesRequest{PageSize: -1})
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			})
+		})
+	}
+}
+
 // TestHealthCheck tests health status reporting
 func TestHealthCheck(t *testing.T) {
 	srv := createInMemoryServer(t)
@@ -267,6 +709,93 @@ func TestHealthCheck(t *testing.T) {
 	})
 }
 
+// TestInstanceHealth tests SUSPECT and self-reported UNHEALTHY states
+func TestInstanceHealth(t *testing.T) {
+	srv, err := NewServer(Config{
+		CacheTTL:     time.Minute,
+		SuspectAfter: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	ctx := context.Background()
+	reg := registerTestService(t, srv)
+	other := &voyagerv1.Registration{
+		ServiceName: reg.ServiceName,
+		InstanceId:  "instance-2",
+		Address:     "127.0.0.1",
+		Port:        8081,
+	}
+	_, err = srv.Register(ctx, other)
+	require.NoError(t, err)
+
+	discover := func(healthyOnly bool) map[string]voyagerv1.HealthResponse_Status {
+		list, discoverErr := srv.Discover(ctx, &voyagerv1.ServiceQuery{
+			ServiceName: reg.ServiceName,
+			HealthyOnly: healthyOnly,
+		})
+		require.NoError(t, discoverErr)
+
+		states := make(map[string]voyagerv1.HealthResponse_Status)
+		for _, inst := range list.Instances {
+			states[inst.InstanceId] = inst.Health
+		}
+		return states
+	}
+
+	t.Run("Missed heartbeats", func(t *testing.T) {
+		assert.Eventually(t, func() bool {
+			return discover(false)[reg.InstanceId] == voyagerv1.HealthResponse_SUSPECT
+		}, 2*time.Second, 20*time.Millisecond)
+
+		// Keep the second instance healthy and check it is the only one returned
+		assert.Eventually(t, func() bool {
+			resp, healthErr := srv.HealthCheck(ctx, &voyagerv1.HealthRequest{
+				ServiceName: other.ServiceName,
+				InstanceId:  other.InstanceId,
+			})
+			require.NoError(t, healthErr)
+			require.Equal(t, voyagerv1.HealthResponse_HEALTHY, resp.Status)
+
+			healthy := discover(true)
+			_, suspectListed := healthy[reg.InstanceId]
+			return len(healthy) == 1 && !suspectListed
+		}, 2*time.Second, 20*time.Millisecond)
+
+		resp, err := srv.HealthCheck(ctx, &voyagerv1.HealthRequest{
+			ServiceName: reg.ServiceName,
+			InstanceId:  reg.InstanceId,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, voyagerv1.HealthResponse_HEALTHY, resp.Status)
+	})
+
+	t.Run("Self-reported unhealthy", func(t *testing.T) {
+		resp, err := srv.HealthCheck(ctx, &voyagerv1.HealthRequest{
+			ServiceName: reg.ServiceName,
+			InstanceId:  reg.InstanceId,
+			Status:      voyagerv1.HealthResponse_UNHEALTHY,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, voyagerv1.HealthResponse_UNHEALTHY, resp.Status)
+		assert.Equal(t, voyagerv1.HealthResponse_UNHEALTHY, discover(false)[reg.InstanceId])
+		assert.NotContains(t, discover(true), reg.InstanceId)
+
+		// The reported state is stored so other servers see it too
+		stored, err := srv.registry.Get(ctx, reg.ServiceName, reg.InstanceId)
+		require.NoError(t, err)
+		assert.Equal(t, voyagerv1.HealthResponse_UNHEALTHY, stored.Health)
+
+		resp, err = srv.HealthCheck(ctx, &voyagerv1.HealthRequest{
+			ServiceName: reg.ServiceName,
+			InstanceId:  reg.InstanceId,
+			Status:      voyagerv1.HealthResponse_HEALTHY,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, voyagerv1.HealthResponse_HEALTHY, resp.Status)
+	})
+}
+
 // TestDeregister tests service deregistration
 func TestDeregister(t *testing.T) {
 	srv := createInMemoryServer(t)
@@ -294,20 +823,203 @@ func TestJanitorCleanup(t *testing.T) {
 	srv := createInMemoryServer(t)
 	defer srv.Close()
 
-	reg := registerTestService(t, srv)
-
 	srv.mu.Lock()
 	srv.cacheTTL = 100 * time.Millisecond
 	srv.mu.Unlock()
 
+	reg := registerTestService(t, srv)
+
 	time.Sleep(150 * time.Millisecond)
 
 	srv.cleanupExpiredInstances()
 
-	srv.mu.RLock()
-	defer srv.mu.RUnlock()
-	_, exists := srv.inMemoryInstances[reg.ServiceName]
-	assert.False(t, exists)
+	assert.Eventually(t, func() bool {
+		srv.mu.RLock()
+		defer srv.mu.RUnlock()
+		_, exists := srv.instances[reg.ServiceName]
+		return !exists
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestWatch tests registry change streaming
+func TestWatch(t *testing.T) {
+	srv := createInMemoryServer(t)
+	defer srv.Close()
+
+	reg := registerTestService(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := &fakeWatchStream{ctx: ctx, events: make(chan *voyagerv1.ServiceEvent, 16)}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Watch(&voyagerv1.ServiceQuery{ServiceName: reg.ServiceName}, stream)
+	}()
+
+	snapshot := receiveEvent(t, stream)
+	assert.Equal(t, voyagerv1.ServiceEvent_SNAPSHOT, snapshot.Type)
+	require.Len(t, snapshot.Instances, 1)
+	assert.Equal(t, reg.InstanceId, snapshot.Instances[0].InstanceId)
+
+	// Identical re-registration is not a change
+	_, err := srv.Register(context.Background(), reg)
+	require.NoError(t, err)
+
+	updated := &voyagerv1.Registration{
+		ServiceName: reg.ServiceName,
+		InstanceId:  reg.InstanceId,
+		Address:     reg.Address,
+		Port:        9090,
+	}
+	_, err = srv.Register(context.Background(), updated)
+	require.NoError(t, err)
+
+	event := receiveEvent(t, stream)
+	assert.Equal(t, voyagerv1.ServiceEvent_UPDATED, event.Type)
+	assert.Equal(t, int32(9090), event.Instance.Port)
+	assert.Equal(t, snapshot.Revision+1, event.Revision)
+
+	// Other services are filtered out
+	_, err = srv.Register(context.Background(), &voyagerv1.Registration{
+		ServiceName: "other-service",
+		InstanceId:  "instance-1",
+		Address:     "127.0.0.1",
+		Port:        8081,
+	})
+	require.NoError(t, err)
+
+	_, err = srv.Deregister(context.Background(), &voyagerv1.InstanceID{
+		ServiceName: reg.ServiceName,
+		InstanceId:  reg.InstanceId,
+	})
+	require.NoError(t, err)
+
+	event = receiveEvent(t, stream)
+	assert.Equal(t, voyagerv1.ServiceEvent_REMOVED, event.Type)
+	assert.Equal(t, reg.InstanceId, event.Instance.InstanceId)
+	removedRevision := event.Revision
+
+	cancel()
+	require.NoError(t, <-done)
+
+	t.Run("Resume from revision", func(t *testing.T) {
+		resumeCtx, resumeCancel := context.WithCancel(context.Background())
+		defer resumeCancel()
+
+		resumed := &fakeWatchStream{ctx: resumeCtx, events: make(chan *voyagerv1.ServiceEvent, 16)}
+		go func() {
+			_ = srv.Watch(&voyagerv1.ServiceQuery{
+				ServiceName:    reg.ServiceName,
+				ResumeRevision: snapshot.Revision,
+			}, resumed)
+		}()
+
+		event := receiveEvent(t, resumed)
+		assert.Equal(t, voyagerv1.ServiceEvent_UPDATED, event.Type)
+		event = receiveEvent(t, resumed)
+		assert.Equal(t, voyagerv1.ServiceEvent_REMOVED, event.Type)
+		assert.Equal(t, removedRevision, event.Revision)
+	})
+
+	t.Run("Expired revision falls back to snapshot", func(t *testing.T) {
+		expiredCtx, expiredCancel := context.WithCancel(context.Background())
+		defer expiredCancel()
+
+		expired := &fakeWatchStream{ctx: expiredCtx, events: make(chan *voyagerv1.ServiceEvent, 16)}
+		go func() {
+			_ = srv.Watch(&voyagerv1.ServiceQuery{ResumeRevision: 1}, expired)
+		}()
+
+		event := receiveEvent(t, expired)
+		assert.Equal(t, voyagerv1.ServiceEvent_SNAPSHOT, event.Type)
+		require.Len(t, event.Instances, 1)
+		assert.Equal(t, "other-service", event.Instances[0].ServiceName)
+	})
+
+	t.Run("Health and selector filters", func(t *testing.T) {
+		filterCtx, filterCancel := context.WithCancel(context.Background())
+		defer filterCancel()
+
+		register := func(id, env string, health voyagerv1.HealthResponse_Status) {
+			_, err := srv.Register(filterCtx, &voyagerv1.Registration{
+				ServiceName: "filtered-service",
+				InstanceId:  id,
+				Address:     "127.0.0.1",
+				Port:        8080,
+				Metadata:    map[string]string{"environment": env},
+				Health:      health,
+			})
+			require.NoError(t, err)
+		}
+		register("instance-1", "production", voyagerv1.HealthResponse_HEALTHY)
+		register("instance-2", "staging", voyagerv1.HealthResponse_HEALTHY)
+		register("instance-3", "production", voyagerv1.HealthResponse_UNHEALTHY)
+
+		query := &voyagerv1.ServiceQuery{
+			ServiceName: "filtered-service",
+			HealthyOnly: true,
+			Selector: []*voyagerv1.LabelRequirement{{
+				Key:      "environment",
+				Operator: voyagerv1.LabelRequirement_EQUALS,
+				Values:   []string{"production"},
+			}},
+		}
+		filtered := &fakeWatchStream{ctx: filterCtx, events: make(chan *voyagerv1.ServiceEvent, 16)}
+		go func() {
+			_ = srv.Watch(query, filtered)
+		}()
+
+		event := receiveEvent(t, filtered)
+		assert.Equal(t, voyagerv1.ServiceEvent_SNAPSHOT, event.Type)
+		require.Len(t, event.Instances, 1)
+		assert.Equal(t, "instance-1", event.Instances[0].InstanceId)
+
+		// An instance turning healthy enters the filters
+		_, err := srv.HealthCheck(filterCtx, &voyagerv1.HealthRequest{
+			ServiceName: "filtered-service",
+			InstanceId:  "instance-3",
+			Status:      voyagerv1.HealthResponse_HEALTHY,
+		})
+		require.NoError(t, err)
+		event = receiveEvent(t, filtered)
+		assert.Equal(t, voyagerv1.ServiceEvent_ADDED, event.Type)
+		assert.Equal(t, "instance-3", event.Instance.InstanceId)
+
+		// An instance no longer matching the selector leaves them
+		register("instance-1", "staging", voyagerv1.HealthResponse_HEALTHY)
+		event = receiveEvent(t, filtered)
+		assert.Equal(t, voyagerv1.ServiceEvent_REMOVED, event.Type)
+		assert.Equal(t, "instance-1", event.Instance.InstanceId)
+
+		// Changes to instances outside the filters are not sent
+		_, err = srv.Deregister(filterCtx, &voyagerv1.InstanceID{ServiceName: "filtered-service", InstanceId: "instance-2"})
+		require.NoError(t, err)
+		register("instance-4", "production", voyagerv1.HealthResponse_HEALTHY)
+		event = receiveEvent(t, filtered)
+		assert.Equal(t, voyagerv1.ServiceEvent_ADDED, event.Type)
+		assert.Equal(t, "instance-4", event.Instance.InstanceId)
+
+		// Filtered watches cannot resume from the history
+		resumed := &fakeWatchStream{ctx: filterCtx, events: make(chan *voyagerv1.ServiceEvent, 16)}
+		go func() {
+			_ = srv.Watch(&voyagerv1.ServiceQuery{
+				ServiceName:    query.ServiceName,
+				HealthyOnly:    true,
+				Selector:       query.Selector,
+				ResumeRevision: event.Revision,
+			}, resumed)
+		}()
+		event = receiveEvent(t, resumed)
+		assert.Equal(t, voyagerv1.ServiceEvent_SNAPSHOT, event.Type)
+		assert.Len(t, event.Instances, 2)
+
+		err = srv.Watch(&voyagerv1.ServiceQuery{
+			ServiceName: query.ServiceName,
+			Selector:    []*voyagerv1.LabelRequirement{{Operator: voyagerv1.LabelRequirement_EQUALS}},
+		}, filtered)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
 }
 
 // TestEtcdAdapter tests ETCD adapter operations
@@ -380,6 +1092,13 @@ func TestMetrics(t *testing.T) {
 
 // Helper functions
 
+// withHealth returns a copy of reg with the given health state
+func withHealth(reg *voyagerv1.Registration, health voyagerv1.HealthResponse_Status) *voyagerv1.Registration {
+	clone := proto.Clone(reg).(*voyagerv1.Registration)
+	clone.Health = health
+	return clone
+}
+
 // createInMemoryServer creates in-memory server for tests
 func createInMemoryServer(t *testing.T) *Server {
 	srv, err := NewServer(Config{
@@ -389,6 +1108,33 @@ func createInMemoryServer(t *testing.T) *Server {
 	return srv
 }
 
+// fakeWatchStream captures events sent by Server.Watch
+type fakeWatchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *voyagerv1.ServiceEvent
+}
+
+func (f *fakeWatchStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeWatchStream) Send(event *voyagerv1.ServiceEvent) error {
+	f.events <- event
+	return nil
+}
+
+// receiveEvent waits for the next event on a fake watch stream
+func receiveEvent(t *testing.T, stream *fakeWatchStream) *voyagerv1.ServiceEvent {
+	select {
+	case event := <-stream.events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for watch event")
+		return nil
+	}
+}
+
 // registerTestService registers test service
 func registerTestService(t *testing.T, srv *Server) *voyagerv1.Registration {
 	reg := &voyagerv1.Registration{
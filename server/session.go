@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+const (
+	// defaultSessionGrace is used when Config.SessionGrace is not set
+	defaultSessionGrace = 10 * time.Second
+	// sessionClockSlack is how much later than the disconnect a heartbeat
+	// must be seen to keep the instance, in any registry. It covers the last
+	// keepalives of the broken stream, which are stamped when handled and
+	// may race the disconnect, and clock skew between servers sharing
+	// heartbeats.
+	sessionClockSlack = time.Second
+)
+
+// instanceSession tracks the Session streams keeping one instance alive
+type instanceSession struct {
+	streams      int
+	generation   uint64 // bumped whenever a stream binds, invalidating pending expiry
+	timer        *time.Timer
+	disconnected time.Time
+}
+
+// sessionRequest is a received SessionRequest or the error ending the stream
+type sessionRequest struct {
+	req *voyagerv1.SessionRequest
+	err error
+}
+
+// Session keeps the instances announced on the stream alive. Every request
+// is handled like a HealthCheck and answered with the effective health. When
+// the stream breaks, its instances are deregistered after the grace period
+// unless another stream or a unary heartbeat picked them up in the meantime.
+func (s *Server) Session(stream voyagerv1.Discovery_SessionServer) error {
+	sessionsGauge.Inc()
+	defer sessionsGauge.Dec()
+
+	type boundInstance struct {
+		serviceName, instanceID string
+	}
+	bound := make(map[string]boundInstance)
+	defer func() {
+		for key, instance := range bound {
+			s.endSession(key, instance.serviceName, instance.instanceID)
+		}
+	}()
+
+	requests := make(chan sessionRequest)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			select {
+			case requests <- sessionRequest{req: req, err: err}:
+			case <-stream.Context().Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		var received sessionRequest
+		select {
+		case received = <-requests:
+		case <-stream.Context().Done():
+			return nil
+		case <-s.ctx.Done():
+			return status.Error(codes.Unavailable, "server shutting down")
+		}
+		if errors.Is(received.err, io.EOF) {
+			return nil
+		}
+		if received.err != nil {
+			return received.err
+		}
+
+		req := received.req
+		if req.ServiceName == "" || req.InstanceId == "" {
+			return status.Error(codes.InvalidArgument, "service_name and instance_id are required")
+		}
+
+		health, err := s.heartbeat(stream.Context(), req.ServiceName, req.InstanceId, req.Status)
+		if errors.Is(err, ErrInstanceNotFound) {
+			return status.Errorf(codes.NotFound, "instance %s/%s is not registered", req.ServiceName, req.InstanceId)
+		}
+		if err != nil {
+			return status.Error(codes.Unavailable, "failed to refresh instance")
+		}
+
+		key := instanceKey(req.ServiceName, req.InstanceId)
+		if _, exists := bound[key]; !exists {
+			log.Printf("Session opened for service %s instance %s", req.ServiceName, req.InstanceId)
+			bound[key] = boundInstance{serviceName: req.ServiceName, instanceID: req.InstanceId}
+			s.beginSession(key)
+		}
+
+		if err := stream.Send(&voyagerv1.SessionResponse{Status: health}); err != nil {
+			return err
+		}
+	}
+}
+
+// beginSession binds a stream to an instance and cancels a pending expiry
+func (s *Server) beginSession(key string) {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	session, exists := s.sessions[key]
+	if !exists {
+		session = &instanceSession{}
+		s.sessions[key] = session
+	}
+	session.streams++
+	session.generation++
+	if session.timer != nil {
+		session.timer.Stop()
+		session.timer = nil
+	}
+}
+
+// endSession unbinds a stream and schedules the instance for removal once
+// no stream is left
+func (s *Server) endSession(key, serviceName, instanceID string) {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	session, exists := s.sessions[key]
+	if !exists {
+		return
+	}
+	session.streams--
+	if session.streams > 0 {
+		return
+	}
+	if s.ctx.Err() != nil {
+		// Shutting down; clients move to another server within the grace
+		delete(s.sessions, key)
+		return
+	}
+
+	log.Printf("Session closed for service %s instance %s, deregistering in %v",
+		serviceName, instanceID, s.sessionGrace)
+	session.disconnected = time.Now()
+	generation := session.generation
+	session.timer = time.AfterFunc(s.sessionGrace, func() {
+		s.expireSession(key, serviceName, instanceID, generation)
+	})
+}
+
+// expireSession deregisters an instance whose Session stream did not come
+// back within the grace period
+func (s *Server) expireSession(key, serviceName, instanceID string, generation uint64) {
+	s.sessionMu.Lock()
+	session, exists := s.sessions[key]
+	if !exists || session.generation != generation || session.streams > 0 {
+		s.sessionMu.Unlock()
+		return
+	}
+	delete(s.sessions, key)
+	disconnected := session.disconnected
+	s.sessionMu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	// A heartbeat after the disconnect means the instance is kept alive
+	// elsewhere, by another server or by the unary HealthCheck
+	lastSeen, err := s.registry.LastSeen(ctx)
+	if err != nil {
+		log.Printf("Failed to check instance %s/%s before session expiry: %v", serviceName, instanceID, err)
+		return
+	}
+	seen, exists := lastSeen[key]
+	if !exists || seen.After(disconnected.Add(sessionClockSlack)) {
+		return
+	}
+
+	log.Printf("Session for service %s instance %s expired, deregistering", serviceName, instanceID)
+	if _, err := s.Deregister(ctx, &voyagerv1.InstanceID{ServiceName: serviceName, InstanceId: instanceID}); err != nil {
+		log.Printf("Failed to deregister instance %s/%s after session expiry: %v", serviceName, instanceID, err)
+		return
+	}
+	sessionExpiredCounter.Inc()
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// TestSession tests keepalives and deregistration after a broken stream
+func TestSession(t *testing.T) {
+	srv, err := NewServer(Config{
+		CacheTTL:     time.Minute,
+		SessionGrace: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	grpcSrv := srv.GRPCServer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = grpcSrv.Serve(listener)
+	}()
+	defer grpcSrv.Stop()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	discovery := voyagerv1.NewDiscoveryClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	register := func(instanceID string) {
+		_, err := srv.Register(ctx, &voyagerv1.Registration{
+			ServiceName: "payment-service",
+			InstanceId:  instanceID,
+			Address:     "10.0.0.1",
+			Port:        8080,
+		})
+		require.NoError(t, err)
+	}
+	registered := func(instanceID string) bool {
+		resp, err := srv.Discover(ctx, &voyagerv1.ServiceQuery{ServiceName: "payment-service"})
+		require.NoError(t, err)
+		for _, instance := range resp.Instances {
+			if instance.InstanceId == instanceID {
+				return true
+			}
+		}
+		return false
+	}
+	keepalive := func(stream voyagerv1.Discovery_SessionClient, instanceID string, health voyagerv1.HealthResponse_Status) voyagerv1.HealthResponse_Status {
+		require.NoError(t, stream.Send(&voyagerv1.SessionRequest{
+			ServiceName: "payment-service",
+			InstanceId:  instanceID,
+			Status:      health,
+		}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	t.Run("Deregister on disconnect", func(t *testing.T) {
+		register("instance-1")
+
+		streamCtx, closeStream := context.WithCancel(ctx)
+		stream, err := discovery.Session(streamCtx)
+		require.NoError(t, err)
+		assert.Equal(t, voyagerv1.HealthResponse_HEALTHY, keepalive(stream, "instance-1", voyagerv1.HealthResponse_UNKNOWN))
+		assert.Equal(t, voyagerv1.HealthResponse_UNHEALTHY, keepalive(stream, "instance-1", voyagerv1.HealthResponse_UNHEALTHY))
+
+		closeStream()
+		assert.Eventually(t, func() bool {
+			return !registered("instance-1")
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("Reconnect within grace", func(t *testing.T) {
+		register("instance-2")
+
+		streamCtx, closeStream := context.WithCancel(ctx)
+		stream, err := discovery.Session(streamCtx)
+		require.NoError(t, err)
+		keepalive(stream, "instance-2", voyagerv1.HealthResponse_HEALTHY)
+		closeStream()
+
+		stream, err = discovery.Session(ctx)
+		require.NoError(t, err)
+		keepalive(stream, "instance-2", voyagerv1.HealthResponse_HEALTHY)
+
+		time.Sleep(300 * time.Millisecond)
+		assert.True(t, registered("instance-2"))
+		require.NoError(t, stream.CloseSend())
+	})
+
+	t.Run("Unknown instance", func(t *testing.T) {
+		stream, err := discovery.Session(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&voyagerv1.SessionRequest{
+			ServiceName: "payment-service",
+			InstanceId:  "missing",
+		}))
+		_, err = stream.Recv()
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
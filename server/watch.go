@@ -0,0 +1,294 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+	"github.com/kolkov/voyager/internal/selector"
+)
+
+const (
+	// watchHistorySize is the number of recent events kept for resuming watchers
+	watchHistorySize = 1024
+	// watchBufferSize is the per-watcher queue length before it is dropped
+	watchBufferSize = 256
+)
+
+// watchSubscriber is a single Watch stream waiting for events
+type watchSubscriber struct {
+	serviceName string
+	events      chan *voyagerv1.ServiceEvent
+	overflow    chan struct{}
+	healthyOnly bool
+	selector    []*voyagerv1.LabelRequirement
+	visible     map[string]bool // instances a filtered watcher has been sent
+}
+
+// watchHub assigns revisions to registry changes and fans them out to watchers
+type watchHub struct {
+	mu          sync.Mutex
+	revision    uint64
+	history     []*voyagerv1.ServiceEvent
+	subscribers map[*watchSubscriber]struct{}
+}
+
+func newWatchHub() *watchHub {
+	return &watchHub{
+		// Seed from the clock so revisions handed out before a restart
+		// are never mistaken for ones issued after it.
+		revision:    uint64(time.Now().UnixNano()),
+		subscribers: make(map[*watchSubscriber]struct{}),
+	}
+}
+
+// publish records a change and delivers it to matching watchers.
+// Callers hold Server.mu so that events are ordered with registry state.
+func (h *watchHub) publish(eventType voyagerv1.ServiceEvent_Type, reg *voyagerv1.Registration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.revision++
+	event := &voyagerv1.ServiceEvent{
+		Type:     eventType,
+		Instance: reg,
+		Revision: h.revision,
+	}
+
+	h.history = append(h.history, event)
+	if len(h.history) > watchHistorySize {
+		h.history = h.history[len(h.history)-watchHistorySize:]
+	}
+
+	watchEventsCounter.WithLabelValues(eventType.String()).Inc()
+
+	for sub := range h.subscribers {
+		if !sub.matches(reg.ServiceName) {
+			continue
+		}
+		delivered := sub.translate(event)
+		if delivered == nil {
+			continue
+		}
+		select {
+		case sub.events <- delivered:
+		default:
+			log.Printf("Dropping slow watcher for service %q at revision %d", sub.serviceName, h.revision)
+			close(sub.overflow)
+			delete(h.subscribers, sub)
+			watchersGauge.Dec()
+		}
+	}
+}
+
+// subscribe registers a watcher and returns the events it missed since
+// resumeRevision. When the revision is zero or no longer in history, ok is
+// false and the caller must send a snapshot instead.
+func (h *watchHub) subscribe(serviceName string, resumeRevision uint64) (sub *watchSubscriber, backlog []*voyagerv1.ServiceEvent, revision uint64, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub = &watchSubscriber{
+		serviceName: serviceName,
+		events:      make(chan *voyagerv1.ServiceEvent, watchBufferSize),
+		overflow:    make(chan struct{}),
+	}
+	h.subscribers[sub] = struct{}{}
+	watchersGauge.Inc()
+
+	if resumeRevision == 0 || resumeRevision > h.revision {
+		return sub, nil, h.revision, false
+	}
+
+	if resumeRevision < h.revision {
+		if len(h.history) == 0 || h.history[0].Revision > resumeRevision+1 {
+			return sub, nil, h.revision, false
+		}
+	}
+
+	for _, event := range h.history {
+		if event.Revision > resumeRevision && sub.matches(event.Instance.ServiceName) {
+			backlog = append(backlog, event)
+		}
+	}
+	return sub, backlog, h.revision, true
+}
+
+// unsubscribe removes a watcher
+func (h *watchHub) unsubscribe(sub *watchSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.subscribers[sub]; exists {
+		delete(h.subscribers, sub)
+		watchersGauge.Dec()
+	}
+}
+
+// matches reports whether the watcher is interested in a service
+func (sub *watchSubscriber) matches(serviceName string) bool {
+	return sub.serviceName == "" || sub.serviceName == serviceName
+}
+
+// filter restricts the watcher to HEALTHY instances when healthyOnly is set
+// and to instances matching requirements, starting from snapshot, and
+// returns the part of snapshot it sees. Callers hold Server.mu, so no event
+// is published meanwhile.
+func (sub *watchSubscriber) filter(healthyOnly bool, requirements []*voyagerv1.LabelRequirement, snapshot []*voyagerv1.Registration) []*voyagerv1.Registration {
+	if !healthyOnly && len(requirements) == 0 {
+		return snapshot
+	}
+
+	sub.healthyOnly = healthyOnly
+	sub.selector = requirements
+	sub.visible = make(map[string]bool)
+	var filtered []*voyagerv1.Registration
+	for _, reg := range snapshot {
+		if sub.accepts(reg) {
+			sub.visible[instanceKey(reg.ServiceName, reg.InstanceId)] = true
+			filtered = append(filtered, reg)
+		}
+	}
+	return filtered
+}
+
+// accepts reports whether an instance passes the filters of the watcher
+func (sub *watchSubscriber) accepts(reg *voyagerv1.Registration) bool {
+	return (!sub.healthyOnly || isHealthy(reg)) && selector.Matches(sub.selector, reg.Metadata)
+}
+
+// translate adapts an event to a filtered watcher: instances entering its
+// filters are ADDED and instances leaving them REMOVED. It returns nil when
+// the watcher does not see the change.
+func (sub *watchSubscriber) translate(event *voyagerv1.ServiceEvent) *voyagerv1.ServiceEvent {
+	if sub.visible == nil {
+		return event
+	}
+
+	key := instanceKey(event.Instance.ServiceName, event.Instance.InstanceId)
+	wasVisible := sub.visible[key]
+	visible := event.Type != voyagerv1.ServiceEvent_REMOVED && sub.accepts(event.Instance)
+	if visible {
+		sub.visible[key] = true
+	} else {
+		delete(sub.visible, key)
+	}
+
+	eventType := event.Type
+	switch {
+	case !wasVisible && !visible:
+		return nil
+	case !wasVisible:
+		eventType = voyagerv1.ServiceEvent_ADDED
+	case !visible:
+		eventType = voyagerv1.ServiceEvent_REMOVED
+	}
+	if eventType == event.Type {
+		return event
+	}
+	return &voyagerv1.ServiceEvent{Type: eventType, Instance: event.Instance, Revision: event.Revision}
+}
+
+// Watch streams registry changes for a service, or for all services when
+// the query has no service name. The stream starts with a SNAPSHOT event
+// unless resume_revision is still covered by the server's event history.
+// healthy_only and selector filter like Discover; instances entering or
+// leaving the filters are reported as ADDED and REMOVED, and filtered
+// watches always start with a snapshot.
+func (s *Server) Watch(req *voyagerv1.ServiceQuery, stream voyagerv1.Discovery_WatchServer) error {
+	log.Printf("Watch request for service: %q, resume revision: %d", req.ServiceName, req.ResumeRevision)
+
+	if err := selector.Validate(req.Selector); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// The instances a filtered watcher was sent are unknown after it
+	// reconnects, so it cannot resume from the history
+	resumeRevision := req.ResumeRevision
+	if req.HealthyOnly || len(req.Selector) > 0 {
+		resumeRevision = 0
+	}
+
+	// Subscribing under the read lock guarantees no change slips between
+	// the snapshot and the first streamed event.
+	s.mu.RLock()
+	sub, backlog, revision, resumed := s.hub.subscribe(req.ServiceName, resumeRevision)
+	var snapshot *voyagerv1.ServiceEvent
+	if !resumed {
+		snapshot = &voyagerv1.ServiceEvent{
+			Type:      voyagerv1.ServiceEvent_SNAPSHOT,
+			Instances: sub.filter(req.HealthyOnly, req.Selector, s.listInstancesLocked(req.ServiceName)),
+			Revision:  revision,
+		}
+	}
+	s.mu.RUnlock()
+	defer s.hub.unsubscribe(sub)
+
+	if snapshot != nil {
+		if err := stream.Send(snapshot); err != nil {
+			return err
+		}
+	}
+	for _, event := range backlog {
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case event := <-sub.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-sub.overflow:
+			return status.Error(codes.ResourceExhausted, "watcher fell behind, resume from the last received revision")
+		case <-stream.Context().Done():
+			return nil
+		case <-s.ctx.Done():
+			return status.Error(codes.Unavailable, "server shutting down")
+		}
+	}
+}
+
+// listInstancesLocked returns instances of a service, or of every service
+// when serviceName is empty. Callers must hold s.mu.
+func (s *Server) listInstancesLocked(serviceName string) []*voyagerv1.Registration {
+	var list []*voyagerv1.Registration
+	for name, instances := range s.instances {
+		if serviceName != "" && name != serviceName {
+			continue
+		}
+		for _, info := range instances {
+			list = append(list, info.registration)
+		}
+	}
+	return list
+}
+
+// publishDiff emits events that turn the old service map into the new one.
+// Callers must hold s.mu for writing.
+func (s *Server) publishDiff(oldServices, newServices map[string]map[string]*instanceInfo) {
+	for serviceName, instances := range oldServices {
+		for instanceID, info := range instances {
+			if _, exists := newServices[serviceName][instanceID]; !exists {
+				s.hub.publish(voyagerv1.ServiceEvent_REMOVED, info.registration)
+			}
+		}
+	}
+
+	for serviceName, instances := range newServices {
+		for instanceID, info := range instances {
+			old, exists := oldServices[serviceName][instanceID]
+			if !exists {
+				s.hub.publish(voyagerv1.ServiceEvent_ADDED, info.registration)
+			} else {
+				s.publishRegistration(old.registration, info.registration)
+			}
+		}
+	}
+}
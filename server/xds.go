@@ -0,0 +1,392 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	routerv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	xdsserverv3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+const (
+	// XDSMetadataNamespace is the filter_metadata key holding instance metadata
+	XDSMetadataNamespace = "voyager"
+
+	// xdsNodeGroup is the snapshot shared by every xDS client
+	xdsNodeGroup = "voyager"
+	// xdsConnectTimeout is the cluster connect timeout
+	xdsConnectTimeout = 5 * time.Second
+	// xdsRouterFilter is the terminal HTTP filter of listeners
+	xdsRouterFilter = "envoy.filters.http.router"
+)
+
+// Metadata keys mapped to the xDS locality of endpoints registered without
+// Registration.locality
+const (
+	LocalityRegionKey  = "region"
+	LocalityZoneKey    = "zone"
+	LocalitySubZoneKey = "sub_zone"
+)
+
+// XDSServer publishes the registry as an xDS control plane over the
+// aggregated discovery service, in state-of-the-world and incremental
+// variants. Every service is an EDS cluster of the same name and its
+// instances are the endpoints of that cluster. Every service also gets an API
+// listener and a route configuration of the same name sending all requests to
+// its cluster, which proxyless gRPC clients look up for xds:///<service>. Instance metadata becomes
+// endpoint metadata under the "voyager" namespace. Registration.locality, or
+// the region, zone and sub_zone metadata keys, select the endpoint locality.
+type XDSServer struct {
+	server  *Server
+	cache   cachev3.SnapshotCache
+	xds     xdsserverv3.Server
+	version atomic.Uint64
+}
+
+// xdsLocalityKey groups endpoints by locality
+type xdsLocalityKey struct {
+	region, zone, subZone string
+}
+
+// xdsNodeHash serves the same snapshot to every node
+type xdsNodeHash struct{}
+
+// ID maps every node to the shared snapshot
+func (xdsNodeHash) ID(*corev3.Node) string {
+	return xdsNodeGroup
+}
+
+// XDSServer returns an xDS control plane that follows every registry change
+// until the server is closed
+func (s *Server) XDSServer() *XDSServer {
+	x := &XDSServer{
+		server: s,
+		cache:  cachev3.NewSnapshotCache(true, xdsNodeHash{}, nil),
+	}
+	x.xds = xdsserverv3.NewServer(s.ctx, x.cache, xdsserverv3.CallbackFuncs{
+		StreamOpenFunc: func(context.Context, int64, string) error {
+			xdsStreamsGauge.Inc()
+			return nil
+		},
+		StreamClosedFunc: func(int64, *corev3.Node) {
+			xdsStreamsGauge.Dec()
+		},
+		DeltaStreamOpenFunc: func(context.Context, int64, string) error {
+			xdsStreamsGauge.Inc()
+			return nil
+		},
+		DeltaStreamClosedFunc: func(int64, *corev3.Node) {
+			xdsStreamsGauge.Dec()
+		},
+	})
+
+	// Subscribing before the first snapshot guarantees no change is missed
+	sub, _, _, _ := s.hub.subscribe("", 0)
+	x.updateSnapshot()
+	go x.run(sub)
+	return x
+}
+
+// Register adds the aggregated discovery service to a gRPC server
+func (x *XDSServer) Register(grpcServer *grpc.Server) {
+	discoveryv3.RegisterAggregatedDiscoveryServiceServer(grpcServer, x.xds)
+}
+
+// GRPCServer returns a gRPC server serving only the aggregated discovery
+// service, requiring the server's auth token when one is configured
+func (x *XDSServer) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	serverOpts := []grpc.ServerOption{}
+	if x.server.authToken != "" {
+		serverOpts = append(serverOpts, grpc.StreamInterceptor(x.server.AuthStreamInterceptor))
+	}
+	serverOpts = append(serverOpts, opts...)
+
+	srv := grpc.NewServer(serverOpts...)
+	x.Register(srv)
+	return srv
+}
+
+// run rebuilds the snapshot after registry changes until the server closes
+func (x *XDSServer) run(sub *watchSubscriber) {
+	for {
+		select {
+		case <-sub.events:
+			// Coalesce a burst of changes into one snapshot
+			for drained := false; !drained; {
+				select {
+				case <-sub.events:
+				default:
+					drained = true
+				}
+			}
+			x.updateSnapshot()
+		case <-sub.overflow:
+			// Fell behind; resubscribe and rebuild from the current state
+			sub, _, _, _ = x.server.hub.subscribe("", 0)
+			x.updateSnapshot()
+		case <-x.server.ctx.Done():
+			x.server.hub.unsubscribe(sub)
+			return
+		}
+	}
+}
+
+// updateSnapshot publishes the current instances as listeners, routes,
+// clusters and endpoints
+func (x *XDSServer) updateSnapshot() {
+	s := x.server
+	s.mu.RLock()
+	listeners := make([]types.Resource, 0, len(s.instances))
+	routes := make([]types.Resource, 0, len(s.instances))
+	clusters := make([]types.Resource, 0, len(s.instances))
+	endpoints := make([]types.Resource, 0, len(s.instances))
+	for serviceName, instances := range s.instances {
+		if len(instances) == 0 {
+			continue
+		}
+		listener, err := xdsListener(serviceName)
+		if err != nil {
+			s.mu.RUnlock()
+			log.Printf("Failed to build xDS listener for service %s: %v", serviceName, err)
+			return
+		}
+		listeners = append(listeners, listener)
+		routes = append(routes, xdsRouteConfiguration(serviceName))
+		clusters = append(clusters, xdsCluster(serviceName))
+		endpoints = append(endpoints, xdsLoadAssignment(serviceName, instances))
+	}
+	s.mu.RUnlock()
+
+	version := strconv.FormatUint(x.version.Add(1), 10)
+	snapshot, err := cachev3.NewSnapshot(version, map[resource.Type][]types.Resource{
+		resource.ListenerType: listeners,
+		resource.RouteType:    routes,
+		resource.ClusterType:  clusters,
+		resource.EndpointType: endpoints,
+	})
+	if err != nil {
+		log.Printf("Failed to build xDS snapshot: %v", err)
+		return
+	}
+	if err := x.cache.SetSnapshot(context.Background(), xdsNodeGroup, snapshot); err != nil {
+		log.Printf("Failed to set xDS snapshot: %v", err)
+		return
+	}
+	xdsSnapshotsCounter.Inc()
+}
+
+// xdsListener returns the API listener of a service, fetching its route
+// configuration over ADS
+func xdsListener(serviceName string) (*listenerv3.Listener, error) {
+	router, err := anypb.New(&routerv3.Router{})
+	if err != nil {
+		return nil, err
+	}
+	manager, err := anypb.New(&hcmv3.HttpConnectionManager{
+		RouteSpecifier: &hcmv3.HttpConnectionManager_Rds{
+			Rds: &hcmv3.Rds{
+				ConfigSource:    xdsADSConfigSource(),
+				RouteConfigName: serviceName,
+			},
+		},
+		HttpFilters: []*hcmv3.HttpFilter{{
+			Name:       xdsRouterFilter,
+			ConfigType: &hcmv3.HttpFilter_TypedConfig{TypedConfig: router},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &listenerv3.Listener{
+		Name:        serviceName,
+		ApiListener: &listenerv3.ApiListener{ApiListener: manager},
+	}, nil
+}
+
+// xdsRouteConfiguration routes every request for a service to its cluster
+func xdsRouteConfiguration(serviceName string) *routev3.RouteConfiguration {
+	return &routev3.RouteConfiguration{
+		Name: serviceName,
+		VirtualHosts: []*routev3.VirtualHost{{
+			Name:    serviceName,
+			Domains: []string{"*"},
+			Routes: []*routev3.Route{{
+				Match: &routev3.RouteMatch{
+					PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: ""},
+				},
+				Action: &routev3.Route_Route{
+					Route: &routev3.RouteAction{
+						ClusterSpecifier: &routev3.RouteAction_Cluster{Cluster: serviceName},
+					},
+				},
+			}},
+		}},
+	}
+}
+
+// xdsADSConfigSource points a resource at the aggregated discovery service
+func xdsADSConfigSource() *corev3.ConfigSource {
+	return &corev3.ConfigSource{
+		ResourceApiVersion:    corev3.ApiVersion_V3,
+		ConfigSourceSpecifier: &corev3.ConfigSource_Ads{Ads: &corev3.AggregatedConfigSource{}},
+	}
+}
+
+// xdsCluster returns the EDS cluster of a service
+func xdsCluster(serviceName string) *clusterv3.Cluster {
+	return &clusterv3.Cluster{
+		Name:                 serviceName,
+		ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_EDS},
+		EdsClusterConfig: &clusterv3.Cluster_EdsClusterConfig{
+			EdsConfig: xdsADSConfigSource(),
+		},
+		ConnectTimeout: durationpb.New(xdsConnectTimeout),
+		LbPolicy:       clusterv3.Cluster_ROUND_ROBIN,
+	}
+}
+
+// xdsLoadAssignment groups the instances of a service by locality
+func xdsLoadAssignment(serviceName string, instances map[string]*instanceInfo) *endpointv3.ClusterLoadAssignment {
+	byLocality := make(map[xdsLocalityKey][]*voyagerv1.Registration)
+	for _, info := range instances {
+		locality := xdsLocality(info.registration)
+		byLocality[locality] = append(byLocality[locality], info.registration)
+	}
+
+	localities := make([]xdsLocalityKey, 0, len(byLocality))
+	for locality := range byLocality {
+		localities = append(localities, locality)
+	}
+	sort.Slice(localities, func(i, j int) bool {
+		a, b := localities[i], localities[j]
+		if a.region != b.region {
+			return a.region < b.region
+		}
+		if a.zone != b.zone {
+			return a.zone < b.zone
+		}
+		return a.subZone < b.subZone
+	})
+
+	assignment := &endpointv3.ClusterLoadAssignment{ClusterName: serviceName}
+	for _, locality := range localities {
+		regs := byLocality[locality]
+		sort.Slice(regs, func(i, j int) bool {
+			return regs[i].InstanceId < regs[j].InstanceId
+		})
+
+		var weight uint32
+		for _, reg := range regs {
+			weight += instanceWeight(reg)
+		}
+		if weight == 0 {
+			// Locality weights must be positive; its endpoints are draining
+			weight = 1
+		}
+
+		group := &endpointv3.LocalityLbEndpoints{
+			Locality: &corev3.Locality{
+				Region:  locality.region,
+				Zone:    locality.zone,
+				SubZone: locality.subZone,
+			},
+			LoadBalancingWeight: wrapperspb.UInt32(weight),
+		}
+		for _, reg := range regs {
+			group.LbEndpoints = append(group.LbEndpoints, xdsEndpoint(reg))
+		}
+		assignment.Endpoints = append(assignment.Endpoints, group)
+	}
+	return assignment
+}
+
+// xdsLocality returns the locality of an instance. Registrations without
+// one fall back to the region, zone and sub_zone metadata keys.
+func xdsLocality(reg *voyagerv1.Registration) xdsLocalityKey {
+	if locality := reg.Locality; locality != nil {
+		return xdsLocalityKey{
+			region:  locality.Region,
+			zone:    locality.Zone,
+			subZone: locality.SubZone,
+		}
+	}
+	return xdsLocalityKey{
+		region:  reg.Metadata[LocalityRegionKey],
+		zone:    reg.Metadata[LocalityZoneKey],
+		subZone: reg.Metadata[LocalitySubZoneKey],
+	}
+}
+
+// xdsEndpoint converts an instance to an endpoint with its health, weight and
+// metadata. Instances with weight 0 are DRAINING so they get no new traffic.
+func xdsEndpoint(reg *voyagerv1.Registration) *endpointv3.LbEndpoint {
+	fields := make(map[string]*structpb.Value, len(reg.Metadata)+1)
+	for key, value := range reg.Metadata {
+		fields[key] = structpb.NewStringValue(value)
+	}
+	fields["instance_id"] = structpb.NewStringValue(reg.InstanceId)
+
+	endpoint := &endpointv3.LbEndpoint{
+		HostIdentifier: &endpointv3.LbEndpoint_Endpoint{
+			Endpoint: &endpointv3.Endpoint{
+				Address: &corev3.Address{
+					Address: &corev3.Address_SocketAddress{
+						SocketAddress: &corev3.SocketAddress{
+							Protocol:      corev3.SocketAddress_TCP,
+							Address:       reg.Address,
+							PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: uint32(reg.Port)},
+						},
+					},
+				},
+			},
+		},
+		HealthStatus: xdsHealthStatus(reg.Health),
+		Metadata: &corev3.Metadata{
+			FilterMetadata: map[string]*structpb.Struct{
+				XDSMetadataNamespace: {Fields: fields},
+			},
+		},
+	}
+	if weight := instanceWeight(reg); weight > 0 {
+		endpoint.LoadBalancingWeight = wrapperspb.UInt32(weight)
+	} else {
+		endpoint.HealthStatus = corev3.HealthStatus_DRAINING
+	}
+	return endpoint
+}
+
+// xdsHealthStatus maps instance health to the xDS endpoint health
+func xdsHealthStatus(health voyagerv1.HealthResponse_Status) corev3.HealthStatus {
+	switch health {
+	case voyagerv1.HealthResponse_HEALTHY:
+		return corev3.HealthStatus_HEALTHY
+	case voyagerv1.HealthResponse_SUSPECT:
+		return corev3.HealthStatus_DEGRADED
+	case voyagerv1.HealthResponse_UNHEALTHY:
+		return corev3.HealthStatus_UNHEALTHY
+	default:
+		return corev3.HealthStatus_UNKNOWN
+	}
+}
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+)
+
+// TestXDSServer tests clusters and endpoints over SotW and incremental ADS
+func TestXDSServer(t *testing.T) {
+	srv := createInMemoryServer(t)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := srv.Register(ctx, &voyagerv1.Registration{
+		ServiceName: "payment-service",
+		InstanceId:  "instance-1",
+		Address:     "10.0.0.1",
+		Port:        8080,
+		Metadata:    map[string]string{"region": "eu-west", "zone": "eu-west-1a", "version": "1.2"},
+		Weight:      proto.Uint32(3),
+	})
+	require.NoError(t, err)
+
+	grpcSrv := srv.XDSServer().GRPCServer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = grpcSrv.Serve(listener)
+	}()
+	defer grpcSrv.Stop()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	ads := discoveryv3.NewAggregatedDiscoveryServiceClient(conn)
+	node := &corev3.Node{Id: "test-node"}
+
+	t.Run("State of the world", func(t *testing.T) {
+		stream, err := ads.StreamAggregatedResources(ctx)
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&discoveryv3.DiscoveryRequest{Node: node, TypeUrl: resource.ClusterType}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.Len(t, resp.Resources, 1)
+
+		cluster := &clusterv3.Cluster{}
+		require.NoError(t, resp.Resources[0].UnmarshalTo(cluster))
+		assert.Equal(t, "payment-service", cluster.Name)
+		assert.Equal(t, clusterv3.Cluster_EDS, cluster.GetType())
+		require.NoError(t, stream.Send(&discoveryv3.DiscoveryRequest{
+			Node:          node,
+			TypeUrl:       resource.ClusterType,
+			VersionInfo:   resp.VersionInfo,
+			ResponseNonce: resp.Nonce,
+		}))
+
+		require.NoError(t, stream.Send(&discoveryv3.DiscoveryRequest{
+			Node:          node,
+			TypeUrl:       resource.EndpointType,
+			ResourceNames: []string{"payment-service"},
+		}))
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, resource.EndpointType, resp.TypeUrl)
+		assignment := unmarshalAssignment(t, resp.Resources[0])
+		require.Len(t, assignment.Endpoints, 1)
+
+		locality := assignment.Endpoints[0]
+		assert.Equal(t, "eu-west", locality.Locality.Region)
+		assert.Equal(t, "eu-west-1a", locality.Locality.Zone)
+		require.Len(t, locality.LbEndpoints, 1)
+
+		endpoint := locality.LbEndpoints[0]
+		assert.Equal(t, "10.0.0.1", endpoint.GetEndpoint().Address.GetSocketAddress().Address)
+		assert.Equal(t, uint32(8080), endpoint.GetEndpoint().Address.GetSocketAddress().GetPortValue())
+		assert.Equal(t, corev3.HealthStatus_HEALTHY, endpoint.HealthStatus)
+		assert.Equal(t, uint32(3), endpoint.LoadBalancingWeight.GetValue())
+		assert.Equal(t, uint32(3), locality.LoadBalancingWeight.GetValue())
+		fields := endpoint.Metadata.FilterMetadata[XDSMetadataNamespace].Fields
+		assert.Equal(t, "1.2", fields["version"].GetStringValue())
+		assert.Equal(t, "instance-1", fields["instance_id"].GetStringValue())
+
+		// A registration pushes new endpoints once the previous ones are acked
+		require.NoError(t, stream.Send(&discoveryv3.DiscoveryRequest{
+			Node:          node,
+			TypeUrl:       resource.EndpointType,
+			ResourceNames: []string{"payment-service"},
+			VersionInfo:   resp.VersionInfo,
+			ResponseNonce: resp.Nonce,
+		}))
+		_, err = srv.Register(ctx, &voyagerv1.Registration{
+			ServiceName: "payment-service",
+			InstanceId:  "instance-2",
+			Address:     "10.0.0.2",
+			Port:        8080,
+			Locality:    &voyagerv1.Locality{Region: "us-east", Zone: "us-east-1a"},
+		})
+		require.NoError(t, err)
+
+		for {
+			resp, err = stream.Recv()
+			require.NoError(t, err)
+			if resp.TypeUrl == resource.EndpointType {
+				break
+			}
+		}
+		assignment = unmarshalAssignment(t, resp.Resources[0])
+		require.Len(t, assignment.Endpoints, 2)
+		assert.Equal(t, "us-east-1a", assignment.Endpoints[1].Locality.Zone)
+	})
+
+	t.Run("Listeners and routes", func(t *testing.T) {
+		stream, err := ads.StreamAggregatedResources(ctx)
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&discoveryv3.DiscoveryRequest{
+			Node:          node,
+			TypeUrl:       resource.ListenerType,
+			ResourceNames: []string{"payment-service"},
+		}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.Len(t, resp.Resources, 1)
+
+		listener := &listenerv3.Listener{}
+		require.NoError(t, resp.Resources[0].UnmarshalTo(listener))
+		assert.Equal(t, "payment-service", listener.Name)
+		manager := &hcmv3.HttpConnectionManager{}
+		require.NoError(t, listener.ApiListener.ApiListener.UnmarshalTo(manager))
+		assert.Equal(t, "payment-service", manager.GetRds().RouteConfigName)
+		require.Len(t, manager.HttpFilters, 1)
+		assert.Equal(t, xdsRouterFilter, manager.HttpFilters[0].Name)
+
+		require.NoError(t, stream.Send(&discoveryv3.DiscoveryRequest{
+			Node:          node,
+			TypeUrl:       resource.RouteType,
+			ResourceNames: []string{"payment-service"},
+		}))
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+		require.Len(t, resp.Resources, 1)
+
+		routes := &routev3.RouteConfiguration{}
+		require.NoError(t, resp.Resources[0].UnmarshalTo(routes))
+		require.Len(t, routes.VirtualHosts, 1)
+		assert.Equal(t, []string{"*"}, routes.VirtualHosts[0].Domains)
+		require.Len(t, routes.VirtualHosts[0].Routes, 1)
+		assert.Equal(t, "payment-service", routes.VirtualHosts[0].Routes[0].GetRoute().GetCluster())
+	})
+
+	t.Run("Incremental", func(t *testing.T) {
+		stream, err := ads.DeltaAggregatedResources(ctx)
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&discoveryv3.DeltaDiscoveryRequest{
+			Node:                   node,
+			TypeUrl:                resource.EndpointType,
+			ResourceNamesSubscribe: []string{"payment-service"},
+		}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.Len(t, resp.Resources, 1)
+		assignment := unmarshalAssignment(t, resp.Resources[0].Resource)
+		assert.Len(t, assignment.Endpoints, 2)
+
+		require.NoError(t, stream.Send(&discoveryv3.DeltaDiscoveryRequest{
+			Node:          node,
+			TypeUrl:       resource.EndpointType,
+			ResponseNonce: resp.Nonce,
+		}))
+		_, err = srv.Deregister(ctx, &voyagerv1.InstanceID{ServiceName: "payment-service", InstanceId: "instance-1"})
+		require.NoError(t, err)
+
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+		require.Len(t, resp.Resources, 1)
+		assignment = unmarshalAssignment(t, resp.Resources[0].Resource)
+		require.Len(t, assignment.Endpoints, 1)
+		assert.Equal(t, "10.0.0.2", assignment.Endpoints[0].LbEndpoints[0].GetEndpoint().Address.GetSocketAddress().Address)
+
+		// Removing the last instance removes the cluster load assignment
+		require.NoError(t, stream.Send(&discoveryv3.DeltaDiscoveryRequest{
+			Node:          node,
+			TypeUrl:       resource.EndpointType,
+			ResponseNonce: resp.Nonce,
+		}))
+		_, err = srv.Deregister(ctx, &voyagerv1.InstanceID{ServiceName: "payment-service", InstanceId: "instance-2"})
+		require.NoError(t, err)
+
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"payment-service"}, resp.RemovedResources)
+	})
+}
+
+// TestXDSServer_Auth tests that the xDS server requires the auth token
+func TestXDSServer_Auth(t *testing.T) {
+	srv := createInMemoryServer(t)
+	defer srv.Close()
+	srv.authToken = "test-token"
+
+	grpcSrv := srv.XDSServer().GRPCServer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = grpcSrv.Serve(listener)
+	}()
+	defer grpcSrv.Stop()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	ads := discoveryv3.NewAggregatedDiscoveryServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fetchClusters := func(ctx context.Context) error {
+		stream, err := ads.StreamAggregatedResources(ctx)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&discoveryv3.DiscoveryRequest{Node: &corev3.Node{Id: "test-node"}, TypeUrl: resource.ClusterType}); err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+
+	t.Run("Without token", func(t *testing.T) {
+		assert.Equal(t, codes.PermissionDenied, status.Code(fetchClusters(ctx)))
+	})
+
+	t.Run("With token", func(t *testing.T) {
+		assert.NoError(t, fetchClusters(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer test-token")))
+	})
+}
+
+// unmarshalAssignment decodes a ClusterLoadAssignment resource
+func unmarshalAssignment(t *testing.T, res *anypb.Any) *endpointv3.ClusterLoadAssignment {
+	assignment := &endpointv3.ClusterLoadAssignment{}
+	require.NoError(t, res.UnmarshalTo(assignment))
+	return assignment
+}
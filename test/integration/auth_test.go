@@ -0,0 +1,64 @@
+package integration_test
+
+import (
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kolkov/voyager/client"
+	"github.com/kolkov/voyager/server"
+)
+
+// TestClientAuthentication verifies that client.Client authenticates against
+// a server started with an auth token
+func TestClientAuthentication(t *testing.T) {
+	srv, err := server.NewServer(server.Config{
+		Registry:  server.NewMemoryRegistry(),
+		CacheTTL:  time.Minute,
+		AuthToken: testToken,
+	})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcSrv := srv.GRPCServer()
+	go func() {
+		if srvErr := grpcSrv.Serve(lis); srvErr != nil {
+			log.Printf("gRPC server exited: %v", srvErr)
+		}
+	}()
+	defer grpcSrv.Stop()
+
+	register := func(opts ...client.Option) error {
+		voyager, err := client.New(lis.Addr().String(), append(opts, client.WithInsecure())...)
+		require.NoError(t, err)
+		defer func() {
+			if closeErr := voyager.Close(); closeErr != nil {
+				t.Logf("failed to close client: %v", closeErr)
+			}
+		}()
+		_, err = voyager.Register("payment-service", "10.0.0.1", 8080, nil)
+		return err
+	}
+
+	t.Run("Static token", func(t *testing.T) {
+		assert.NoError(t, register(client.WithAuthToken(testToken)))
+	})
+
+	t.Run("Wrong token", func(t *testing.T) {
+		err := register(client.WithAuthToken("wrong-token"))
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		err := register()
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
@@ -0,0 +1,218 @@
+package integration_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/kolkov/voyager/client"
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+	"github.com/kolkov/voyager/server"
+)
+
+// TestDiscoveryFailover verifies that a client keeps its registration alive
+// through another replica when one discovery server goes away
+func TestDiscoveryFailover(t *testing.T) {
+	registry := server.NewMemoryRegistry()
+
+	// Two replicas share one registry; sessions records the replica serving
+	// the client's Session stream
+	var addrs []string
+	var grpcServers []*grpc.Server
+	sessions := make(chan int, 10)
+	for i := 0; i < 2; i++ {
+		replica := i
+		srv, err := server.NewServer(server.Config{
+			Registry:     registry,
+			CacheTTL:     time.Minute,
+			SessionGrace: 2 * time.Second,
+		})
+		require.NoError(t, err)
+		defer srv.Close()
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		grpcSrv := srv.GRPCServer(grpc.ChainStreamInterceptor(
+			func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if info.FullMethod == "/voyager.v1.Discovery/Session" {
+					sessions <- replica
+				}
+				return handler(srv, ss)
+			}))
+		go func() {
+			if srvErr := grpcSrv.Serve(lis); srvErr != nil {
+				log.Printf("gRPC server exited: %v", srvErr)
+			}
+		}()
+		defer grpcSrv.Stop()
+
+		addrs = append(addrs, lis.Addr().String())
+		grpcServers = append(grpcServers, grpcSrv)
+	}
+
+	voyager, err := client.New(addrs[0],
+		client.WithDiscoveryEndpoints(addrs[1]),
+		client.WithInsecure(),
+		client.WithHealthCheckInterval(100*time.Millisecond),
+	)
+	require.NoError(t, err)
+	defer func() {
+		if closeErr := voyager.Close(); closeErr != nil {
+			t.Logf("failed to close client: %v", closeErr)
+		}
+	}()
+
+	_, err = voyager.Register("payment-service", "10.0.0.1", 8080, map[string]string{
+		"environment": "production",
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	instances := func() []*voyagerv1.Registration {
+		list, err := registry.List(ctx, "payment-service")
+		require.NoError(t, err)
+		return list
+	}
+	require.Len(t, instances(), 1)
+
+	// The replica holding the session goes away; calls and the session move
+	// to the other one
+	var first int
+	select {
+	case first = <-sessions:
+	case <-ctx.Done():
+		t.Fatal("no session opened")
+	}
+	time.Sleep(300 * time.Millisecond)
+	grpcServers[first].Stop()
+
+	services, err := voyager.ListServices(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, services, 1)
+	assert.Equal(t, int32(1), services[0].InstanceCount)
+
+	// Heartbeats through the second replica outlast the session grace of
+	// the first, and the instance is neither lost nor duplicated
+	stopped := time.Now()
+	time.Sleep(3 * time.Second)
+	seen, err := registry.LastSeen(ctx)
+	require.NoError(t, err)
+	require.Len(t, seen, 1)
+	for _, lastSeen := range seen {
+		assert.True(t, lastSeen.After(stopped.Add(2*time.Second)))
+	}
+	select {
+	case second := <-sessions:
+		assert.Equal(t, 1-first, second)
+	default:
+		t.Fatal("session was not re-established")
+	}
+	list := instances()
+	require.Len(t, list, 1)
+	assert.Equal(t, "production", list[0].Metadata["environment"])
+}
+
+// TestDiscoveryFailoverTLS verifies that a client given several discovery
+// servers verifies each of them by its host name over TLS
+func TestDiscoveryFailoverTLS(t *testing.T) {
+	cert, roots := selfSignedCertificate(t, "localhost")
+	registry := server.NewMemoryRegistry()
+
+	var endpoints []string
+	var grpcServers []*grpc.Server
+	for i := 0; i < 2; i++ {
+		srv, err := server.NewServer(server.Config{
+			Registry: registry,
+			CacheTTL: time.Minute,
+		})
+		require.NoError(t, err)
+		defer srv.Close()
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		grpcSrv := srv.GRPCServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+		})))
+		go func() {
+			if srvErr := grpcSrv.Serve(lis); srvErr != nil {
+				log.Printf("gRPC server exited: %v", srvErr)
+			}
+		}()
+		defer grpcSrv.Stop()
+
+		endpoints = append(endpoints, fmt.Sprintf("localhost:%d", lis.Addr().(*net.TCPAddr).Port))
+		grpcServers = append(grpcServers, grpcSrv)
+	}
+
+	// No ServerName in the TLS config; it comes from each endpoint
+	voyager, err := client.New(endpoints[0],
+		client.WithDiscoveryEndpoints(endpoints[1]),
+		client.WithTLSConfig(&tls.Config{RootCAs: roots}),
+	)
+	require.NoError(t, err)
+	defer func() {
+		if closeErr := voyager.Close(); closeErr != nil {
+			t.Logf("failed to close client: %v", closeErr)
+		}
+	}()
+
+	_, err = voyager.Register("payment-service", "10.0.0.1", 8080, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Each replica in turn is the only one left
+	for i := range grpcServers {
+		if i > 0 {
+			grpcServers[i-1].Stop()
+		}
+		services, err := voyager.ListServices(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, services, 1)
+		assert.Equal(t, "payment-service", services[0].Name)
+	}
+}
+
+// selfSignedCertificate returns a certificate for host and a pool trusting it
+func selfSignedCertificate(t *testing.T, host string) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots
+}
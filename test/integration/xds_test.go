@@ -0,0 +1,85 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/xds"
+
+	voyagerv1 "github.com/kolkov/voyager/gen/proto/voyager/v1"
+	"github.com/kolkov/voyager/server"
+)
+
+// TestProxylessXDS verifies that a proxyless gRPC client dialing
+// xds:///<service> reaches a registered instance through the xDS server
+func TestProxylessXDS(t *testing.T) {
+	srv, err := server.NewServer(server.Config{
+		Registry: server.NewMemoryRegistry(),
+		CacheTTL: time.Minute,
+	})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	// The registered instance serves the gRPC health service
+	backendLis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	backend := grpc.NewServer()
+	healthpb.RegisterHealthServer(backend, health.NewServer())
+	go func() {
+		if srvErr := backend.Serve(backendLis); srvErr != nil {
+			log.Printf("backend server exited: %v", srvErr)
+		}
+	}()
+	defer backend.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err = srv.Register(ctx, &voyagerv1.Registration{
+		ServiceName: "payment-service",
+		InstanceId:  "instance-1",
+		Address:     "127.0.0.1",
+		Port:        int32(backendLis.Addr().(*net.TCPAddr).Port),
+	})
+	require.NoError(t, err)
+
+	xdsLis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	xdsSrv := srv.XDSServer().GRPCServer()
+	go func() {
+		if srvErr := xdsSrv.Serve(xdsLis); srvErr != nil {
+			log.Printf("xDS server exited: %v", srvErr)
+		}
+	}()
+	defer xdsSrv.Stop()
+
+	resolver, err := xds.NewXDSResolverWithConfigForTesting([]byte(fmt.Sprintf(`{
+		"xds_servers": [{
+			"server_uri": %q,
+			"channel_creds": [{"type": "insecure"}],
+			"server_features": ["xds_v3"]
+		}],
+		"node": {"id": "test-node"}
+	}`, xdsLis.Addr().String())))
+	require.NoError(t, err)
+
+	conn, err := grpc.NewClient("xds:///payment-service",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithResolvers(resolver))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+}